@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+// defaultAnalyzeTop is how many entries each ranking holds unless the
+// caller asks for more or fewer
+const defaultAnalyzeTop = 10
+
+// AnalyzeOptions are the options of the analyze operation. Top limits the
+// largest-value rankings.
+type AnalyzeOptions struct {
+	Top int `json:"top"`
+}
+
+// DocumentSize compares the serialized size of the document in bytes.
+// Pretty is measured with the default two-space style, so it does not
+// depend on the saved format settings.
+type DocumentSize struct {
+	Source   int `json:"source"`
+	Minified int `json:"minified"`
+	Pretty   int `json:"pretty"`
+}
+
+// ArrayStat describes one array. Uniform is set when every element has
+// the same JSON type; empty arrays count as uniform.
+type ArrayStat struct {
+	Path         string   `json:"path"`
+	Length       int      `json:"length"`
+	Bytes        int      `json:"bytes"`
+	ElementTypes []string `json:"elementTypes"`
+	Uniform      bool     `json:"uniform"`
+}
+
+// StringStat describes one string value. Length counts characters and
+// Bytes the serialized size including quotes and escapes.
+type StringStat struct {
+	Path   string `json:"path"`
+	Length int    `json:"length"`
+	Bytes  int    `json:"bytes"`
+}
+
+// ValueStat is the minified size of a value in bytes
+type ValueStat struct {
+	Path  string `json:"path"`
+	Type  string `json:"type"`
+	Bytes int    `json:"bytes"`
+}
+
+// KeyStat totals the members with one key name across the document.
+// Bytes covers the key and its values as minified JSON.
+type KeyStat struct {
+	Key         string `json:"key"`
+	Occurrences int    `json:"occurrences"`
+	Bytes       int    `json:"bytes"`
+}
+
+// AnalyzeResult is the result of the analyze operation. Depth counts
+// nested containers, so a scalar document has depth 0.
+type AnalyzeResult struct {
+	Size           DocumentSize   `json:"size"`
+	MaxDepth       int            `json:"maxDepth"`
+	DeepestPath    string         `json:"deepestPath"`
+	Values         int            `json:"values"`
+	Types          map[string]int `json:"types"`
+	TotalKeys      int            `json:"totalKeys"`
+	UniqueKeys     int            `json:"uniqueKeys"`
+	UniformArrays  int            `json:"uniformArrays"`
+	MixedArrays    []ArrayStat    `json:"mixedArrays"`
+	LargestArrays  []ArrayStat    `json:"largestArrays"`
+	LargestStrings []StringStat   `json:"largestStrings"`
+	LargestValues  []ValueStat    `json:"largestValues"`
+	HeaviestKeys   []KeyStat      `json:"heaviestKeys"`
+}
+
+// runAnalyze reports statistics about the input document and where its
+// bytes go
+func runAnalyze(req OperationRequest) (interface{}, error) {
+	opts := AnalyzeOptions{Top: defaultAnalyzeTop}
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+	if opts.Top <= 0 {
+		return nil, fmt.Errorf("top must be positive")
+	}
+
+	root, err := parseTree(req.Input)
+	if err != nil {
+		return nil, fmt.Errorf("invalid input JSON: %w", err)
+	}
+	pretty, err := formatTokens(req.Input, defaultFormatStyle)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &analyzer{
+		result: AnalyzeResult{
+			Types:       make(map[string]int),
+			MixedArrays: []ArrayStat{},
+		},
+		keys: make(map[string]*KeyStat),
+	}
+	minified := a.visit(root, "", 0)
+	a.result.Size = DocumentSize{Source: len(req.Input), Minified: minified, Pretty: len(pretty)}
+	a.result.UniqueKeys = len(a.keys)
+
+	top := func(n int) int {
+		if n > opts.Top {
+			return opts.Top
+		}
+		return n
+	}
+
+	sort.SliceStable(a.arrays, func(i, j int) bool { return a.arrays[i].Length > a.arrays[j].Length })
+	a.result.LargestArrays = append([]ArrayStat{}, a.arrays[:top(len(a.arrays))]...)
+	for _, stat := range a.arrays {
+		if stat.Uniform {
+			a.result.UniformArrays++
+		} else if len(a.result.MixedArrays) < opts.Top {
+			a.result.MixedArrays = append(a.result.MixedArrays, stat)
+		}
+	}
+
+	sort.SliceStable(a.strings, func(i, j int) bool { return a.strings[i].Bytes > a.strings[j].Bytes })
+	a.result.LargestStrings = append([]StringStat{}, a.strings[:top(len(a.strings))]...)
+
+	sort.SliceStable(a.values, func(i, j int) bool { return a.values[i].Bytes > a.values[j].Bytes })
+	a.result.LargestValues = append([]ValueStat{}, a.values[:top(len(a.values))]...)
+
+	keys := make([]KeyStat, 0, len(a.keys))
+	for _, name := range a.keyOrder {
+		keys = append(keys, *a.keys[name])
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].Bytes > keys[j].Bytes })
+	a.result.HeaviestKeys = keys[:top(len(keys))]
+
+	return a.result, nil
+}
+
+// analyzer collects statistics in a single pass over the tree
+type analyzer struct {
+	result   AnalyzeResult
+	arrays   []ArrayStat
+	strings  []StringStat
+	values   []ValueStat
+	keys     map[string]*KeyStat
+	keyOrder []string
+}
+
+// visit records n and everything below it and returns the minified size
+// of n in bytes. depth is the number of containers enclosing n.
+func (a *analyzer) visit(n *node, path string, depth int) int {
+	a.result.Values++
+	a.result.Types[n.Kind.String()]++
+
+	if n.Kind == nodeObject || n.Kind == nodeArray {
+		depth++
+		if depth > a.result.MaxDepth {
+			a.result.MaxDepth = depth
+			a.result.DeepestPath = path
+		}
+	}
+
+	size := len(n.Raw)
+	switch n.Kind {
+	case nodeObject:
+		size = 2
+		for i, m := range n.Members {
+			valueSize := a.visit(m.Value, appendPointer(path, m.Key), depth)
+			memberSize := len(m.KeyRaw) + 1 + valueSize
+			size += memberSize
+			if i > 0 {
+				size++
+			}
+
+			a.result.TotalKeys++
+			stat, ok := a.keys[m.Key]
+			if !ok {
+				stat = &KeyStat{Key: m.Key}
+				a.keys[m.Key] = stat
+				a.keyOrder = append(a.keyOrder, m.Key)
+			}
+			stat.Occurrences++
+			stat.Bytes += memberSize
+		}
+	case nodeArray:
+		size = 2
+		seen := make(map[string]bool)
+		types := []string{}
+		for i, item := range n.Items {
+			size += a.visit(item, appendPointerIndex(path, i), depth)
+			if i > 0 {
+				size++
+			}
+			if kind := item.Kind.String(); !seen[kind] {
+				seen[kind] = true
+				types = append(types, kind)
+			}
+		}
+		sort.Strings(types)
+		a.arrays = append(a.arrays, ArrayStat{
+			Path:         path,
+			Length:       len(n.Items),
+			Bytes:        size,
+			ElementTypes: types,
+			Uniform:      len(types) <= 1,
+		})
+	case nodeString:
+		a.strings = append(a.strings, StringStat{
+			Path:   path,
+			Length: utf8.RuneCountInString(scalarText(n)),
+			Bytes:  len(n.Raw),
+		})
+	}
+
+	if path != "" && (n.Kind == nodeObject || n.Kind == nodeArray) {
+		a.values = append(a.values, ValueStat{Path: path, Type: n.Kind.String(), Bytes: size})
+	}
+	return size
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRunAnalyze(t *testing.T) {
+	input := `{"users": [{"id": 1, "name": "ann"}, {"id": 2, "name": "bob", "tags": ["a", 1]}], "note": "hello"}`
+	result := runForTest[AnalyzeResult](t, runAnalyze, input, `{"top": 2}`)
+
+	if result.Size.Source != len(input) || result.Size.Minified != len(compactForTest(t, input)) {
+		t.Errorf("size = %+v, want source %d and minified %d", result.Size, len(input), len(compactForTest(t, input)))
+	}
+	if result.MaxDepth != 4 || result.DeepestPath != "/users/1/tags" {
+		t.Errorf("depth = %d at %q, want 4 at /users/1/tags", result.MaxDepth, result.DeepestPath)
+	}
+	wantTypes := map[string]int{"object": 3, "array": 2, "number": 3, "string": 4}
+	if !reflect.DeepEqual(result.Types, wantTypes) || result.Values != 12 {
+		t.Errorf("types = %v (%d values), want %v (12 values)", result.Types, result.Values, wantTypes)
+	}
+	if result.TotalKeys != 7 || result.UniqueKeys != 5 {
+		t.Errorf("keys = %d total, %d unique; want 7 and 5", result.TotalKeys, result.UniqueKeys)
+	}
+	if result.UniformArrays != 1 || len(result.MixedArrays) != 1 || result.MixedArrays[0].Path != "/users/1/tags" {
+		t.Errorf("uniform = %d, mixed = %+v", result.UniformArrays, result.MixedArrays)
+	}
+	if got := result.MixedArrays[0].ElementTypes; !reflect.DeepEqual(got, []string{"number", "string"}) {
+		t.Errorf("mixed element types = %v", got)
+	}
+	if len(result.LargestStrings) != 2 || result.LargestStrings[0].Path != "/note" || result.LargestStrings[0].Length != 5 {
+		t.Errorf("largest strings = %+v", result.LargestStrings)
+	}
+	if len(result.LargestValues) != 2 || result.LargestValues[0].Path != "/users" {
+		t.Errorf("largest values = %+v", result.LargestValues)
+	}
+	if len(result.HeaviestKeys) != 2 || result.HeaviestKeys[0].Key != "users" {
+		t.Errorf("heaviest keys = %+v", result.HeaviestKeys)
+	}
+}
+
+func TestRunAnalyzeScalar(t *testing.T) {
+	result := runForTest[AnalyzeResult](t, runAnalyze, ` "x" `, `{}`)
+	if result.MaxDepth != 0 || result.Values != 1 || result.Size.Minified != 3 {
+		t.Errorf("result = %+v", result)
+	}
+	if result.LargestArrays == nil || result.LargestValues == nil || result.HeaviestKeys == nil {
+		t.Error("rankings should be empty lists, not null")
+	}
+}
+
+func TestRunAnalyzePrettySizeIgnoresSettings(t *testing.T) {
+	settingsMu.Lock()
+	saved, savedRetry := cachedSettings, settingsRetryAt
+	minified := FormatSettings{TabSize: 8, Minify: true, LineWidth: defaultLineWidth}
+	cachedSettings, settingsRetryAt = &minified, time.Time{}
+	settingsMu.Unlock()
+	t.Cleanup(func() {
+		settingsMu.Lock()
+		cachedSettings, settingsRetryAt = saved, savedRetry
+		settingsMu.Unlock()
+	})
+
+	input := `{"a":[1,2],"b":{"c":"d"}}`
+	pretty, err := formatTokens(input, defaultFormatStyle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := runForTest[AnalyzeResult](t, runAnalyze, input, `{}`)
+	if result.Size.Pretty != len(pretty) || result.Size.Pretty <= result.Size.Minified {
+		t.Errorf("pretty = %d, want %d (minified %d)", result.Size.Pretty, len(pretty), result.Size.Minified)
+	}
+}
+
+func TestRunAnalyzeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		options string
+	}{
+		{"invalid input", `{"a": }`, `{}`},
+		{"zero top", `{}`, `{"top": 0}`},
+		{"negative top", `{}`, `{"top": -1}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := runAnalyze(OperationRequest{Operation: "analyze", Input: tt.input, Options: []byte(tt.options)}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
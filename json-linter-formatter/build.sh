@@ -23,7 +23,7 @@ go mod tidy
 
 # 3. Build the backend for the current host OS/Arch
 echo "Building backend for ${GOOS}/${GOARCH}..."
-go build -o "./${OUTPUT_NAME}" main.go
+go build -o "./${OUTPUT_NAME}" .
 
 # 4. Add execute permission (for non-Windows builds)
 if [ "$GOOS" != "windows" ]; then
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// digestAlgorithms are the supported hash functions
+var digestAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+	"sha1":   sha1.New,
+	"md5":    md5.New,
+}
+
+// defaultDigestAlgorithms are computed when no algorithms are requested
+var defaultDigestAlgorithms = []string{"sha256", "sha384", "sha512"}
+
+// CanonicalizeOptions are the options of the canonicalize operation.
+// Compare is another document, inline or as JSON text, whose canonical
+// form is compared with the input's.
+type CanonicalizeOptions struct {
+	Algorithms []string        `json:"algorithms"`
+	Compare    json.RawMessage `json:"compare"`
+}
+
+// Digest is a hash of the canonical bytes
+type Digest struct {
+	Algorithm string `json:"algorithm"`
+	Hex       string `json:"hex"`
+	Base64URL string `json:"base64url"`
+}
+
+// CanonicalizeResult is the result of the canonicalize operation.
+// PrecisionLoss lists numbers whose canonical form differs in value from
+// the source because they do not fit an IEEE 754 double. Equal is set
+// only when a comparison document was given.
+type CanonicalizeResult struct {
+	Canonical     string   `json:"canonical"`
+	Bytes         int      `json:"bytes"`
+	Digests       []Digest `json:"digests"`
+	PrecisionLoss []string `json:"precisionLoss"`
+	Equal         *bool    `json:"equal,omitempty"`
+}
+
+// runCanonicalize outputs the RFC 8785 JSON Canonicalization Scheme form
+// of the input along with digests of it
+func runCanonicalize(req OperationRequest) (interface{}, error) {
+	var opts CanonicalizeOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+	algorithms := opts.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = defaultDigestAlgorithms
+	}
+	for _, name := range algorithms {
+		if digestAlgorithms[name] == nil {
+			return nil, fmt.Errorf("unsupported digest algorithm %q (supported: sha256, sha384, sha512, sha1, md5)", name)
+		}
+	}
+
+	canonical, lossy, err := canonicalJSON(req.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	result := CanonicalizeResult{
+		Canonical:     canonical,
+		Bytes:         len(canonical),
+		PrecisionLoss: lossy,
+	}
+	for _, name := range algorithms {
+		h := digestAlgorithms[name]()
+		h.Write([]byte(canonical))
+		sum := h.Sum(nil)
+		result.Digests = append(result.Digests, Digest{
+			Algorithm: name,
+			Hex:       hex.EncodeToString(sum),
+			Base64URL: base64.RawURLEncoding.EncodeToString(sum),
+		})
+	}
+
+	if opts.Compare != nil {
+		other, err := documentOption(opts.Compare, "compare")
+		if err != nil {
+			return nil, err
+		}
+		otherCanonical, _, err := canonicalJSON(other)
+		if err != nil {
+			return nil, fmt.Errorf("compare: %w", err)
+		}
+		equal := otherCanonical == canonical
+		result.Equal = &equal
+	}
+	return result, nil
+}
+
+// canonicalJSON parses src and serializes it per RFC 8785. It also
+// returns the pointers of numbers that lost precision.
+func canonicalJSON(src string) (string, []string, error) {
+	root, err := parseTree(src)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid input JSON: %w", err)
+	}
+	c := &canonicalizer{lossy: []string{}}
+	if err := c.write(root, ""); err != nil {
+		return "", nil, err
+	}
+	return c.b.String(), c.lossy, nil
+}
+
+// canonicalizer writes the canonical form of a tree
+type canonicalizer struct {
+	b     strings.Builder
+	lossy []string
+}
+
+func (c *canonicalizer) write(n *node, pointer string) error {
+	switch n.Kind {
+	case nodeObject:
+		members := append([]member(nil), n.Members...)
+		sort.SliceStable(members, func(i, j int) bool {
+			return lessUTF16(members[i].Key, members[j].Key)
+		})
+		c.b.WriteByte('{')
+		for i, m := range members {
+			child := appendPointer(pointer, m.Key)
+			if i > 0 {
+				if members[i-1].Key == m.Key {
+					return fmt.Errorf("duplicate key %q at %q cannot be canonicalized", m.Key, child)
+				}
+				c.b.WriteByte(',')
+			}
+			if err := checkUnicode(m.KeyRaw, child); err != nil {
+				return err
+			}
+			writeCanonicalString(&c.b, m.Key)
+			c.b.WriteByte(':')
+			if err := c.write(m.Value, child); err != nil {
+				return err
+			}
+		}
+		c.b.WriteByte('}')
+	case nodeArray:
+		c.b.WriteByte('[')
+		for i, item := range n.Items {
+			if i > 0 {
+				c.b.WriteByte(',')
+			}
+			if err := c.write(item, appendPointerIndex(pointer, i)); err != nil {
+				return err
+			}
+		}
+		c.b.WriteByte(']')
+	case nodeString:
+		if err := checkUnicode(n.Raw, pointer); err != nil {
+			return err
+		}
+		writeCanonicalString(&c.b, scalarText(n))
+	case nodeNumber:
+		f, err := strconv.ParseFloat(n.Raw, 64)
+		if err != nil {
+			return fmt.Errorf("number %s at %q is outside the IEEE 754 double range", n.Raw, pointer)
+		}
+		text := canonicalNumber(f)
+		source, _ := new(big.Rat).SetString(n.Raw)
+		if value, _ := new(big.Rat).SetString(text); source.Cmp(value) != 0 {
+			c.lossy = append(c.lossy, pointer)
+		}
+		c.b.WriteString(text)
+	default:
+		c.b.WriteString(n.Raw)
+	}
+	return nil
+}
+
+// lessUTF16 orders strings by their UTF-16 code units as RFC 8785
+// requires
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+// checkUnicode rejects invalid UTF-8 and unpaired surrogate escapes in a
+// raw JSON string, which have no canonical form
+func checkUnicode(raw, pointer string) error {
+	if !utf8.ValidString(raw) {
+		return fmt.Errorf("string at %q is not valid UTF-8", pointer)
+	}
+	surrogate := func(i int) (rune, bool) {
+		if i+6 > len(raw) || raw[i] != '\\' || raw[i+1] != 'u' {
+			return 0, false
+		}
+		code, err := strconv.ParseUint(raw[i+2:i+6], 16, 16)
+		return rune(code), err == nil
+	}
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '\\' {
+			continue
+		}
+		r, ok := surrogate(i)
+		if !ok {
+			i++
+			continue
+		}
+		switch {
+		case r >= 0xD800 && r < 0xDC00:
+			if low, ok := surrogate(i + 6); !ok || low < 0xDC00 || low > 0xDFFF {
+				return fmt.Errorf("string at %q contains an unpaired surrogate \\u%04x", pointer, r)
+			}
+			i += 11
+		case r >= 0xDC00 && r <= 0xDFFF:
+			return fmt.Errorf("string at %q contains an unpaired surrogate \\u%04x", pointer, r)
+		default:
+			i += 5
+		}
+	}
+	return nil
+}
+
+// writeCanonicalString writes s with the minimal escaping of RFC 8785
+func writeCanonicalString(b *strings.Builder, s string) {
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+}
+
+// canonicalNumber formats f as ECMAScript's Number.prototype.toString
+// does, which RFC 8785 adopts
+func canonicalNumber(f float64) string {
+	if f == 0 {
+		return "0"
+	}
+	sign := ""
+	if f < 0 {
+		sign, f = "-", -f
+	}
+
+	// Shortest round-trip digits and decimal exponent
+	e := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, exp, _ := strings.Cut(e, "e")
+	digits := strings.Replace(mantissa, ".", "", 1)
+	exponent, _ := strconv.Atoi(exp)
+	n, k := exponent+1, len(digits)
+
+	switch {
+	case k <= n && n <= 21:
+		return sign + digits + strings.Repeat("0", n-k)
+	case 0 < n && n <= 21:
+		return sign + digits[:n] + "." + digits[n:]
+	case -6 < n && n <= 0:
+		return sign + "0." + strings.Repeat("0", -n) + digits
+	}
+
+	result := digits[:1]
+	if k > 1 {
+		result += "." + digits[1:]
+	}
+	if n-1 >= 0 {
+		return sign + result + "e+" + strconv.Itoa(n-1)
+	}
+	return sign + result + "e" + strconv.Itoa(n-1)
+}
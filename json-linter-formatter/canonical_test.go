@@ -0,0 +1,129 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		lossy []string
+	}{
+		{
+			name:  "whitespace and key order",
+			input: "{ \"b\": [1, 2],\n  \"a\": {\"z\": null, \"y\": true} }",
+			want:  `{"a":{"y":true,"z":null},"b":[1,2]}`,
+		},
+		{
+			name:  "keys sort by UTF-16 code units",
+			input: `{"\ufb33": 7, "\ud83d\ude00": 6, "\u20ac": 5, "\u00f6": 4, "\u0080": 3, "1": 2, "\r": 1}`,
+			want:  "{\"\\r\":1,\"1\":2,\"\u0080\":3,\"\u00f6\":4,\"\u20ac\":5,\"\U0001F600\":6,\"\ufb33\":7}",
+		},
+		{
+			name:  "minimal string escaping",
+			input: `["\u0041\/\u00e9", "\u001f\b\t", "<&>"]`,
+			want:  "[\"A/\u00e9\",\"\\u001f\\b\\t\",\"<&>\"]",
+		},
+		{
+			name:  "numbers",
+			input: `[4.50, 2e-3, 1E30, -0, 0.000001, 1e-7, 1e20, 1e21, 333333333.33333329, 10.0]`,
+			want:  `[4.5,0.002,1e+30,0,0.000001,1e-7,100000000000000000000,1e+21,333333333.3333333,10]`,
+			lossy: []string{"/8"},
+		},
+		{
+			name:  "precision loss",
+			input: `{"id": 9007199254740993, "ok": 9007199254740992}`,
+			want:  `{"id":9007199254740992,"ok":9007199254740992}`,
+			lossy: []string{"/id"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, lossy, err := canonicalJSON(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("canonical = %s, want %s", got, tt.want)
+			}
+			want := tt.lossy
+			if want == nil {
+				want = []string{}
+			}
+			if !reflect.DeepEqual(lossy, want) {
+				t.Errorf("precision loss = %v, want %v", lossy, want)
+			}
+		})
+	}
+}
+
+func TestCanonicalJSONErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"invalid JSON", `{"a": }`, "invalid input JSON"},
+		{"duplicate keys", `{"a": 1, "a": 2}`, `duplicate key "a"`},
+		{"unpaired high surrogate", `["\ud83d"]`, "unpaired surrogate"},
+		{"unpaired low surrogate", `{"\ude00": 1}`, "unpaired surrogate"},
+		{"number out of range", `[1e400]`, "IEEE 754"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := canonicalJSON(tt.input)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunCanonicalize(t *testing.T) {
+	input := `{"b": [true, null], "a": 1}`
+	value, err := runCanonicalize(OperationRequest{Operation: "canonicalize", Input: input, Options: []byte(`{"algorithms": ["sha256", "md5"], "compare": {"a": 1.0, "b": [true, null]}}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := value.(CanonicalizeResult)
+
+	if result.Canonical != `{"a":1,"b":[true,null]}` || result.Bytes != len(result.Canonical) {
+		t.Errorf("canonical = %s (%d bytes)", result.Canonical, result.Bytes)
+	}
+	want := []Digest{
+		{Algorithm: "sha256", Hex: "1cc69c7fa23616ca2ec3ee70d24390a6225c8832db8a4c814c7e0e7f942f8668", Base64URL: "HMacf6I2Fsouw-5w0kOQpiJciDLbikyBTH4Of5Qvhmg"},
+		{Algorithm: "md5", Hex: "9264c02a0f4163be44ebc9264e797413", Base64URL: "kmTAKg9BY75E68kmTnl0Ew"},
+	}
+	if !reflect.DeepEqual(result.Digests, want) {
+		t.Errorf("digests = %+v, want %+v", result.Digests, want)
+	}
+	if result.Equal == nil || !*result.Equal {
+		t.Errorf("equal = %v, want true", result.Equal)
+	}
+}
+
+func TestRunCanonicalizeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		options string
+	}{
+		{"unknown algorithm", `{}`, `{"algorithms": ["crc32"]}`},
+		{"invalid compare document", `{}`, `{"compare": "{\"a\": }"}`},
+		{"invalid input", `[`, `{}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := runCanonicalize(OperationRequest{Operation: "canonicalize", Input: tt.input, Options: []byte(tt.options)}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
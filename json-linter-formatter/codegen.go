@@ -0,0 +1,410 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/format"
+	goToken "go/token"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Code generation languages
+const (
+	languageGo         = "go"
+	languageTypeScript = "typescript"
+)
+
+// commonInitialisms are written in upper case in Go identifiers
+var commonInitialisms = map[string]bool{
+	"ACL": true, "API": true, "ASCII": true, "CPU": true, "CSS": true,
+	"DNS": true, "EOF": true, "GUID": true, "HTML": true, "HTTP": true,
+	"HTTPS": true, "ID": true, "IP": true, "JSON": true, "QPS": true,
+	"RAM": true, "RPC": true, "SLA": true, "SMTP": true, "SQL": true,
+	"SSH": true, "TCP": true, "TLS": true, "TTL": true, "UDP": true,
+	"UI": true, "UID": true, "UUID": true, "URI": true, "URL": true,
+	"UTF8": true, "VM": true, "XML": true, "XSRF": true, "XSS": true,
+}
+
+// tsIdentifier matches property names TypeScript accepts unquoted
+var tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// GenerateTypesOptions are the options of the generateTypes operation.
+// Language is "go", "typescript" or empty for both. Package adds a package
+// clause to the Go output.
+type GenerateTypesOptions struct {
+	Language string `json:"language"`
+	RootName string `json:"rootName"`
+	Package  string `json:"package"`
+}
+
+// GenerateTypesResult is the result of the generateTypes operation
+type GenerateTypesResult struct {
+	Go         string `json:"go,omitempty"`
+	TypeScript string `json:"typescript,omitempty"`
+}
+
+// typeGenerator names and renders the types of one document. Object shapes
+// become named declarations, emitted parents first.
+type typeGenerator struct {
+	language string
+	indent   string
+	decls    []string
+	// signatures maps declared names to their shape signatures
+	signatures map[string]string
+	needsTime  bool
+	needsJSON  bool
+}
+
+// runGenerateTypes turns the input document into Go structs and
+// TypeScript interfaces
+func runGenerateTypes(req OperationRequest) (interface{}, error) {
+	var opts GenerateTypesOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+	switch opts.Language {
+	case "", languageGo, languageTypeScript:
+	default:
+		return nil, fmt.Errorf("invalid language %q (expected go or typescript)", opts.Language)
+	}
+	if opts.Package != "" && !goToken.IsIdentifier(opts.Package) {
+		return nil, fmt.Errorf("invalid package name %q", opts.Package)
+	}
+
+	rootName := exportedName(opts.RootName)
+	if opts.RootName == "" {
+		rootName = "Root"
+	}
+
+	root, err := parseTree(req.Input)
+	if err != nil {
+		return nil, fmt.Errorf("invalid input JSON: %w", err)
+	}
+	s := newShape()
+	s.observe(root, 0)
+
+	var result GenerateTypesResult
+	if opts.Language != languageTypeScript {
+		if result.Go, err = generateGo(s, rootName, opts.Package); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Language != languageGo {
+		result.TypeScript = generateTypeScript(s, rootName)
+	}
+	return result, nil
+}
+
+// generateGo renders the shape as Go type declarations
+func generateGo(s *shape, rootName, pkg string) (string, error) {
+	g := &typeGenerator{language: languageGo, signatures: make(map[string]string)}
+	g.declareRoot(s, rootName)
+
+	var b strings.Builder
+	if pkg != "" {
+		fmt.Fprintf(&b, "package %s\n\n", pkg)
+	}
+	var imports []string
+	if g.needsJSON {
+		imports = append(imports, `"encoding/json"`)
+	}
+	if g.needsTime {
+		imports = append(imports, `"time"`)
+	}
+	switch len(imports) {
+	case 0:
+	case 1:
+		fmt.Fprintf(&b, "import %s\n\n", imports[0])
+	default:
+		fmt.Fprintf(&b, "import (\n\t%s\n)\n\n", strings.Join(imports, "\n\t"))
+	}
+	b.WriteString(strings.Join(g.decls, "\n"))
+
+	src, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return "", fmt.Errorf("failed to format generated Go: %w", err)
+	}
+	return string(src), nil
+}
+
+// generateTypeScript renders the shape as TypeScript declarations
+func generateTypeScript(s *shape, rootName string) string {
+	indent := currentFormatSettings().style().Indent
+	if indent == "" {
+		indent = "  "
+	}
+	g := &typeGenerator{language: languageTypeScript, indent: indent, signatures: make(map[string]string)}
+	g.declareRoot(s, rootName)
+	return strings.Join(g.decls, "\n")
+}
+
+// declareRoot declares the type of the document itself
+func (g *typeGenerator) declareRoot(s *shape, name string) {
+	if kinds := s.kinds(); len(kinds) == 1 && kinds[0] == "object" && s.types["null"] == 0 {
+		g.objectType(s, name)
+		return
+	}
+
+	index := g.reserve(name, "")
+	typ := g.typeOf(s, name, false)
+	if g.language == languageGo {
+		g.decls[index] = fmt.Sprintf("type %s %s\n", name, typ)
+	} else {
+		g.decls[index] = fmt.Sprintf("export type %s = %s;\n", name, typ)
+	}
+}
+
+// reserve claims a declaration slot for name so parents precede the types
+// they use
+func (g *typeGenerator) reserve(name, signature string) int {
+	g.signatures[name] = signature
+	g.decls = append(g.decls, "")
+	return len(g.decls) - 1
+}
+
+// objectType declares a named type for an object shape and returns its
+// name. Repeated shapes under the same name share one declaration; a
+// different shape gets a numbered name.
+func (g *typeGenerator) objectType(s *shape, hint string) string {
+	data, _ := json.Marshal(s.schema(0))
+	signature := string(data)
+
+	name := hint
+	for i := 2; ; i++ {
+		existing, taken := g.signatures[name]
+		if !taken {
+			break
+		}
+		if existing == signature {
+			return name
+		}
+		name = hint + strconv.Itoa(i)
+	}
+	index := g.reserve(name, signature)
+
+	var b strings.Builder
+	if g.language == languageGo {
+		fmt.Fprintf(&b, "type %s struct {\n", name)
+		used := make(map[string]bool)
+		for _, key := range s.propOrder {
+			field := uniqueName(exportedName(key), used)
+			optional := s.propSeen[key] < s.objects
+			tag := key
+			if optional {
+				tag += ",omitempty"
+			}
+			fmt.Fprintf(&b, "\t%s %s `json:%q`\n", field, g.typeOf(s.props[key], field, optional), tag)
+		}
+		b.WriteString("}\n")
+	} else {
+		fmt.Fprintf(&b, "export interface %s {\n", name)
+		for _, key := range s.propOrder {
+			property := key
+			if !tsIdentifier.MatchString(key) {
+				quoted, _ := json.Marshal(key)
+				property = string(quoted)
+			}
+			if s.propSeen[key] < s.objects {
+				property += "?"
+			}
+			fmt.Fprintf(&b, "%s%s: %s;\n", g.indent, property, g.typeOf(s.props[key], exportedName(key), false))
+		}
+		b.WriteString("}\n")
+	}
+	g.decls[index] = b.String()
+	return name
+}
+
+// typeOf returns the type expression for a shape, declaring any object
+// types it needs under names derived from hint
+func (g *typeGenerator) typeOf(s *shape, hint string, optional bool) string {
+	nullable := s.types["null"] > 0
+	kinds := s.kinds()
+
+	if g.language == languageTypeScript {
+		var members []string
+		for _, kind := range kinds {
+			members = append(members, g.tsKind(s, kind, hint))
+		}
+		if nullable {
+			members = append(members, "null")
+		}
+		if len(members) == 0 {
+			return "unknown"
+		}
+		return strings.Join(members, " | ")
+	}
+
+	if len(kinds) != 1 {
+		return "interface{}"
+	}
+	typ := g.goKind(s, kinds[0], hint)
+	switch kinds[0] {
+	case "array":
+		return typ
+	case "object":
+		if nullable || optional {
+			return "*" + typ
+		}
+		return typ
+	}
+	if nullable {
+		return "*" + typ
+	}
+	return typ
+}
+
+// goKind returns the Go type for one kind of value in the shape
+func (g *typeGenerator) goKind(s *shape, kind, hint string) string {
+	switch kind {
+	case "object":
+		return g.objectType(s, hint)
+	case "array":
+		if s.items == nil {
+			return "[]interface{}"
+		}
+		return "[]" + g.typeOf(s.items, singularName(hint), false)
+	case "string":
+		if s.formatCount["date-time"] == s.strings {
+			g.needsTime = true
+			return "time.Time"
+		}
+		return "string"
+	case "integer":
+		return g.integerType(s)
+	case "number":
+		return "float64"
+	default:
+		return "bool"
+	}
+}
+
+// tsKind returns the TypeScript type for one kind of value in the shape
+func (g *typeGenerator) tsKind(s *shape, kind, hint string) string {
+	switch kind {
+	case "object":
+		return g.objectType(s, hint)
+	case "array":
+		if s.items == nil {
+			return "unknown[]"
+		}
+		item := g.typeOf(s.items, singularName(hint), false)
+		if strings.Contains(item, " ") {
+			return "(" + item + ")[]"
+		}
+		return item + "[]"
+	case "integer":
+		if !isSafeInteger(s.minInt) || !isSafeInteger(s.maxInt) {
+			return "number /* exceeds Number.MAX_SAFE_INTEGER */"
+		}
+		return "number"
+	case "number":
+		return "number"
+	default:
+		return kind
+	}
+}
+
+// integerType returns the Go type for the integers in the shape: int when
+// they fit 32 bits, then int64 or uint64, and json.Number beyond those
+func (g *typeGenerator) integerType(s *shape) string {
+	switch {
+	case s.minInt.IsInt64() && s.maxInt.IsInt64() && s.minInt.Int64() >= math.MinInt32 && s.maxInt.Int64() <= math.MaxInt32:
+		return "int"
+	case s.minInt.IsInt64() && s.maxInt.IsInt64():
+		return "int64"
+	case s.minInt.Sign() >= 0 && s.maxInt.IsUint64():
+		return "uint64"
+	}
+	g.needsJSON = true
+	return "json.Number"
+}
+
+// isSafeInteger reports whether a JavaScript number holds i exactly
+func isSafeInteger(i *big.Int) bool {
+	return new(big.Rat).SetInt(new(big.Int).Abs(i)).Cmp(maxSafeInteger) <= 0
+}
+
+// kinds returns the non-null kinds observed in the shape, with integers
+// folded into numbers when both occur
+func (s *shape) kinds() []string {
+	var kinds []string
+	for _, t := range []string{"object", "array", "string", "integer", "number", "boolean"} {
+		if s.types[t] == 0 || (t == "integer" && s.types["number"] > 0) {
+			continue
+		}
+		kinds = append(kinds, t)
+	}
+	return kinds
+}
+
+// exportedName converts a JSON key into an exported Go identifier, e.g.
+// "html_url" becomes "HTMLURL" and "userId" becomes "UserID"
+func exportedName(key string) string {
+	var parts []string
+	var current []rune
+	flush := func() {
+		if len(current) > 0 {
+			parts = append(parts, string(current))
+			current = current[:0]
+		}
+	}
+	var prev rune
+	for _, r := range key {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+		case unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev)):
+			flush()
+			current = append(current, r)
+		default:
+			current = append(current, r)
+		}
+		prev = r
+	}
+	flush()
+
+	var b strings.Builder
+	for _, part := range parts {
+		if upper := strings.ToUpper(part); commonInitialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+
+	name := b.String()
+	if name == "" || unicode.IsDigit([]rune(name)[0]) {
+		name = "Field" + name
+	}
+	return name
+}
+
+// singularName derives an element type name from a collection name
+func singularName(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 3:
+		return name[:len(name)-3] + "y"
+	case strings.HasSuffix(name, "ss"):
+	case strings.HasSuffix(name, "s") && len(name) > 1:
+		return name[:len(name)-1]
+	}
+	return name + "Item"
+}
+
+// uniqueName returns name, numbered if it is already used
+func uniqueName(name string, used map[string]bool) string {
+	unique := name
+	for i := 2; used[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	used[unique] = true
+	return unique
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunGenerateTypes(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		options string
+		wantGo  []string
+		wantTS  []string
+	}{
+		{
+			name:    "integers and numbers",
+			input:   `{"count": 3, "ratio": 1.5, "whole": 1.0, "big": 1e3}`,
+			options: `{}`,
+			wantGo:  []string{"Count int     `json:\"count\"`", "Ratio float64 `json:\"ratio\"`", "Whole float64 `json:\"whole\"`", "Big   float64 `json:\"big\"`"},
+			wantTS:  []string{"count: number;", "whole: number;", "big: number;"},
+		},
+		{
+			name:    "integer ranges",
+			input:   `{"small": -5, "wide": 12345678901, "neg": -12345678901, "big": 12345678901234567890, "huge": 123456789012345678901234567890, "safe": 9007199254740991}`,
+			options: `{}`,
+			wantGo:  []string{"import \"encoding/json\"", "Small int ", "Wide  int64 ", "Neg   int64 ", "Big   uint64 ", "Huge  json.Number ", "Safe  int64 "},
+			wantTS:  []string{"small: number;", "wide: number;", "big: number /* exceeds Number.MAX_SAFE_INTEGER */;", "huge: number /* exceeds Number.MAX_SAFE_INTEGER */;", "safe: number;"},
+		},
+		{
+			name:    "nested objects and arrays",
+			input:   `{"user_id": 1, "tags": ["a"], "owner": {"html_url": "x"}, "items": [{"id": 1}]}`,
+			options: `{"language": "go"}`,
+			wantGo:  []string{"UserID int", "Tags   []string", "Owner  Owner", "Items  []Item", "type Owner struct", "HTMLURL string", "type Item struct"},
+		},
+		{
+			name:    "optional and nullable members",
+			input:   `[{"a": 1, "b": null}, {"b": "x"}]`,
+			options: `{"rootName": "rows"}`,
+			wantGo:  []string{"type Rows []Row", "A int     `json:\"a,omitempty\"`", "B *string `json:\"b\"`"},
+			wantTS:  []string{"export type Rows = Row[];", "a?: number;", "b: string | null;"},
+		},
+		{
+			name:    "mixed kinds",
+			input:   `{"v": [1, "a"], "n": [1, 2.5]}`,
+			options: `{}`,
+			wantGo:  []string{"V []interface{}", "N []float64"},
+			wantTS:  []string{"v: (string | number)[];", "n: number[];"},
+		},
+		{
+			name:    "date-time strings",
+			input:   `{"at": "2024-01-02T03:04:05Z"}`,
+			options: `{"language": "go", "package": "model"}`,
+			wantGo:  []string{"package model", "import \"time\"", "At time.Time"},
+		},
+		{
+			name:    "integers too wide for Go integers alongside date-times",
+			input:   `{"at": "2024-01-02T03:04:05Z", "n": [1, -18446744073709551616]}`,
+			options: `{"language": "go"}`,
+			wantGo:  []string{"import (\n\t\"encoding/json\"\n\t\"time\"\n)", "N  []json.Number"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runForTest[GenerateTypesResult](t, runGenerateTypes, tt.input, tt.options)
+			for _, want := range tt.wantGo {
+				if !strings.Contains(result.Go, want) {
+					t.Errorf("Go output missing %q:\n%s", want, result.Go)
+				}
+			}
+			for _, want := range tt.wantTS {
+				if !strings.Contains(result.TypeScript, want) {
+					t.Errorf("TypeScript output missing %q:\n%s", want, result.TypeScript)
+				}
+			}
+		})
+	}
+}
+
+func TestRunGenerateTypesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		options string
+	}{
+		{"invalid input", `{"a": }`, `{}`},
+		{"unknown language", `{}`, `{"language": "rust"}`},
+		{"invalid package", `{}`, `{"package": "my-pkg"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := runGenerateTypes(OperationRequest{Operation: "generateTypes", Input: tt.input, Options: []byte(tt.options)}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestExportedName(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"name", "Name"},
+		{"html_url", "HTMLURL"},
+		{"userId", "UserID"},
+		{"first-name", "FirstName"},
+		{"2fa", "Field2fa"},
+		{"", "Field"},
+	}
+
+	for _, tt := range tests {
+		if got := exportedName(tt.key); got != tt.want {
+			t.Errorf("exportedName(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+// TestGeneratedGoDecodesSample compiles the generated types and decodes the
+// sample they were generated from, so every field can hold its values
+func TestGeneratedGoDecodesSample(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not available")
+	}
+
+	sample := `{
+  "id": 7,
+  "price": 1.0,
+  "limit": 1e3,
+  "ratio": 0.25,
+  "user_id": 12345678901,
+  "big": 12345678901234567890,
+  "huge": -123456789012345678901234567890,
+  "created": "2024-01-02T03:04:05Z",
+  "tags": ["a", "b"],
+  "owner": {"name": "x", "email": null},
+  "items": [{"sku": "a", "qty": 1}, {"sku": "b", "qty": 2.5, "note": "n"}]
+}`
+	result := runForTest[GenerateTypesResult](t, runGenerateTypes, sample, `{"language": "go", "package": "main"}`)
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":      "module generated\n\ngo 1.18\n",
+		"types.go":    result.Go,
+		"sample.json": sample,
+		"main.go": `package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+func main() {
+	data, err := os.ReadFile("sample.json")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	var root Root
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&root); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(goTool, "run", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off", "GOPROXY=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("decoding the sample into the generated types failed: %v\n%s\ngenerated:\n%s", err, out, result.Go)
+	}
+}
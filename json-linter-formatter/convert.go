@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Conversion formats
+const (
+	formatJSON = "json"
+	formatYAML = "yaml"
+	formatTOML = "toml"
+	formatCSV  = "csv"
+	formatXML  = "xml"
+)
+
+// maxConversionWarnings caps the warnings reported by one conversion
+const maxConversionWarnings = 100
+
+// ConvertOptions are the options of the convert operation. From and To
+// default to "json"; any supported format can be converted to any other
+// through the JSON document model.
+type ConvertOptions struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Strict fails the conversion instead of reporting warnings when a
+	// value cannot be represented in the target format
+	Strict bool `json:"strict"`
+	// Delimiter is the CSV field separator, "," by default
+	Delimiter string `json:"delimiter"`
+	// Unflatten turns dotted CSV column names back into nested objects
+	Unflatten bool `json:"unflatten"`
+	// KeepStrings stops CSV and XML input values from being read as
+	// numbers, booleans and nulls
+	KeepStrings bool `json:"keepStrings"`
+	// RootElement names the XML root when the document has no single
+	// top-level key, "root" by default
+	RootElement string `json:"rootElement"`
+}
+
+// ConversionWarning describes data that could not be carried over exactly.
+// Pointer locates the value in the JSON document model; Line locates it in
+// a non-JSON input.
+type ConversionWarning struct {
+	Pointer string `json:"pointer,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+// ConvertResult is the result of the convert operation. WarningCount
+// includes warnings beyond those listed.
+type ConvertResult struct {
+	From         string              `json:"from"`
+	To           string              `json:"to"`
+	Output       string              `json:"output"`
+	Warnings     []ConversionWarning `json:"warnings"`
+	WarningCount int                 `json:"warningCount"`
+}
+
+// conversion carries the options and collected warnings of one run
+type conversion struct {
+	opts     ConvertOptions
+	warnings []ConversionWarning
+	count    int
+}
+
+// warn records a value that could not be represented
+func (c *conversion) warn(pointer string, line int, format string, args ...interface{}) {
+	c.count++
+	if len(c.warnings) < maxConversionWarnings {
+		c.warnings = append(c.warnings, ConversionWarning{
+			Pointer: pointer,
+			Line:    line,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+}
+
+// runConvert converts the input between JSON, YAML, TOML, CSV and XML
+func runConvert(req OperationRequest) (interface{}, error) {
+	var opts ConvertOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+	opts.From = strings.ToLower(opts.From)
+	opts.To = strings.ToLower(opts.To)
+	if opts.From == "" {
+		opts.From = formatJSON
+	}
+	if opts.To == "" {
+		opts.To = formatJSON
+	}
+	for _, format := range []string{opts.From, opts.To} {
+		switch format {
+		case formatJSON, formatYAML, formatTOML, formatCSV, formatXML:
+		default:
+			return nil, fmt.Errorf("unsupported format %q (expected json, yaml, toml, csv or xml)", format)
+		}
+	}
+	if opts.Delimiter == "" {
+		opts.Delimiter = ","
+	}
+	if len([]rune(opts.Delimiter)) != 1 {
+		return nil, fmt.Errorf("delimiter must be a single character")
+	}
+	if opts.RootElement == "" {
+		opts.RootElement = "root"
+	}
+	if !validXMLName(opts.RootElement) {
+		return nil, fmt.Errorf("invalid root element name %q", opts.RootElement)
+	}
+
+	c := &conversion{opts: opts}
+	doc, err := c.read(req.Input)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s input: %w", strings.ToUpper(opts.From), err)
+	}
+	output, err := c.write(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Strict && c.count > 0 {
+		first := c.warnings[0]
+		location := first.Pointer
+		if first.Line > 0 {
+			location = fmt.Sprintf("line %d", first.Line)
+		}
+		return nil, fmt.Errorf("%d value(s) cannot be represented in %s; first at %q: %s",
+			c.count, strings.ToUpper(opts.To), location, first.Message)
+	}
+
+	warnings := c.warnings
+	if warnings == nil {
+		warnings = []ConversionWarning{}
+	}
+	return ConvertResult{
+		From:         opts.From,
+		To:           opts.To,
+		Output:       output,
+		Warnings:     warnings,
+		WarningCount: c.count,
+	}, nil
+}
+
+// read parses the input into the JSON document model
+func (c *conversion) read(input string) (*node, error) {
+	switch c.opts.From {
+	case formatYAML:
+		return c.readYAML(input)
+	case formatTOML:
+		return c.readTOML(input)
+	case formatCSV:
+		return c.readCSV(input)
+	case formatXML:
+		return c.readXML(input)
+	default:
+		return parseTree(input)
+	}
+}
+
+// write renders the document in the target format
+func (c *conversion) write(doc *node) (string, error) {
+	switch c.opts.To {
+	case formatYAML:
+		return c.writeYAML(doc), nil
+	case formatTOML:
+		return c.writeTOML(doc)
+	case formatCSV:
+		return c.writeCSV(doc)
+	case formatXML:
+		return c.writeXML(doc)
+	default:
+		return formatTokens(doc.compact(), currentFormatSettings().style())
+	}
+}
+
+// scalarText returns the text of a scalar node, unquoting strings
+func scalarText(n *node) string {
+	if n.Kind == nodeString {
+		s, _ := n.value().(string)
+		return s
+	}
+	return n.Raw
+}
+
+// inferScalar reads text as a JSON number, boolean or null when it is
+// spelled exactly that way, and as a string otherwise
+func inferScalar(text string) *node {
+	switch text {
+	case "true", "false":
+		return &node{Kind: nodeBool, Raw: text}
+	case "null":
+		return nullNode()
+	}
+	if isStrictNumber(text) {
+		return &node{Kind: nodeNumber, Raw: text}
+	}
+	return stringNode(text)
+}
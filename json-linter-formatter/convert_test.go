@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// warningPointers lists the pointers of conversion warnings in order
+func warningPointers(warnings []ConversionWarning) []string {
+	pointers := []string{}
+	for _, w := range warnings {
+		pointers = append(pointers, w.Pointer)
+	}
+	return pointers
+}
+
+// roundTripForTest converts doc from JSON to format and back, returning
+// the intermediate text and the compact JSON it reads back as
+func roundTripForTest(t *testing.T, doc, format string) (string, string) {
+	t.Helper()
+	out := runForTest[ConvertResult](t, runConvert, doc, `{"to": "`+format+`"}`)
+	if len(out.Warnings) > 0 {
+		t.Fatalf("unexpected warnings writing %s: %+v", format, out.Warnings)
+	}
+	back := runForTest[ConvertResult](t, runConvert, out.Output, `{"from": "`+format+`"}`)
+	if len(back.Warnings) > 0 {
+		t.Fatalf("unexpected warnings reading %s: %+v", format, back.Warnings)
+	}
+	return out.Output, compactForTest(t, back.Output)
+}
+
+func TestRunConvertErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		options string
+		want    string
+	}{
+		{"unknown format", `{}`, `{"to": "ini"}`, "unsupported format"},
+		{"long delimiter", `[]`, `{"to": "csv", "delimiter": ";;"}`, "single character"},
+		{"bad root element", `[]`, `{"to": "xml", "rootElement": "1root"}`, "invalid root element"},
+		{"invalid input", `{"a": }`, `{"to": "yaml"}`, "invalid JSON input"},
+		{"strict with warnings", `{"a": null}`, `{"to": "toml", "strict": true}`, `first at "/a"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runConvert(OperationRequest{Operation: "convert", Input: tt.input, Options: []byte(tt.options)})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunConvertCapsWarnings(t *testing.T) {
+	items := strings.Repeat(`{"a": null}, `, maxConversionWarnings+5)
+	result := runForTest[ConvertResult](t, runConvert, `{"rows": [`+items+`{}]}`, `{"to": "toml"}`)
+	if len(result.Warnings) != maxConversionWarnings || result.WarningCount != maxConversionWarnings+5 {
+		t.Errorf("got %d warnings of %d, want %d of %d", len(result.Warnings), result.WarningCount, maxConversionWarnings, maxConversionWarnings+5)
+	}
+}
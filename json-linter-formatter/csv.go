@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// csvRow is one flattened record, keeping columns in first-seen order
+type csvRow struct {
+	columns []string
+	cells   map[string]string
+}
+
+// csvShape records how a column path was first written: as a single cell,
+// or spread over nested object or array columns
+type csvShape struct {
+	kind    string
+	pointer string
+}
+
+// writeCSV renders an array of objects as CSV, one row per object.
+// Nested objects and arrays are flattened into dotted column names such as
+// "owner.login" and "tags.0".
+func (c *conversion) writeCSV(doc *node) (string, error) {
+	items := []*node{doc}
+	if doc.Kind == nodeArray {
+		items = doc.Items
+	} else if doc.Kind != nodeObject {
+		return "", fmt.Errorf("CSV output needs an array of objects, but the input is a %s", doc.Kind)
+	}
+
+	var header []string
+	known := make(map[string]bool)
+	shapes := make(map[string]csvShape)
+	var rows []csvRow
+	for i, item := range items {
+		pointer := ""
+		if doc.Kind == nodeArray {
+			pointer = appendPointerIndex("", i)
+		}
+		if item.Kind != nodeObject {
+			c.warn(pointer, 0, "a %s cannot be written as a CSV row; it was left out", item.Kind)
+			continue
+		}
+		row := csvRow{cells: make(map[string]string)}
+		c.flattenCSV(&row, shapes, "", item, pointer)
+		for _, column := range row.columns {
+			if !known[column] {
+				known[column] = true
+				header = append(header, column)
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Comma = []rune(c.opts.Delimiter)[0]
+	if len(header) > 0 {
+		w.Write(header)
+	}
+	for _, row := range rows {
+		record := make([]string, len(header))
+		for i, column := range header {
+			record[i] = row.cells[column]
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	return b.String(), w.Error()
+}
+
+// flattenCSV adds the scalar leaves of n to row under dotted column names.
+// shapes tracks each column path across rows so that a value written as a
+// cell in one row and as nested columns in another is reported.
+func (c *conversion) flattenCSV(row *csvRow, shapes map[string]csvShape, column string, n *node, pointer string) {
+	join := func(key string) string {
+		if column == "" {
+			return key
+		}
+		return column + "." + key
+	}
+
+	kind := "a single value"
+	switch {
+	case n.Kind == nodeObject && len(n.Members) > 0:
+		kind = "an object"
+	case n.Kind == nodeArray && len(n.Items) > 0:
+		kind = "an array"
+	}
+	if column != "" {
+		if first, seen := shapes[column]; !seen {
+			shapes[column] = csvShape{kind: kind, pointer: pointer}
+		} else if first.kind != kind {
+			c.warn(pointer, 0, "column %q holds %s here but %s at %s; their cells do not line up", column, kind, first.kind, first.pointer)
+		}
+	}
+
+	switch kind {
+	case "an object":
+		for _, m := range n.Members {
+			c.flattenCSV(row, shapes, join(m.Key), m.Value, appendPointer(pointer, m.Key))
+		}
+		return
+	case "an array":
+		for i, item := range n.Items {
+			c.flattenCSV(row, shapes, join(fmt.Sprint(i)), item, appendPointerIndex(pointer, i))
+		}
+		return
+	}
+
+	if _, taken := row.cells[column]; taken {
+		c.warn(pointer, 0, "column %q is produced by more than one value; the last one is kept", column)
+	} else {
+		row.columns = append(row.columns, column)
+	}
+	switch n.Kind {
+	case nodeNull:
+		c.warn(pointer, 0, "null cannot be told apart from an empty string in CSV; written as an empty cell")
+		row.cells[column] = ""
+	case nodeObject, nodeArray:
+		c.warn(pointer, 0, "empty %s written as the text %s", n.Kind, n.compact())
+		row.cells[column] = n.compact()
+	default:
+		row.cells[column] = scalarText(n)
+	}
+}
+
+// readCSV parses CSV with a header row into an array of objects. Cells
+// are read as numbers, booleans and nulls (for empty cells) unless
+// KeepStrings is set.
+func (c *conversion) readCSV(input string) (*node, error) {
+	r := csv.NewReader(strings.NewReader(input))
+	r.Comma = []rune(c.opts.Delimiter)[0]
+
+	rows := &node{Kind: nodeArray}
+	var header []string
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := r.FieldPos(0)
+
+		if header == nil {
+			header = record
+			seen := make(map[string]bool)
+			for i, name := range header {
+				if name == "" {
+					header[i] = fmt.Sprintf("column%d", i+1)
+					c.warn("", line, "column %d has no name; using %q", i+1, header[i])
+				}
+				if seen[header[i]] {
+					c.warn("", line, "column %q appears more than once; the last value is used", header[i])
+				}
+				seen[header[i]] = true
+			}
+			continue
+		}
+
+		obj := &node{Kind: nodeObject}
+		for i, cell := range record {
+			value := stringNode(cell)
+			if !c.opts.KeepStrings {
+				value = inferScalar(cell)
+				if cell == "" {
+					value = nullNode()
+				}
+			}
+			if !c.opts.Unflatten {
+				setMember(obj, header[i], value)
+				continue
+			}
+			if err := setDotted(obj, strings.Split(header[i], "."), value); err != nil {
+				c.warn("", line, "column %q: %v; the value was left out", header[i], err)
+			}
+		}
+		rows.Items = append(rows.Items, obj)
+	}
+}
+
+// setDotted sets value at the nested object path, creating objects on the
+// way
+func setDotted(obj *node, path []string, value *node) error {
+	for i, key := range path[:len(path)-1] {
+		index := memberIndex(obj, key)
+		if index < 0 {
+			next := &node{Kind: nodeObject}
+			setMember(obj, key, next)
+			obj = next
+			continue
+		}
+		if obj.Members[index].Value.Kind != nodeObject {
+			return fmt.Errorf("%q already holds a value", strings.Join(path[:i+1], "."))
+		}
+		obj = obj.Members[index].Value
+	}
+	last := path[len(path)-1]
+	if index := memberIndex(obj, last); index >= 0 && obj.Members[index].Value.Kind == nodeObject {
+		return fmt.Errorf("%q already holds nested columns", strings.Join(path, "."))
+	}
+	setMember(obj, last, value)
+	return nil
+}
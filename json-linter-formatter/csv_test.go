@@ -0,0 +1,132 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWriteCSV(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		options  string
+		want     string
+		warnings []string
+	}{
+		{
+			name:  "rows with flattened columns",
+			input: `[{"id":1,"owner":{"login":"a"},"tags":["x","y"]},{"id":2,"extra":"q,r"}]`,
+			want:  "id,owner.login,tags.0,tags.1,extra\n1,a,x,y,\n2,,,,\"q,r\"\n",
+		},
+		{
+			name:    "delimiter",
+			input:   `{"a":1,"b":"x"}`,
+			options: `"delimiter": ";"`,
+			want:    "a;b\n1;x\n",
+		},
+		{
+			name:     "values that are reported",
+			input:    `[{"a":null,"b":{},"c":[]},5]`,
+			want:     "a,b,c\n,{},[]\n",
+			warnings: []string{"/0/a", "/0/b", "/0/c", "/1"},
+		},
+		{
+			name:     "colliding columns",
+			input:    `[{"a.b":1,"a":{"b":2}}]`,
+			want:     "a.b\n2\n",
+			warnings: []string{"/0/a/b"},
+		},
+		{
+			name:     "columns that change shape between rows",
+			input:    `[{"a":1,"b":[1]},{"a":{"x":2},"b":"s"},{"a":3,"b":[2]}]`,
+			want:     "a,b.0,a.x,b\n1,1,,\n,,2,s\n3,2,,\n",
+			warnings: []string{"/1/a", "/1/b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := `{"to": "csv"}`
+			if tt.options != "" {
+				options = `{"to": "csv", ` + tt.options + `}`
+			}
+			result := runForTest[ConvertResult](t, runConvert, tt.input, options)
+			if result.Output != tt.want {
+				t.Errorf("output =\n%s\nwant\n%s", result.Output, tt.want)
+			}
+			want := tt.warnings
+			if want == nil {
+				want = []string{}
+			}
+			if got := warningPointers(result.Warnings); !reflect.DeepEqual(got, want) {
+				t.Errorf("warnings = %+v, want %v", result.Warnings, want)
+			}
+		})
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		options  string
+		want     string
+		warnings int
+	}{
+		{
+			name:  "inferred values",
+			input: "id,name,ok,note\n1,a,true,\n2,\"b, c\",false,x\n",
+			want:  `[{"id":1,"name":"a","ok":true,"note":null},{"id":2,"name":"b, c","ok":false,"note":"x"}]`,
+		},
+		{
+			name:    "keep strings",
+			input:   "id,note\n1,\n",
+			options: `"keepStrings": true`,
+			want:    `[{"id":"1","note":""}]`,
+		},
+		{
+			name:    "unflatten",
+			input:   "id,owner.login,owner.id\n1,a,2\n",
+			options: `"unflatten": true`,
+			want:    `[{"id":1,"owner":{"login":"a","id":2}}]`,
+		},
+		{
+			name:     "unnamed and repeated columns",
+			input:    "a,,a\n1,2,3\n",
+			want:     `[{"a":3,"column2":2}]`,
+			warnings: 2,
+		},
+		{
+			name:     "conflicting nested columns",
+			input:    "a,a.b\n1,2\n",
+			options:  `"unflatten": true`,
+			want:     `[{"a":1}]`,
+			warnings: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := `{"from": "csv"}`
+			if tt.options != "" {
+				options = `{"from": "csv", ` + tt.options + `}`
+			}
+			result := runForTest[ConvertResult](t, runConvert, tt.input, options)
+			if got := compactForTest(t, result.Output); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			if result.WarningCount != tt.warnings {
+				t.Errorf("warnings = %+v, want %d", result.Warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	doc := `[{"id":1,"owner":{"login":"a"},"score":1.5},{"id":2,"owner":{"login":"b, c"},"score":-3}]`
+	out := runForTest[ConvertResult](t, runConvert, doc, `{"to": "csv"}`)
+	back := runForTest[ConvertResult](t, runConvert, out.Output, `{"from": "csv", "unflatten": true}`)
+	if got := compactForTest(t, back.Output); got != doc {
+		t.Errorf("round trip = %s, want %s\nCSV:\n%s", got, doc, out.Output)
+	}
+}
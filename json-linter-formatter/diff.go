@@ -0,0 +1,444 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// maxLCSCells bounds the table used to align ordered arrays. Beyond it,
+// arrays are aligned only on their common prefix and suffix.
+const maxLCSCells = 4000000
+
+// Array comparison modes
+const (
+	arraysOrdered = "ordered"
+	arraysSet     = "set"
+)
+
+// DiffOptions are the options of the diff operation. The input is the
+// original document and Target the changed one, given either as a JSON
+// value or as JSON text.
+type DiffOptions struct {
+	Target json.RawMessage `json:"target"`
+	// IgnorePaths are JSON Pointers to leave out of the comparison. A "*"
+	// segment matches any key or index.
+	IgnorePaths []string `json:"ignorePaths"`
+	// Arrays is "ordered" (the default) or "set"
+	Arrays string `json:"arrays"`
+	// IdentityKey matches objects in set arrays by this member, so an
+	// edited element is reported as a change rather than a remove and add
+	IdentityKey string `json:"identityKey"`
+}
+
+// DiffChange is one difference between the documents. Removals are located
+// in the original document; additions and changes in the target.
+type DiffChange struct {
+	Type     string          `json:"type"`
+	Path     string          `json:"path"`
+	OldValue json.RawMessage `json:"oldValue,omitempty"`
+	NewValue json.RawMessage `json:"newValue,omitempty"`
+}
+
+// PatchOperation is a single RFC 6902 JSON Patch operation
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// DiffResult is the result of the diff operation
+type DiffResult struct {
+	Equal   bool             `json:"equal"`
+	Added   int              `json:"added"`
+	Removed int              `json:"removed"`
+	Changed int              `json:"changed"`
+	Changes []DiffChange     `json:"changes"`
+	Patch   []PatchOperation `json:"patch"`
+}
+
+// differ accumulates the changes and patch for one comparison
+type differ struct {
+	opts   DiffOptions
+	result DiffResult
+}
+
+// diffPaths tracks where the values being compared sit: in the original
+// document, in the target, and in the document as the patch rewrites it
+type diffPaths struct {
+	left, right, patch string
+}
+
+func (p diffPaths) member(key string) diffPaths {
+	return diffPaths{appendPointer(p.left, key), appendPointer(p.right, key), appendPointer(p.patch, key)}
+}
+
+// runDiff compares the input with the target document
+func runDiff(req OperationRequest) (interface{}, error) {
+	var opts DiffOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+	switch opts.Arrays {
+	case "":
+		opts.Arrays = arraysOrdered
+	case arraysOrdered, arraysSet:
+	default:
+		return nil, fmt.Errorf("invalid arrays mode %q (expected ordered or set)", opts.Arrays)
+	}
+	for _, pattern := range opts.IgnorePaths {
+		if _, err := splitPointer(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	targetText, err := documentOption(opts.Target, "target")
+	if err != nil {
+		return nil, err
+	}
+
+	left, err := parseTree(req.Input)
+	if err != nil {
+		return nil, fmt.Errorf("invalid input JSON: %w", err)
+	}
+	right, err := parseTree(targetText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target JSON: %w", err)
+	}
+
+	return diffTrees(left, right, opts), nil
+}
+
+// documentOption returns the JSON text of an option that holds a document
+// either inline or as a JSON string
+func documentOption(raw json.RawMessage, name string) (string, error) {
+	text := strings.TrimSpace(string(raw))
+	if text == "" {
+		return "", fmt.Errorf("%s is required", name)
+	}
+	if strings.HasPrefix(text, `"`) {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return "", fmt.Errorf("invalid %s option: %w", name, err)
+		}
+		return s, nil
+	}
+	return text, nil
+}
+
+// diffTrees compares two parsed documents
+func diffTrees(left, right *node, opts DiffOptions) DiffResult {
+	d := &differ{opts: opts, result: DiffResult{Changes: []DiffChange{}, Patch: []PatchOperation{}}}
+	d.compare(left, right, diffPaths{})
+	d.result.Equal = len(d.result.Changes) == 0
+	return d.result
+}
+
+// ignored reports whether either side of a comparison is excluded
+func (d *differ) ignored(p diffPaths) bool {
+	return matchesAnyPointer(p.left, d.opts.IgnorePaths) || matchesAnyPointer(p.right, d.opts.IgnorePaths)
+}
+
+func (d *differ) added(p diffPaths, value *node) {
+	raw := json.RawMessage(value.compact())
+	d.result.Added++
+	d.result.Changes = append(d.result.Changes, DiffChange{Type: "added", Path: p.right, NewValue: raw})
+	d.result.Patch = append(d.result.Patch, PatchOperation{Op: "add", Path: p.patch, Value: raw})
+}
+
+func (d *differ) removed(p diffPaths, value *node) {
+	d.result.Removed++
+	d.result.Changes = append(d.result.Changes, DiffChange{Type: "removed", Path: p.left, OldValue: json.RawMessage(value.compact())})
+	d.result.Patch = append(d.result.Patch, PatchOperation{Op: "remove", Path: p.patch})
+}
+
+func (d *differ) changed(p diffPaths, from, to *node) {
+	raw := json.RawMessage(to.compact())
+	d.result.Changed++
+	d.result.Changes = append(d.result.Changes, DiffChange{Type: "changed", Path: p.right, OldValue: json.RawMessage(from.compact()), NewValue: raw})
+	d.result.Patch = append(d.result.Patch, PatchOperation{Op: "replace", Path: p.patch, Value: raw})
+}
+
+// compare records the differences between two values
+func (d *differ) compare(left, right *node, p diffPaths) {
+	if d.ignored(p) {
+		return
+	}
+
+	switch {
+	case left.Kind == nodeObject && right.Kind == nodeObject:
+		d.compareObjects(left, right, p)
+	case left.Kind == nodeArray && right.Kind == nodeArray:
+		if d.opts.Arrays == arraysSet {
+			d.compareSets(left, right, p)
+		} else {
+			d.compareOrdered(left, right, p)
+		}
+	case equalityKey(left) != equalityKey(right):
+		d.changed(p, left, right)
+	}
+}
+
+// lastMembers indexes an object's members by key, keeping the last value
+// for repeated keys, and returns the keys in order of first appearance
+func lastMembers(n *node) ([]string, map[string]*node) {
+	var keys []string
+	values := make(map[string]*node, len(n.Members))
+	for _, m := range n.Members {
+		if _, seen := values[m.Key]; !seen {
+			keys = append(keys, m.Key)
+		}
+		values[m.Key] = m.Value
+	}
+	return keys, values
+}
+
+func (d *differ) compareObjects(left, right *node, p diffPaths) {
+	leftKeys, leftValues := lastMembers(left)
+	rightKeys, rightValues := lastMembers(right)
+
+	for _, key := range leftKeys {
+		child := p.member(key)
+		if rightValue, ok := rightValues[key]; ok {
+			d.compare(leftValues[key], rightValue, child)
+		} else if !d.ignored(child) {
+			d.removed(child, leftValues[key])
+		}
+	}
+	for _, key := range rightKeys {
+		if _, ok := leftValues[key]; !ok {
+			if child := p.member(key); !d.ignored(child) {
+				d.added(child, rightValues[key])
+			}
+		}
+	}
+}
+
+// compareOrdered aligns two arrays on their longest common subsequence.
+// Unmatched items between aligned ones are compared pairwise, and any
+// surplus is removed or added.
+func (d *differ) compareOrdered(left, right *node, p diffPaths) {
+	leftKeys := make([]string, len(left.Items))
+	for i, item := range left.Items {
+		leftKeys[i] = equalityKey(item)
+	}
+	rightKeys := make([]string, len(right.Items))
+	for i, item := range right.Items {
+		rightKeys[i] = equalityKey(item)
+	}
+
+	matches := alignSequences(leftKeys, rightKeys)
+	matches = append(matches, [2]int{len(leftKeys), len(rightKeys)})
+
+	i, j, k := 0, 0, 0
+	at := func(i, j, k int) diffPaths {
+		return diffPaths{appendPointerIndex(p.left, i), appendPointerIndex(p.right, j), appendPointerIndex(p.patch, k)}
+	}
+	for _, m := range matches {
+		for ; i < m[0] && j < m[1]; i, j, k = i+1, j+1, k+1 {
+			d.compare(left.Items[i], right.Items[j], at(i, j, k))
+		}
+		// k only advances past items that remain in the patched array
+		for ; i < m[0]; i++ {
+			if child := at(i, j, k); d.ignored(child) {
+				k++
+			} else {
+				d.removed(child, left.Items[i])
+			}
+		}
+		for ; j < m[1]; j++ {
+			if child := at(i, j, k); !d.ignored(child) {
+				d.added(child, right.Items[j])
+				k++
+			}
+		}
+		i, j, k = i+1, j+1, k+1
+	}
+}
+
+// alignSequences returns the index pairs of a longest common subsequence.
+// Sequences too large for the table are aligned only on their common
+// prefix and suffix.
+func alignSequences(a, b []string) [][2]int {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var matches [][2]int
+	for i := 0; i < prefix; i++ {
+		matches = append(matches, [2]int{i, i})
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(midA) > 0 && len(midB) > 0 && len(midA)*len(midB) <= maxLCSCells {
+		// lengths[i][j] is the LCS length of midA[i:] and midB[j:]
+		lengths := make([][]int32, len(midA)+1)
+		for i := range lengths {
+			lengths[i] = make([]int32, len(midB)+1)
+		}
+		for i := len(midA) - 1; i >= 0; i-- {
+			for j := len(midB) - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lengths[i][j] = lengths[i+1][j+1] + 1
+				} else if lengths[i+1][j] >= lengths[i][j+1] {
+					lengths[i][j] = lengths[i+1][j]
+				} else {
+					lengths[i][j] = lengths[i][j+1]
+				}
+			}
+		}
+		for i, j := 0, 0; i < len(midA) && j < len(midB); {
+			switch {
+			case midA[i] == midB[j]:
+				matches = append(matches, [2]int{prefix + i, prefix + j})
+				i++
+				j++
+			case lengths[i+1][j] >= lengths[i][j+1]:
+				i++
+			default:
+				j++
+			}
+		}
+	}
+
+	for s := suffix; s > 0; s-- {
+		matches = append(matches, [2]int{len(a) - s, len(b) - s})
+	}
+	return matches
+}
+
+// compareSets matches array items regardless of position. Objects carrying
+// the identity key are paired by its value and compared member by member;
+// other items are paired only when equal.
+func (d *differ) compareSets(left, right *node, p diffPaths) {
+	identity := func(n *node) (string, bool) {
+		if d.opts.IdentityKey == "" || n.Kind != nodeObject {
+			return "", false
+		}
+		_, values := lastMembers(n)
+		if v, ok := values[d.opts.IdentityKey]; ok {
+			return "id:" + equalityKey(v), true
+		}
+		return "", false
+	}
+	key := func(n *node) string {
+		if id, ok := identity(n); ok {
+			return id
+		}
+		return "value:" + equalityKey(n)
+	}
+
+	// Pair each right item with the first unused left item of the same key
+	available := make(map[string][]int)
+	for i, item := range left.Items {
+		k := key(item)
+		available[k] = append(available[k], i)
+	}
+	pairOf := make(map[int]int)
+	var unmatchedRight []int
+	for j, item := range right.Items {
+		k := key(item)
+		if candidates := available[k]; len(candidates) > 0 {
+			pairOf[candidates[0]] = j
+			available[k] = candidates[1:]
+		} else {
+			unmatchedRight = append(unmatchedRight, j)
+		}
+	}
+
+	// Removals go first, from the back, so earlier indexes stay valid
+	var removals []int
+	for i := len(left.Items) - 1; i >= 0; i-- {
+		if _, ok := pairOf[i]; !ok {
+			removals = append(removals, i)
+		}
+	}
+	for _, i := range removals {
+		child := diffPaths{appendPointerIndex(p.left, i), "", appendPointerIndex(p.patch, i)}
+		if !d.ignored(child) {
+			d.removed(child, left.Items[i])
+		}
+	}
+
+	// Removals of ignored items were skipped, so positions are recomputed
+	// against what remains
+	position := 0
+	for i := range left.Items {
+		_, paired := pairOf[i]
+		child := diffPaths{left: appendPointerIndex(p.left, i)}
+		if !paired && !d.ignored(child) {
+			continue
+		}
+		if paired {
+			j := pairOf[i]
+			d.compare(left.Items[i], right.Items[j], diffPaths{
+				appendPointerIndex(p.left, i), appendPointerIndex(p.right, j), appendPointerIndex(p.patch, position),
+			})
+		}
+		position++
+	}
+
+	sort.Ints(unmatchedRight)
+	for _, j := range unmatchedRight {
+		child := diffPaths{"", appendPointerIndex(p.right, j), p.patch + "/-"}
+		if !d.ignored(child) {
+			d.added(child, right.Items[j])
+		}
+	}
+}
+
+// equalityKey renders a value so that semantically equal values, ignoring
+// key order, whitespace and number spelling, give the same string
+func equalityKey(n *node) string {
+	var b strings.Builder
+	writeEqualityKey(&b, n)
+	return b.String()
+}
+
+func writeEqualityKey(b *strings.Builder, n *node) {
+	switch n.Kind {
+	case nodeObject:
+		keys, values := lastMembers(n)
+		sort.Strings(keys)
+		b.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			quoted, _ := json.Marshal(key)
+			b.Write(quoted)
+			b.WriteByte(':')
+			writeEqualityKey(b, values[key])
+		}
+		b.WriteByte('}')
+	case nodeArray:
+		b.WriteByte('[')
+		for i, item := range n.Items {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeEqualityKey(b, item)
+		}
+		b.WriteByte(']')
+	case nodeNumber:
+		if r, ok := new(big.Rat).SetString(n.Raw); ok {
+			b.WriteString(r.RatString())
+		} else {
+			b.WriteString(n.Raw)
+		}
+	case nodeString:
+		s, _ := n.value().(string)
+		quoted, _ := json.Marshal(s)
+		b.Write(quoted)
+	default:
+		b.WriteString(n.Raw)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// patchSummary renders patch operations as "op path [value]" lines
+func patchSummary(ops []PatchOperation) string {
+	var lines []string
+	for _, op := range ops {
+		line := op.Op + " " + op.Path
+		if op.From != "" {
+			line += " from " + op.From
+		}
+		if len(op.Value) > 0 {
+			line += " " + string(op.Value)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestRunDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		target  string
+		options string
+		patch   []string
+	}{
+		{
+			name:   "equal documents",
+			input:  `{"a": 1, "b": [1, 2]}`,
+			target: `{"b": [1, 2], "a": 1.0}`,
+		},
+		{
+			name:   "object members",
+			input:  `{"a": 1, "b": 2}`,
+			target: `{"a": 1, "b": 3, "c/d": null}`,
+			patch:  []string{"replace /b 3", "add /c~1d null"},
+		},
+		{
+			name:   "removed member",
+			input:  `{"a": {"x": 1, "y": 2}}`,
+			target: `{"a": {"y": 2}}`,
+			patch:  []string{"remove /a/x"},
+		},
+		{
+			name:   "ordered array insert",
+			input:  `[1, 2, 3]`,
+			target: `[1, 4, 2, 3]`,
+			patch:  []string{"add /1 4"},
+		},
+		{
+			name:   "ordered array removals use shifted indexes",
+			input:  `[1, 2, 3, 4]`,
+			target: `[1, 4]`,
+			patch:  []string{"remove /1", "remove /1"},
+		},
+		{
+			name:   "type change",
+			input:  `{"a": [1]}`,
+			target: `{"a": {"0": 1}}`,
+			patch:  []string{`replace /a {"0":1}`},
+		},
+		{
+			name:    "ignored paths",
+			input:   `{"id": 1, "meta": {"at": "x"}, "items": [{"ts": 1}]}`,
+			target:  `{"id": 2, "meta": {"at": "y"}, "items": [{"ts": 2}]}`,
+			options: `{"ignorePaths": ["/meta/at", "/items/*/ts"]}`,
+			patch:   []string{"replace /id 2"},
+		},
+		{
+			name:    "set arrays ignore order",
+			input:   `[1, 2, 3]`,
+			target:  `[3, 1, 2]`,
+			options: `{"arrays": "set"}`,
+		},
+		{
+			name:    "set arrays with identity key",
+			input:   `[{"id": 1, "v": "a"}, {"id": 2, "v": "b"}]`,
+			target:  `[{"id": 2, "v": "c"}, {"id": 1, "v": "a"}]`,
+			options: `{"arrays": "set", "identityKey": "id"}`,
+			patch:   []string{`replace /1/v "c"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := map[string]interface{}{}
+			if tt.options != "" {
+				if err := json.Unmarshal([]byte(tt.options), &options); err != nil {
+					t.Fatal(err)
+				}
+			}
+			options["target"] = tt.target
+			data, _ := json.Marshal(options)
+
+			value, err := runDiff(OperationRequest{Operation: "diff", Input: tt.input, Options: data})
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := value.(DiffResult)
+			if result.Equal != (len(tt.patch) == 0) {
+				t.Errorf("equal = %v", result.Equal)
+			}
+			if got, want := patchSummary(result.Patch), strings.Join(tt.patch, "\n"); got != want {
+				t.Errorf("patch =\n%s\nwant\n%s", got, want)
+			}
+			if len(result.Changes) != result.Added+result.Removed+result.Changed {
+				t.Errorf("counts %d+%d+%d do not match %d changes", result.Added, result.Removed, result.Changed, len(result.Changes))
+			}
+		})
+	}
+}
+
+func TestRunDiffErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		options string
+	}{
+		{"missing target", `{}`, `{}`},
+		{"invalid arrays mode", `{}`, `{"target": {}, "arrays": "bag"}`},
+		{"invalid ignore path", `{}`, `{"target": {}, "ignorePaths": ["no-slash"]}`},
+		{"invalid input", `{`, `{"target": {}}`},
+		{"invalid target", `{}`, `{"target": "{"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := runDiff(OperationRequest{Input: tt.input, Options: []byte(tt.options)}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+)
+
+// decodeJSON parses a complete JSON document into generic values. Numbers
+// are kept as json.Number so their literal text survives round-trips.
+func decodeJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("empty JSON input")
+		}
+		return nil, err
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid character after top-level value at offset %d", dec.InputOffset())
+	}
+	return value, nil
+}
+
+// jsonQuote returns s as a JSON string literal. HTML characters are left
+// as they are; responses escape them according to the settings.
+func jsonQuote(s string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// jsonType returns the JSON Schema type name of a decoded value
+func jsonType(v interface{}) string {
+	switch n := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if r, ok := numberRat(n); ok && r.IsInt() {
+			return "integer"
+		}
+		return "number"
+	case float64:
+		if n == float64(int64(n)) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// numberRat converts a numeric value to an exact rational
+func numberRat(v interface{}) (*big.Rat, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		return new(big.Rat).SetString(string(n))
+	case float64:
+		r := new(big.Rat)
+		if r.SetFloat64(n) == nil {
+			return nil, false
+		}
+		return r, true
+	case int:
+		return new(big.Rat).SetInt64(int64(n)), true
+	}
+	return nil, false
+}
+
+// jsonEqual compares two decoded values structurally. Numbers are equal
+// when they have the same mathematical value, so 1 equals 1.0.
+func jsonEqual(a, b interface{}) bool {
+	ra, aNum := numberRat(a)
+	rb, bNum := numberRat(b)
+	if aNum || bNum {
+		return aNum && bNum && ra.Cmp(rb) == 0
+	}
+
+	switch av := a.(type) {
+	case nil:
+		return b == nil
+	case bool:
+		bv, ok := b.(bool)
+		return ok && av == bv
+	case string:
+		bv, ok := b.(string)
+		return ok && av == bv
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !jsonEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, v := range av {
+			other, exists := bv[k]
+			if !exists || !jsonEqual(v, other) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// pointerEscaper escapes reference tokens as described in RFC 6901
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// pointerUnescaper reverses pointerEscaper
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// appendPointer extends a JSON Pointer with one reference token
+func appendPointer(pointer, token string) string {
+	return pointer + "/" + pointerEscaper.Replace(token)
+}
+
+// appendPointerIndex extends a JSON Pointer with an array index
+func appendPointerIndex(pointer string, index int) string {
+	return fmt.Sprintf("%s/%d", pointer, index)
+}
+
+// splitPointer breaks a JSON Pointer into unescaped reference tokens
+func splitPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q: must start with '/'", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = pointerUnescaper.Replace(token)
+	}
+	return tokens, nil
+}
+
+// resolvePointer looks up the value a JSON Pointer refers to
+func resolvePointer(doc interface{}, pointer string) (interface{}, error) {
+	tokens, err := splitPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	current := doc
+	for i, token := range tokens {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			next, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path %q not found: no member %q", pointer, token)
+			}
+			current = next
+		case []interface{}:
+			index, err := arrayIndex(token, len(node))
+			if err != nil {
+				return nil, fmt.Errorf("path %q not found: %v", pointer, err)
+			}
+			current = node[index]
+		default:
+			return nil, fmt.Errorf("path %q not found: %q is a %s", pointer, "/"+strings.Join(tokens[:i], "/"), jsonType(current))
+		}
+	}
+	return current, nil
+}
+
+// arrayIndex parses an array index token and checks it against length
+func arrayIndex(token string, length int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+
+	index := 0
+	for _, c := range token {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid array index %q", token)
+		}
+		index = index*10 + int(c-'0')
+		if index > length {
+			return 0, fmt.Errorf("array index %s out of range (length %d)", token, length)
+		}
+	}
+
+	if index >= length {
+		return 0, fmt.Errorf("array index %s out of range (length %d)", token, length)
+	}
+	return index, nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxExpandLayers bounds how many times one string is decoded, guarding
+// against values encoded over and over
+const maxExpandLayers = 32
+
+// ExpandOptions are the options of the expand operation. Paths are JSON
+// Pointers, in which a "*" segment matches any key or index, limiting
+// which strings are expanded; all strings are considered when empty.
+type ExpandOptions struct {
+	Paths []string `json:"paths"`
+	// Scalars also expands strings holding a number, boolean or null.
+	// Only objects and arrays are expanded by default.
+	Scalars bool `json:"scalars"`
+}
+
+// ExpandResult is the result of the expand operation. Paths point at the
+// expanded values in the output.
+type ExpandResult struct {
+	Expanded string   `json:"expanded"`
+	Paths    []string `json:"paths"`
+	Count    int      `json:"count"`
+}
+
+// StringifyOptions are the options of the stringify operation. Paths are
+// JSON Pointers, in which a "*" segment matches any key or index, selecting
+// the values to encode; each must match at least one value.
+type StringifyOptions struct {
+	Paths []string `json:"paths"`
+}
+
+// StringifyResult is the result of the stringify operation. Paths point
+// at the encoded values, in document order.
+type StringifyResult struct {
+	Output string   `json:"output"`
+	Paths  []string `json:"paths"`
+}
+
+// embeddedDocument parses the text of a string value as JSON. Strings
+// holding scalars only count when scalars is set, so ordinary text such as
+// "42" or "true" is not mistaken for an encoded document.
+func embeddedDocument(n *node, scalars bool) (*node, bool) {
+	if n.Kind != nodeString {
+		return nil, false
+	}
+	text := strings.TrimSpace(scalarText(n))
+	if text == "" {
+		return nil, false
+	}
+	if !scalars && text[0] != '{' && text[0] != '[' && text[0] != '"' {
+		return nil, false
+	}
+	doc, err := parseTree(text)
+	if err != nil {
+		return nil, false
+	}
+	// A quoted string only counts when it wraps another encoded document,
+	// as happens when a value is encoded twice
+	if doc.Kind == nodeString {
+		if _, ok := embeddedDocument(doc, scalars); !ok {
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// runExpand replaces string values holding encoded JSON with the decoded
+// value, recursing into what was decoded
+func runExpand(req OperationRequest) (interface{}, error) {
+	var opts ExpandOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+	for _, pattern := range opts.Paths {
+		if _, err := splitPointer(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	root, err := parseTree(req.Input)
+	if err != nil {
+		return nil, fmt.Errorf("invalid input JSON: %w", err)
+	}
+
+	result := ExpandResult{Paths: []string{}}
+	var expand func(n *node, path string)
+	expand = func(n *node, path string) {
+		if len(opts.Paths) == 0 || matchesAnyPointer(path, opts.Paths) {
+			for layer := 0; layer < maxExpandLayers; layer++ {
+				doc, ok := embeddedDocument(n, opts.Scalars)
+				if !ok {
+					break
+				}
+				if layer == 0 {
+					result.Paths = append(result.Paths, path)
+				}
+				*n = *doc
+			}
+		}
+		switch n.Kind {
+		case nodeObject:
+			for _, m := range n.Members {
+				expand(m.Value, appendPointer(path, m.Key))
+			}
+		case nodeArray:
+			for i, item := range n.Items {
+				expand(item, appendPointerIndex(path, i))
+			}
+		}
+	}
+	expand(root, "")
+
+	expanded, err := formatTokens(root.compact(), currentFormatSettings().style())
+	if err != nil {
+		return nil, err
+	}
+	result.Expanded = expanded
+	result.Count = len(result.Paths)
+	return result, nil
+}
+
+// runStringify replaces the values at the given paths with strings
+// holding their minified JSON, the reverse of expand
+func runStringify(req OperationRequest) (interface{}, error) {
+	var opts StringifyOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+	if len(opts.Paths) == 0 {
+		return nil, fmt.Errorf("at least one path is required")
+	}
+
+	root, err := parseTree(req.Input)
+	if err != nil {
+		return nil, fmt.Errorf("invalid input JSON: %w", err)
+	}
+
+	for _, pattern := range opts.Paths {
+		if _, err := splitPointer(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	type target struct {
+		n     *node
+		path  string
+		depth int
+	}
+	var targets []target
+	var collect func(n *node, path string, depth int)
+	collect = func(n *node, path string, depth int) {
+		if matchesAnyPointer(path, opts.Paths) {
+			targets = append(targets, target{n, path, depth})
+		}
+		switch n.Kind {
+		case nodeObject:
+			for _, m := range n.Members {
+				collect(m.Value, appendPointer(path, m.Key), depth+1)
+			}
+		case nodeArray:
+			for i, item := range n.Items {
+				collect(item, appendPointerIndex(path, i), depth+1)
+			}
+		}
+	}
+	collect(root, "", 0)
+
+	paths := make([]string, len(targets))
+	for i, t := range targets {
+		paths[i] = t.path
+	}
+	for _, pattern := range opts.Paths {
+		if !matchesAnyOf(pattern, paths) {
+			return nil, fmt.Errorf("no value matches path %q", pattern)
+		}
+	}
+
+	// Inner values are encoded before the values containing them, so
+	// nested paths end up encoded twice as they would have been
+	sort.SliceStable(targets, func(i, j int) bool { return targets[i].depth > targets[j].depth })
+	for _, t := range targets {
+		*t.n = *stringNode(t.n.compact())
+	}
+
+	output, err := formatTokens(root.compact(), currentFormatSettings().style())
+	if err != nil {
+		return nil, err
+	}
+	return StringifyResult{Output: output, Paths: paths}, nil
+}
+
+// matchesAnyOf reports whether pattern matches any of the pointers
+func matchesAnyOf(pattern string, pointers []string) bool {
+	for _, pointer := range pointers {
+		if matchesAnyPointer(pointer, []string{pattern}) {
+			return true
+		}
+	}
+	return false
+}
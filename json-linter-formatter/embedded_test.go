@@ -0,0 +1,183 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRunExpand(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		options string
+		want    string
+		paths   []string
+	}{
+		{
+			name:    "objects and arrays",
+			input:   `{"body": "{\"a\": 1}", "list": "[1, 2]", "text": "hello"}`,
+			options: `{}`,
+			want:    `{"body":{"a":1},"list":[1,2],"text":"hello"}`,
+			paths:   []string{"/body", "/list"},
+		},
+		{
+			name:    "scalars only when asked",
+			input:   `{"n": "42", "b": "true", "o": "{}"}`,
+			options: `{}`,
+			want:    `{"n":"42","b":"true","o":{}}`,
+			paths:   []string{"/o"},
+		},
+		{
+			name:    "scalars",
+			input:   `{"n": "42", "b": "true", "s": "plain"}`,
+			options: `{"scalars": true}`,
+			want:    `{"n":42,"b":true,"s":"plain"}`,
+			paths:   []string{"/n", "/b"},
+		},
+		{
+			name:    "values encoded twice",
+			input:   `{"x": "\"{\\\"a\\\": \\\"[1]\\\"}\""}`,
+			options: `{}`,
+			want:    `{"x":{"a":[1]}}`,
+			paths:   []string{"/x", "/x/a"},
+		},
+		{
+			name:    "quoted plain strings stay",
+			input:   `{"q": "\"hi\""}`,
+			options: `{}`,
+			want:    `{"q":"\"hi\""}`,
+			paths:   []string{},
+		},
+		{
+			name:    "path patterns",
+			input:   `{"rows": [{"meta": "{}", "raw": "[]"}, {"meta": "[0]"}]}`,
+			options: `{"paths": ["/rows/*/meta"]}`,
+			want:    `{"rows":[{"meta":{},"raw":"[]"},{"meta":[0]}]}`,
+			paths:   []string{"/rows/0/meta", "/rows/1/meta"},
+		},
+		{
+			name:    "invalid embedded JSON is left alone",
+			input:   `["{not json}", "[1,"]`,
+			options: `{}`,
+			want:    `["{not json}","[1,"]`,
+			paths:   []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := runExpand(OperationRequest{Operation: "expand", Input: tt.input, Options: []byte(tt.options)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := value.(ExpandResult)
+			if got := compactForTest(t, result.Expanded); got != tt.want {
+				t.Errorf("expanded = %s, want %s", got, tt.want)
+			}
+			if !reflect.DeepEqual(result.Paths, tt.paths) || result.Count != len(tt.paths) {
+				t.Errorf("paths = %v (count %d), want %v", result.Paths, result.Count, tt.paths)
+			}
+		})
+	}
+}
+
+func TestRunStringify(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		options string
+		want    string
+	}{
+		{
+			name:    "object value",
+			input:   `{"body": {"a": 1, "b": [true]}}`,
+			options: `{"paths": ["/body"]}`,
+			want:    `{"body":"{\"a\":1,\"b\":[true]}"}`,
+		},
+		{
+			name:    "nested paths are encoded twice",
+			input:   `{"x": {"a": [1]}}`,
+			options: `{"paths": ["/x", "/x/a"]}`,
+			want:    `{"x":"{\"a\":\"[1]\"}"}`,
+		},
+		{
+			name:    "scalars and repeated paths",
+			input:   `{"n": 1.50}`,
+			options: `{"paths": ["/n", "/n"]}`,
+			want:    `{"n":"1.50"}`,
+		},
+		{
+			name:    "wildcard paths",
+			input:   `{"rows": [{"body": {"a": 1}}, {"body": [2]}, {"id": 3}]}`,
+			options: `{"paths": ["/rows/*/body"]}`,
+			want:    `{"rows":[{"body":"{\"a\":1}"},{"body":"[2]"},{"id":3}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := runStringify(OperationRequest{Operation: "stringify", Input: tt.input, Options: []byte(tt.options)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := compactForTest(t, value.(StringifyResult).Output); got != tt.want {
+				t.Errorf("output = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStringifyExpandRoundTrip(t *testing.T) {
+	input := `{"x":{"a":[1,{"b":null}]},"y":"text"}`
+	value, err := runStringify(OperationRequest{Operation: "stringify", Input: input, Options: []byte(`{"paths": ["/x", "/x/a"]}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expanded, err := runExpand(OperationRequest{Operation: "expand", Input: value.(StringifyResult).Output})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := compactForTest(t, expanded.(ExpandResult).Expanded); got != input {
+		t.Errorf("round trip = %s, want %s", got, input)
+	}
+}
+
+func TestStringifyExpandRoundTripWithWildcards(t *testing.T) {
+	input := `{"events":[{"payload":{"tags":["a","b"]}},{"payload":{"tags":[]}}],"id":1}`
+	options := `{"paths": ["/events/*/payload", "/events/*/payload/tags"]}`
+	stringified := runForTest[StringifyResult](t, runStringify, input, options)
+
+	want := []string{"/events/0/payload", "/events/0/payload/tags", "/events/1/payload", "/events/1/payload/tags"}
+	if !reflect.DeepEqual(stringified.Paths, want) {
+		t.Errorf("paths = %v, want %v", stringified.Paths, want)
+	}
+
+	expanded := runForTest[ExpandResult](t, runExpand, stringified.Output, `{"paths": ["/events/*/payload", "/events/*/payload/tags"]}`)
+	if got := compactForTest(t, expanded.Expanded); got != input {
+		t.Errorf("round trip = %s, want %s", got, input)
+	}
+}
+
+func TestEmbeddedOperationErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		run     func(OperationRequest) (interface{}, error)
+		input   string
+		options string
+	}{
+		{"expand invalid input", runExpand, `{"a": }`, `{}`},
+		{"expand invalid pattern", runExpand, `{}`, `{"paths": ["a"]}`},
+		{"stringify needs a path", runStringify, `{}`, `{}`},
+		{"stringify missing path", runStringify, `{"a": 1}`, `{"paths": ["/b"]}`},
+		{"stringify invalid pointer", runStringify, `{"a": 1}`, `{"paths": ["a"]}`},
+		{"stringify unmatched wildcard", runStringify, `{"a": [1]}`, `{"paths": ["/a/*/b"]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.run(OperationRequest{Input: tt.input, Options: []byte(tt.options)}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
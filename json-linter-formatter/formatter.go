@@ -0,0 +1,237 @@
+package main
+
+import (
+	"io"
+	"strings"
+)
+
+// formatStyle controls the layout of formatted output
+type formatStyle struct {
+	// Indent is written once per nesting level
+	Indent string
+	// Minify drops all insignificant whitespace and any comments
+	Minify bool
+	// CompactArrays keeps arrays of scalars on one line when they fit
+	// within LineWidth
+	CompactArrays bool
+	LineWidth     int
+	// EscapeHTML rewrites <, > and & in strings as \u escapes, matching
+	// what encoding/json does by default
+	EscapeHTML bool
+}
+
+// defaultFormatStyle is used when no settings have been stored
+var defaultFormatStyle = formatStyle{Indent: "  ", LineWidth: defaultLineWidth}
+
+// formatTokens re-indents a valid JSON document by rewriting only the
+// whitespace between tokens. Key order, number spelling and string escapes
+// are copied from the source unchanged.
+func formatTokens(src string, style formatStyle) (string, error) {
+	return formatTokenStream(newTokenizer(src), style, false)
+}
+
+// tokenWriter accumulates formatted output and tracks pending line breaks
+type tokenWriter struct {
+	out            strings.Builder
+	style          formatStyle
+	depth          int
+	pendingNewline bool
+	pendingSpace   bool
+}
+
+// newline starts a new line at the current depth
+func (w *tokenWriter) newline() {
+	w.pendingNewline = false
+	w.pendingSpace = false
+	if w.style.Minify {
+		return
+	}
+	w.out.WriteByte('\n')
+	for i := 0; i < w.depth; i++ {
+		w.out.WriteString(w.style.Indent)
+	}
+}
+
+// value writes a token that begins a value, breaking the line first if one
+// is pending
+func (w *tokenWriter) value(text string) {
+	if w.pendingNewline {
+		w.newline()
+	} else if w.pendingSpace && !w.style.Minify {
+		w.out.WriteByte(' ')
+	}
+	w.pendingSpace = false
+	w.out.WriteString(text)
+}
+
+// column returns the width of the line written so far
+func (w *tokenWriter) column() int {
+	s := w.out.String()
+	return len([]rune(s[strings.LastIndexByte(s, '\n')+1:]))
+}
+
+// comments writes comments attached to a token. Comments that shared a
+// line with the previous token stay on that line; others get their own.
+func (w *tokenWriter) comments(comments []token) {
+	if w.style.Minify {
+		return
+	}
+	for _, c := range comments {
+		if c.SameLine && w.out.Len() > 0 {
+			if !strings.HasSuffix(w.out.String(), " ") {
+				w.out.WriteByte(' ')
+			}
+			w.out.WriteString(c.Text)
+		} else {
+			if w.out.Len() > 0 {
+				w.newline()
+			}
+			w.out.WriteString(c.Text)
+			w.pendingNewline = true
+		}
+
+		if strings.HasPrefix(c.Text, "//") {
+			w.pendingNewline = true
+		} else {
+			w.pendingSpace = true
+		}
+	}
+}
+
+// formatTokenStream re-indents the tokens from ts. When keepComments is set,
+// comments attached to tokens are written alongside them.
+func formatTokenStream(ts tokenSource, style formatStyle, keepComments bool) (string, error) {
+	tokens, err := collectTokens(ts)
+	if err != nil {
+		return "", err
+	}
+
+	w := &tokenWriter{style: style}
+	comments := func(t token) {
+		if keepComments {
+			w.comments(t.Comments)
+		}
+	}
+
+	// The last token marks the end of input and only carries comments
+	for i := 0; i < len(tokens)-1; i++ {
+		t := tokens[i]
+		if t.Kind == tokenString && style.EscapeHTML {
+			t.Text = escapeHTML(t.Text)
+		}
+
+		switch t.Kind {
+		case tokenBeginObject, tokenBeginArray:
+			comments(t)
+
+			// Empty containers stay on one line
+			following := tokens[i+1]
+			if len(following.Comments) == 0 && (following.Kind == tokenEndObject || following.Kind == tokenEndArray) {
+				w.value(t.Text + following.Text)
+				i++
+				continue
+			}
+
+			if t.Kind == tokenBeginArray && style.CompactArrays && !style.Minify {
+				if line, end, ok := compactArray(tokens, i, style.EscapeHTML); ok {
+					width := len([]rune(line))
+					if w.pendingNewline {
+						width += w.depth * len([]rune(style.Indent))
+					} else {
+						width += w.column() + 1
+					}
+					if width+1 <= style.LineWidth {
+						w.value(line)
+						i = end
+						continue
+					}
+				}
+			}
+
+			w.value(t.Text)
+			w.depth++
+			w.pendingNewline = true
+		case tokenEndObject, tokenEndArray:
+			comments(t)
+			w.depth--
+			w.newline()
+			w.out.WriteString(t.Text)
+		case tokenComma:
+			w.out.WriteByte(',')
+			w.pendingNewline = true
+			comments(t)
+		case tokenColon:
+			w.out.WriteByte(':')
+			w.pendingSpace = true
+			comments(t)
+		default:
+			comments(t)
+			w.value(t.Text)
+		}
+	}
+	comments(tokens[len(tokens)-1])
+
+	return w.out.String(), nil
+}
+
+// collectTokens reads every token from ts. The final token returned is a
+// placeholder for the end of input so trailing comments are not lost.
+func collectTokens(ts tokenSource) ([]token, error) {
+	var tokens []token
+	for {
+		t, err := ts.next()
+		if err == io.EOF {
+			return append(tokens, t), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+}
+
+// compactArray renders the array opening at tokens[start] on a single line
+// if it holds only scalars and no comments. It returns the rendered text and
+// the index of the closing bracket.
+func compactArray(tokens []token, start int, htmlSafe bool) (string, int, bool) {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i := start + 1; i < len(tokens); i++ {
+		t := tokens[i]
+		if len(t.Comments) > 0 {
+			return "", 0, false
+		}
+		switch t.Kind {
+		case tokenEndArray:
+			b.WriteByte(']')
+			return b.String(), i, true
+		case tokenComma:
+			b.WriteString(", ")
+		case tokenString:
+			if htmlSafe {
+				b.WriteString(escapeHTML(t.Text))
+			} else {
+				b.WriteString(t.Text)
+			}
+		case tokenNumber, tokenLiteral:
+			b.WriteString(t.Text)
+		default:
+			return "", 0, false
+		}
+	}
+	return "", 0, false
+}
+
+// htmlEscaper rewrites the characters encoding/json escapes for HTML safety
+var htmlEscaper = strings.NewReplacer(
+	"<", `\u003c`,
+	">", `\u003e`,
+	"&", `\u0026`,
+	"\u2028", `\u2028`,
+	"\u2029", `\u2029`,
+)
+
+// escapeHTML applies HTML-safe escaping to the text of a string token
+func escapeHTML(text string) string {
+	return htmlEscaper.Replace(text)
+}
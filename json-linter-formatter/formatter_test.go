@@ -0,0 +1,93 @@
+package main
+
+import "testing"
+
+func TestFormatTokens(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		style formatStyle
+		want  string
+	}{
+		{
+			name:  "keeps key order",
+			input: `{"b": 1, "a": {"z": true, "y": null}}`,
+			style: defaultFormatStyle,
+			want:  "{\n  \"b\": 1,\n  \"a\": {\n    \"z\": true,\n    \"y\": null\n  }\n}",
+		},
+		{
+			name:  "keeps number spelling",
+			input: `[1.0, 1e3, -0, 12345678901234567890, 0.10000000000000000001]`,
+			style: defaultFormatStyle,
+			want:  "[\n  1.0,\n  1e3,\n  -0,\n  12345678901234567890,\n  0.10000000000000000001\n]",
+		},
+		{
+			name:  "keeps string escapes",
+			input: `{"s":"caf\u00e9 \/ \"q\""}`,
+			style: defaultFormatStyle,
+			want:  "{\n  \"s\": \"caf\\u00e9 \\/ \\\"q\\\"\"\n}",
+		},
+		{
+			name:  "empty containers stay on one line",
+			input: `{"a": [ ], "b": { }}`,
+			style: defaultFormatStyle,
+			want:  "{\n  \"a\": [],\n  \"b\": {}\n}",
+		},
+		{
+			name:  "tabs",
+			input: `{"a": [1]}`,
+			style: formatStyle{Indent: "\t", LineWidth: defaultLineWidth},
+			want:  "{\n\t\"a\": [\n\t\t1\n\t]\n}",
+		},
+		{
+			name:  "minify",
+			input: "{\n  \"a\": [1, 2],\n  \"b\": \"x y\"\n}",
+			style: formatStyle{Minify: true},
+			want:  `{"a":[1,2],"b":"x y"}`,
+		},
+		{
+			name:  "compact arrays that fit",
+			input: `{"a": [1, 2, 3], "b": [{"c": 1}]}`,
+			style: formatStyle{Indent: "  ", CompactArrays: true, LineWidth: defaultLineWidth},
+			want:  "{\n  \"a\": [1, 2, 3],\n  \"b\": [\n    {\n      \"c\": 1\n    }\n  ]\n}",
+		},
+		{
+			name:  "compact arrays that do not fit",
+			input: `["aaaaaaaaaa", "bbbbbbbbbb"]`,
+			style: formatStyle{Indent: "  ", CompactArrays: true, LineWidth: 20},
+			want:  "[\n  \"aaaaaaaaaa\",\n  \"bbbbbbbbbb\"\n]",
+		},
+		{
+			name:  "escape HTML",
+			input: `["<a&b>"]`,
+			style: formatStyle{Minify: true, EscapeHTML: true},
+			want:  `["\u003ca\u0026b\u003e"]`,
+		},
+		{
+			name:  "scalar document",
+			input: `  "just a string"  `,
+			style: defaultFormatStyle,
+			want:  `"just a string"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := formatTokens(tt.input, tt.style)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("formatTokens =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatTokensRejectsInvalidTokens(t *testing.T) {
+	for _, input := range []string{`{"a": 1} x`, `'single'`, `["unterminated]`, `["\q"]`, `["\u12"]`, `[tru]`} {
+		if _, err := formatTokens(input, defaultFormatStyle); err == nil {
+			t.Errorf("formatTokens(%q) succeeded, want an error", input)
+		}
+	}
+}
@@ -0,0 +1,568 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// historyStorageKey is the plugin data key listing the sessions with a
+// history. Each session's revisions are stored under their own key, see
+// historySessionKey.
+const historyStorageKey = "json_history"
+
+// historyStoreVersion is the version of the stored history layout
+const historyStoreVersion = "2.0.0"
+
+// History bounds. The oldest revisions of a session are dropped once it
+// holds too many or too large revisions, and the least recently used
+// sessions once there are too many.
+const (
+	maxHistoryRevisions = 50
+	maxHistoryBytes     = 4 << 20
+	maxHistorySessions  = 20
+)
+
+// historyEditOperation labels revisions recorded from the editor rather
+// than produced by an operation
+const historyEditOperation = "edit"
+
+// historyQueueSize bounds the history work waiting to be stored. Recording
+// is skipped when the queue is full rather than holding up a response.
+const historyQueueSize = 64
+
+var (
+	// historyQueue runs history storage work in order on one goroutine,
+	// so revisions recorded after an operation are stored before a later
+	// history operation reads them
+	historyQueue       = make(chan func(), historyQueueSize)
+	startHistoryWorker sync.Once
+)
+
+// historyDocuments extracts the document produced by operations whose
+// results are recorded in the session history
+var historyDocuments = map[string]func(result interface{}) string{
+	"format":    func(r interface{}) string { return r.(JSONValidationResult).FormattedJSON },
+	"minify":    func(r interface{}) string { return r.(MinifyResult).Minified },
+	"repair":    func(r interface{}) string { return r.(RepairResult).Repaired },
+	"patch":     func(r interface{}) string { return r.(PatchResult).Document },
+	"normalize": func(r interface{}) string { return r.(NormalizeResult).Normalized },
+	"expand":    func(r interface{}) string { return r.(ExpandResult).Expanded },
+	"stringify": func(r interface{}) string { return r.(StringifyResult).Output },
+}
+
+// Revision is one stored version of a session's document
+type Revision struct {
+	ID        int       `json:"id"`
+	Operation string    `json:"operation"`
+	Document  string    `json:"document"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// RevisionSummary describes a revision without its document
+type RevisionSummary struct {
+	ID        int       `json:"id"`
+	Operation string    `json:"operation"`
+	Size      int       `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+	Current   bool      `json:"current"`
+}
+
+// sessionHistory is the stored history of one session. Current is the ID
+// of the revision undo and redo move from; revisions after it can be
+// redone until a new revision is recorded.
+type sessionHistory struct {
+	Revisions []Revision `json:"revisions"`
+	Current   int        `json:"current"`
+	NextID    int        `json:"nextId"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+// historyIndex is the stored list of sessions, most recently used first
+type historyIndex struct {
+	Sessions []string `json:"sessions"`
+	Version  string   `json:"version"`
+}
+
+// HistoryOptions are the options of the history operations. The session
+// is taken from the request envelope.
+type HistoryOptions struct {
+	// Operation labels a revision recorded with historyRecord
+	Operation string `json:"operation"`
+	// ID selects the revision returned by historyGet
+	ID int `json:"id"`
+}
+
+// HistoryDiffOptions are the options of the historyDiff operation. From
+// defaults to the revision before the current one and To to the current
+// one.
+type HistoryDiffOptions struct {
+	From        int      `json:"from"`
+	To          int      `json:"to"`
+	IgnorePaths []string `json:"ignorePaths"`
+	Arrays      string   `json:"arrays"`
+	IdentityKey string   `json:"identityKey"`
+}
+
+// HistoryState describes a session's history after an operation.
+// Revision is the current revision with its document.
+type HistoryState struct {
+	Session   string            `json:"session"`
+	Revision  *Revision         `json:"revision,omitempty"`
+	CanUndo   bool              `json:"canUndo"`
+	CanRedo   bool              `json:"canRedo"`
+	Revisions []RevisionSummary `json:"revisions"`
+}
+
+// HistoryDiffResult is the result of the historyDiff operation
+type HistoryDiffResult struct {
+	From RevisionSummary `json:"from"`
+	To   RevisionSummary `json:"to"`
+	DiffResult
+}
+
+// historySessionKey is the plugin data key holding one session's history
+func historySessionKey(session string) string {
+	return historyStorageKey + ":" + session
+}
+
+// loadStoredHistory decodes the value stored under key into v. It
+// reports false when nothing is stored there; values saved in an older
+// layout are ignored.
+func loadStoredHistory(key string, v interface{}) (bool, error) {
+	if plugin == nil {
+		return false, fmt.Errorf("plugin storage not available")
+	}
+	stored, err := plugin.LoadData(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to load history: %w", err)
+	}
+	if stored == nil || stored.Value == nil || stored.Version != historyStoreVersion {
+		return false, nil
+	}
+
+	data, err := json.Marshal(stored.Value)
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("invalid saved history format: %w", err)
+	}
+	return true, nil
+}
+
+// storeHistoryValue writes v under key. A nil value clears the key.
+func storeHistoryValue(key string, v interface{}) error {
+	if err := plugin.StoreData(key, v, historyStoreVersion); err != nil {
+		return fmt.Errorf("failed to save history: %w", err)
+	}
+	return nil
+}
+
+// loadSession reads one session's history from plugin storage. A session
+// without a stored history gets an empty one.
+func loadSession(session string) (*sessionHistory, error) {
+	history := &sessionHistory{}
+	if _, err := loadStoredHistory(historySessionKey(session), history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// storeSession writes one session's history to plugin storage and marks
+// the session as the most recently used
+func storeSession(session string, history *sessionHistory) error {
+	if err := storeHistoryValue(historySessionKey(session), history); err != nil {
+		return err
+	}
+	return updateHistoryIndex(func(sessions []string) []string {
+		return promoteSession(sessions, session)
+	})
+}
+
+// deleteSession clears one session's history and drops it from the index
+func deleteSession(session string) error {
+	if err := storeHistoryValue(historySessionKey(session), nil); err != nil {
+		return err
+	}
+	return updateHistoryIndex(func(sessions []string) []string {
+		return removeSession(sessions, session)
+	})
+}
+
+// updateHistoryIndex applies update to the stored session list. The
+// index is only written when it changes, and the histories of sessions
+// beyond maxHistorySessions are cleared.
+func updateHistoryIndex(update func([]string) []string) error {
+	var index historyIndex
+	if _, err := loadStoredHistory(historyStorageKey, &index); err != nil {
+		return err
+	}
+	sessions := update(append([]string(nil), index.Sessions...))
+	if equalStrings(sessions, index.Sessions) {
+		return nil
+	}
+
+	if len(sessions) > maxHistorySessions {
+		for _, evicted := range sessions[maxHistorySessions:] {
+			if err := storeHistoryValue(historySessionKey(evicted), nil); err != nil {
+				return err
+			}
+		}
+		sessions = sessions[:maxHistorySessions]
+	}
+	return storeHistoryValue(historyStorageKey, historyIndex{Sessions: sessions, Version: historyStoreVersion})
+}
+
+// promoteSession moves session to the front of the list, adding it when
+// it is missing
+func promoteSession(sessions []string, session string) []string {
+	return append([]string{session}, removeSession(sessions, session)...)
+}
+
+// removeSession returns the list without session
+func removeSession(sessions []string, session string) []string {
+	kept := sessions[:0]
+	for _, name := range sessions {
+		if name != session {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
+
+// equalStrings reports whether two lists hold the same strings in order
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// enqueueHistory adds task to the history queue, starting the worker the
+// first time. It reports false when the queue is full and wait is unset.
+func enqueueHistory(task func(), wait bool) bool {
+	startHistoryWorker.Do(func() {
+		go func() {
+			for task := range historyQueue {
+				task()
+			}
+		}()
+	})
+	if wait {
+		historyQueue <- task
+		return true
+	}
+	select {
+	case historyQueue <- task:
+		return true
+	default:
+		return false
+	}
+}
+
+// inHistoryQueue runs fn on the history queue and waits for it, so it
+// sees every revision recorded before it
+func inHistoryQueue(fn func() error) error {
+	done := make(chan error, 1)
+	enqueueHistory(func() { done <- fn() }, true)
+	return <-done
+}
+
+// revisionIndex returns the position of the revision with the given ID,
+// or -1
+func (h *sessionHistory) revisionIndex(id int) int {
+	for i, r := range h.Revisions {
+		if r.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// current returns the current revision, or nil for an empty history
+func (h *sessionHistory) current() *Revision {
+	if i := h.revisionIndex(h.Current); i >= 0 {
+		return &h.Revisions[i]
+	}
+	return nil
+}
+
+// record adds a revision after the current one, discarding the revisions
+// that could have been redone. A document equal to the current one is not
+// recorded again.
+func (h *sessionHistory) record(operation, document string, now time.Time) {
+	if current := h.current(); current != nil {
+		if current.Document == document {
+			return
+		}
+		h.Revisions = h.Revisions[:h.revisionIndex(h.Current)+1]
+	}
+
+	h.NextID++
+	h.Revisions = append(h.Revisions, Revision{ID: h.NextID, Operation: operation, Document: document, CreatedAt: now})
+	h.Current = h.NextID
+	h.UpdatedAt = now
+
+	size := 0
+	for _, r := range h.Revisions {
+		size += len(r.Document)
+	}
+	for len(h.Revisions) > 1 && (len(h.Revisions) > maxHistoryRevisions || size > maxHistoryBytes) {
+		size -= len(h.Revisions[0].Document)
+		h.Revisions = h.Revisions[1:]
+	}
+}
+
+// state summarizes the history for a response
+func (h *sessionHistory) state(session string) HistoryState {
+	state := HistoryState{Session: session, Revisions: []RevisionSummary{}}
+	index := h.revisionIndex(h.Current)
+	if index >= 0 {
+		revision := h.Revisions[index]
+		state.Revision = &revision
+		state.CanUndo = index > 0
+		state.CanRedo = index < len(h.Revisions)-1
+	}
+	for _, r := range h.Revisions {
+		state.Revisions = append(state.Revisions, r.summary(r.ID == h.Current))
+	}
+	return state
+}
+
+func (r Revision) summary(current bool) RevisionSummary {
+	return RevisionSummary{ID: r.ID, Operation: r.Operation, Size: len(r.Document), CreatedAt: r.CreatedAt, Current: current}
+}
+
+// recordOperation adds the document produced by a successful operation to
+// the request's session history. The input is recorded first when it
+// differs from the current revision, so the operation can be undone.
+// Storing happens in the background; failures are logged rather than
+// failing or delaying the operation.
+func recordOperation(req OperationRequest, result interface{}) {
+	extract, ok := historyDocuments[req.Operation]
+	if req.Session == "" || !ok {
+		return
+	}
+	document := extract(result)
+	if document == "" || len(document) > maxHistoryBytes {
+		return
+	}
+
+	now := time.Now().UTC()
+	queued := enqueueHistory(func() {
+		history, err := loadSession(req.Session)
+		if err == nil {
+			if req.Input != "" && len(req.Input) <= maxHistoryBytes {
+				history.record(historyEditOperation, req.Input, now)
+			}
+			history.record(req.Operation, document, now)
+			err = storeSession(req.Session, history)
+		}
+		if err != nil {
+			log.Printf("History not recorded for %s: %v", req.Operation, err)
+		}
+	}, false)
+	if !queued {
+		log.Printf("History not recorded for %s: too many revisions waiting to be stored", req.Operation)
+	}
+}
+
+// withHistory loads the request's session history, runs fn on it and
+// stores the result when fn reports a change
+func withHistory(req OperationRequest, fn func(h *sessionHistory) (bool, error)) (*sessionHistory, error) {
+	if req.Session == "" {
+		return nil, fmt.Errorf("a session is required for %s", req.Operation)
+	}
+
+	var history *sessionHistory
+	err := inHistoryQueue(func() error {
+		var err error
+		if history, err = loadSession(req.Session); err != nil {
+			return err
+		}
+		changed, err := fn(history)
+		if err != nil || !changed {
+			return err
+		}
+		return storeSession(req.Session, history)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// runHistoryRecord records the input as a revision, labeled with the
+// given operation or as an edit
+func runHistoryRecord(req OperationRequest) (interface{}, error) {
+	var opts HistoryOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+	if opts.Operation == "" {
+		opts.Operation = historyEditOperation
+	}
+	if req.Input == "" {
+		return nil, fmt.Errorf("cannot record an empty document")
+	}
+	if len(req.Input) > maxHistoryBytes {
+		return nil, fmt.Errorf("document is too large for history (%d bytes, limit %d)", len(req.Input), maxHistoryBytes)
+	}
+
+	history, err := withHistory(req, func(h *sessionHistory) (bool, error) {
+		h.record(opts.Operation, req.Input, time.Now().UTC())
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return history.state(req.Session), nil
+}
+
+// runHistoryList returns the revisions of the session
+func runHistoryList(req OperationRequest) (interface{}, error) {
+	history, err := withHistory(req, func(*sessionHistory) (bool, error) { return false, nil })
+	if err != nil {
+		return nil, err
+	}
+	return history.state(req.Session), nil
+}
+
+// runHistoryGet returns one revision with its document
+func runHistoryGet(req OperationRequest) (interface{}, error) {
+	var opts HistoryOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+	history, err := withHistory(req, func(*sessionHistory) (bool, error) { return false, nil })
+	if err != nil {
+		return nil, err
+	}
+	i := history.revisionIndex(opts.ID)
+	if i < 0 {
+		return nil, fmt.Errorf("no revision %d in session %q", opts.ID, req.Session)
+	}
+	return history.Revisions[i], nil
+}
+
+// runHistoryUndo makes the revision before the current one current
+func runHistoryUndo(req OperationRequest) (interface{}, error) {
+	return stepHistory(req, -1)
+}
+
+// runHistoryRedo makes the revision after the current one current
+func runHistoryRedo(req OperationRequest) (interface{}, error) {
+	return stepHistory(req, 1)
+}
+
+// stepHistory moves the current revision by step
+func stepHistory(req OperationRequest, step int) (interface{}, error) {
+	history, err := withHistory(req, func(h *sessionHistory) (bool, error) {
+		i := h.revisionIndex(h.Current) + step
+		if h.revisionIndex(h.Current) < 0 || i < 0 || i >= len(h.Revisions) {
+			if step < 0 {
+				return false, fmt.Errorf("nothing to undo")
+			}
+			return false, fmt.Errorf("nothing to redo")
+		}
+		h.Current = h.Revisions[i].ID
+		h.UpdatedAt = time.Now().UTC()
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return history.state(req.Session), nil
+}
+
+// runHistoryClear deletes the session's history
+func runHistoryClear(req OperationRequest) (interface{}, error) {
+	if req.Session == "" {
+		return nil, fmt.Errorf("a session is required for %s", req.Operation)
+	}
+
+	existed := false
+	err := inHistoryQueue(func() error {
+		var history sessionHistory
+		var err error
+		if existed, err = loadStoredHistory(historySessionKey(req.Session), &history); err != nil || !existed {
+			return err
+		}
+		return deleteSession(req.Session)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"session": req.Session, "cleared": existed}, nil
+}
+
+// runHistoryDiff compares two revisions of the session
+func runHistoryDiff(req OperationRequest) (interface{}, error) {
+	var opts HistoryDiffOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+	switch opts.Arrays {
+	case "":
+		opts.Arrays = arraysOrdered
+	case arraysOrdered, arraysSet:
+	default:
+		return nil, fmt.Errorf("invalid arrays mode %q (expected ordered or set)", opts.Arrays)
+	}
+	for _, pattern := range opts.IgnorePaths {
+		if _, err := splitPointer(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	history, err := withHistory(req, func(*sessionHistory) (bool, error) { return false, nil })
+	if err != nil {
+		return nil, err
+	}
+	current := history.revisionIndex(history.Current)
+	if current < 0 {
+		return nil, fmt.Errorf("session %q has no history", req.Session)
+	}
+	if opts.To == 0 {
+		opts.To = history.Current
+	}
+	if opts.From == 0 {
+		if current == 0 {
+			return nil, fmt.Errorf("the current revision has no earlier revision to compare with")
+		}
+		opts.From = history.Revisions[current-1].ID
+	}
+
+	var revisions [2]Revision
+	for i, id := range []int{opts.From, opts.To} {
+		index := history.revisionIndex(id)
+		if index < 0 {
+			return nil, fmt.Errorf("no revision %d in session %q", id, req.Session)
+		}
+		revisions[i] = history.Revisions[index]
+	}
+
+	left, err := parseTree(revisions[0].Document)
+	if err != nil {
+		return nil, fmt.Errorf("revision %d is not valid JSON: %w", revisions[0].ID, err)
+	}
+	right, err := parseTree(revisions[1].Document)
+	if err != nil {
+		return nil, fmt.Errorf("revision %d is not valid JSON: %w", revisions[1].ID, err)
+	}
+
+	diff := diffTrees(left, right, DiffOptions{IgnorePaths: opts.IgnorePaths, Arrays: opts.Arrays, IdentityKey: opts.IdentityKey})
+	return HistoryDiffResult{
+		From:       revisions[0].summary(revisions[0].ID == history.Current),
+		To:         revisions[1].summary(revisions[1].ID == history.Current),
+		DiffResult: diff,
+	}, nil
+}
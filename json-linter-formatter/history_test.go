@@ -0,0 +1,204 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// revisionDocuments lists the documents of a history in order
+func revisionDocuments(h *sessionHistory) []string {
+	var docs []string
+	for _, r := range h.Revisions {
+		docs = append(docs, r.Document)
+	}
+	return docs
+}
+
+func TestSessionHistoryRecord(t *testing.T) {
+	now := time.Now().UTC()
+	tests := []struct {
+		name    string
+		records []string
+		undo    int
+		then    []string
+		want    []string
+		current string
+	}{
+		{
+			name:    "appends revisions",
+			records: []string{"1", "2", "3"},
+			want:    []string{"1", "2", "3"},
+			current: "3",
+		},
+		{
+			name:    "skips a document equal to the current one",
+			records: []string{"1", "1", "2", "2"},
+			want:    []string{"1", "2"},
+			current: "2",
+		},
+		{
+			name:    "recording after undo drops the redo branch",
+			records: []string{"1", "2", "3"},
+			undo:    2,
+			then:    []string{"4"},
+			want:    []string{"1", "4"},
+			current: "4",
+		},
+		{
+			name:    "recording the undone document again keeps the branch",
+			records: []string{"1", "2"},
+			undo:    1,
+			then:    []string{"1"},
+			want:    []string{"1", "2"},
+			current: "1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &sessionHistory{}
+			for _, doc := range tt.records {
+				h.record("format", doc, now)
+			}
+			for i := 0; i < tt.undo; i++ {
+				h.Current = h.Revisions[h.revisionIndex(h.Current)-1].ID
+			}
+			for _, doc := range tt.then {
+				h.record("format", doc, now)
+			}
+			if got := revisionDocuments(h); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("revisions = %v, want %v", got, tt.want)
+			}
+			if current := h.current(); current == nil || current.Document != tt.current {
+				t.Errorf("current = %+v, want %q", current, tt.current)
+			}
+		})
+	}
+}
+
+func TestSessionHistoryTrims(t *testing.T) {
+	now := time.Now().UTC()
+
+	h := &sessionHistory{}
+	for i := 0; i < maxHistoryRevisions+5; i++ {
+		h.record("edit", strings.Repeat("x", i+1), now)
+	}
+	if len(h.Revisions) != maxHistoryRevisions || h.Revisions[0].ID != 6 {
+		t.Errorf("kept %d revisions starting at %d, want %d starting at 6", len(h.Revisions), h.Revisions[0].ID, maxHistoryRevisions)
+	}
+
+	h = &sessionHistory{}
+	big := strings.Repeat("x", maxHistoryBytes/2+1)
+	h.record("edit", big+"a", now)
+	h.record("edit", big+"b", now)
+	if len(h.Revisions) != 1 || h.Revisions[0].Document != big+"b" {
+		t.Errorf("kept %d revisions, want only the newest", len(h.Revisions))
+	}
+}
+
+func TestSessionHistoryState(t *testing.T) {
+	now := time.Now().UTC()
+	h := &sessionHistory{}
+	if state := h.state("s"); state.Revision != nil || state.CanUndo || state.CanRedo || len(state.Revisions) != 0 {
+		t.Errorf("empty state = %+v", state)
+	}
+
+	h.record("edit", "[1]", now)
+	h.record("minify", "[2]", now)
+	h.record("format", "[3]", now)
+	h.Current = 2
+
+	state := h.state("s")
+	if state.Session != "s" || state.Revision == nil || state.Revision.Document != "[2]" || !state.CanUndo || !state.CanRedo {
+		t.Errorf("state = %+v", state)
+	}
+	want := []RevisionSummary{
+		{ID: 1, Operation: "edit", Size: 3, CreatedAt: now},
+		{ID: 2, Operation: "minify", Size: 3, CreatedAt: now, Current: true},
+		{ID: 3, Operation: "format", Size: 3, CreatedAt: now},
+	}
+	if !reflect.DeepEqual(state.Revisions, want) {
+		t.Errorf("revisions = %+v, want %+v", state.Revisions, want)
+	}
+}
+
+func TestHistoryIndexUpdates(t *testing.T) {
+	tests := []struct {
+		name     string
+		sessions []string
+		update   func([]string) []string
+		want     []string
+	}{
+		{"promote a new session", []string{"a", "b"}, func(s []string) []string { return promoteSession(s, "c") }, []string{"c", "a", "b"}},
+		{"promote an existing session", []string{"a", "b", "c"}, func(s []string) []string { return promoteSession(s, "b") }, []string{"b", "a", "c"}},
+		{"promote the first session", []string{"a", "b"}, func(s []string) []string { return promoteSession(s, "a") }, []string{"a", "b"}},
+		{"remove a session", []string{"a", "b", "c"}, func(s []string) []string { return removeSession(s, "b") }, []string{"a", "c"}},
+		{"remove a missing session", []string{"a"}, func(s []string) []string { return removeSession(s, "x") }, []string{"a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.update(tt.sessions); !equalStrings(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistorySessionKey(t *testing.T) {
+	if got := historySessionKey("tab-1"); got != "json_history:tab-1" {
+		t.Errorf("historySessionKey = %q", got)
+	}
+}
+
+func TestHistoryOperationErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		run     func(OperationRequest) (interface{}, error)
+		session string
+		input   string
+		want    string
+	}{
+		{"list needs a session", runHistoryList, "", "", "session is required"},
+		{"clear needs a session", runHistoryClear, "", "", "session is required"},
+		{"record needs a document", runHistoryRecord, "s", "", "empty document"},
+		{"record rejects large documents", runHistoryRecord, "s", strings.Repeat(" ", maxHistoryBytes+1), "too large"},
+		{"undo without storage", runHistoryUndo, "s", "", "storage not available"},
+		{"clear without storage", runHistoryClear, "s", "", "storage not available"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.run(OperationRequest{Operation: "history", Session: tt.session, Input: tt.input})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordOperationDoesNotBlockResponses(t *testing.T) {
+	release := make(chan struct{})
+	enqueueHistory(func() { <-release }, true)
+	defer close(release)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < historyQueueSize*2; i++ {
+			req := OperationRequest{Operation: "minify", Session: "s", Input: `{"a": 1}`}
+			if _, err := dispatchOperation(req); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("operations waited for history to be stored")
+	}
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// defaultMaxEnumValues is the largest number of distinct strings turned
+// into an enum
+const defaultMaxEnumValues = 5
+
+// inferredFormats are the string formats detected, in order of preference
+var inferredFormats = []string{"date-time", "date", "uuid", "email", "uri"}
+
+// schemaURIs maps drafts to their $schema URIs
+var schemaURIs = map[string]string{
+	SchemaDraft07:   "http://json-schema.org/draft-07/schema#",
+	SchemaDraft2020: "https://json-schema.org/draft/2020-12/schema",
+}
+
+// InferSchemaOptions are the options of the inferSchema operation. Samples
+// come from the input (one document, or one per line when JSONLines is
+// set), from Samples and from stored snippets named in Snippets.
+type InferSchemaOptions struct {
+	Samples   []json.RawMessage `json:"samples"`
+	Snippets  []string          `json:"snippets"`
+	JSONLines bool              `json:"jsonLines"`
+	Draft     string            `json:"draft"`
+	// MaxEnumValues limits enum detection; a negative value disables it
+	MaxEnumValues int `json:"maxEnumValues"`
+	// SaveAs stores the inferred schema under this name for validateSchema
+	SaveAs string `json:"saveAs"`
+}
+
+// InferSchemaResult is the result of the inferSchema operation
+type InferSchemaResult struct {
+	Draft   string `json:"draft"`
+	Samples int    `json:"samples"`
+	Schema  string `json:"schema"`
+}
+
+// shape accumulates what has been seen at one location across samples
+type shape struct {
+	types map[string]int
+
+	strings     int
+	distinct    map[string]bool
+	formatCount map[string]int
+
+	// minInt and maxInt bound the integer literals observed
+	minInt, maxInt *big.Int
+
+	objects   int
+	propOrder []string
+	props     map[string]*shape
+	propSeen  map[string]int
+
+	items *shape
+}
+
+func newShape() *shape {
+	return &shape{
+		types:       make(map[string]int),
+		distinct:    make(map[string]bool),
+		formatCount: make(map[string]int),
+		props:       make(map[string]*shape),
+		propSeen:    make(map[string]int),
+	}
+}
+
+// observe merges one value into the shape
+func (s *shape) observe(n *node, maxEnum int) {
+	switch n.Kind {
+	case nodeObject:
+		s.types["object"]++
+		s.objects++
+		keys, values := lastMembers(n)
+		for _, key := range keys {
+			prop, ok := s.props[key]
+			if !ok {
+				prop = newShape()
+				s.props[key] = prop
+				s.propOrder = append(s.propOrder, key)
+			}
+			s.propSeen[key]++
+			prop.observe(values[key], maxEnum)
+		}
+	case nodeArray:
+		s.types["array"]++
+		for _, item := range n.Items {
+			if s.items == nil {
+				s.items = newShape()
+			}
+			s.items.observe(item, maxEnum)
+		}
+	case nodeString:
+		s.types["string"]++
+		s.strings++
+		str, _ := n.value().(string)
+		if len(s.distinct) <= maxEnum {
+			s.distinct[str] = true
+		}
+		for _, format := range inferredFormats {
+			if format == "uri" && !strings.Contains(str, "://") {
+				continue
+			}
+			if checkFormat(format, str) == "" {
+				s.formatCount[format]++
+				break
+			}
+		}
+	case nodeNumber:
+		if isIntegerLiteral(n.Raw) {
+			s.types["integer"]++
+			s.observeInteger(n.Raw)
+		} else {
+			s.types["number"]++
+		}
+	case nodeBool:
+		s.types["boolean"]++
+	default:
+		s.types["null"]++
+	}
+}
+
+// observeInteger widens the integer bounds to include an integer literal
+func (s *shape) observeInteger(raw string) {
+	i, _ := new(big.Int).SetString(raw, 10)
+	if s.minInt == nil || i.Cmp(s.minInt) < 0 {
+		s.minInt = i
+	}
+	if s.maxInt == nil || i.Cmp(s.maxInt) > 0 {
+		s.maxInt = i
+	}
+}
+
+// isIntegerLiteral reports whether a number literal is written as an
+// integer. 1.0 and 1e3 are integral values but are spelled as numbers, and
+// typing them as integers would make generated Go fields unable to hold them.
+func isIntegerLiteral(raw string) bool {
+	_, ok := new(big.Int).SetString(raw, 10)
+	return ok
+}
+
+// schema renders the shape as a JSON Schema fragment
+func (s *shape) schema(maxEnum int) orderedObject {
+	var out orderedObject
+
+	var types []interface{}
+	for _, t := range []string{"object", "array", "string", "integer", "number", "boolean", "null"} {
+		if s.types[t] == 0 || (t == "integer" && s.types["number"] > 0) {
+			continue
+		}
+		types = append(types, t)
+	}
+	switch len(types) {
+	case 0:
+	case 1:
+		out = append(out, orderedField{"type", types[0]})
+	default:
+		out = append(out, orderedField{"type", types})
+	}
+
+	if s.strings > 0 {
+		format := ""
+		for _, f := range inferredFormats {
+			if s.formatCount[f] == s.strings {
+				format = f
+				break
+			}
+		}
+		onlyStrings := s.types["string"]+s.types["null"] == s.count()
+
+		switch {
+		case format != "":
+			out = append(out, orderedField{"format", format})
+		case onlyStrings && len(s.distinct) <= maxEnum && s.strings > len(s.distinct):
+			values := make([]interface{}, 0, len(s.distinct)+1)
+			for _, v := range sortedKeys(s.distinct) {
+				values = append(values, v)
+			}
+			if s.types["null"] > 0 {
+				values = append(values, nil)
+			}
+			out = append(out, orderedField{"enum", values})
+		}
+	}
+
+	if s.objects > 0 {
+		props := orderedObject{}
+		var required []interface{}
+		for _, key := range s.propOrder {
+			props = append(props, orderedField{key, s.props[key].schema(maxEnum)})
+			if s.propSeen[key] == s.objects {
+				required = append(required, key)
+			}
+		}
+		out = append(out, orderedField{"properties", props})
+		if len(required) > 0 {
+			out = append(out, orderedField{"required", required})
+		}
+	}
+
+	if s.items != nil {
+		out = append(out, orderedField{"items", s.items.schema(maxEnum)})
+	}
+	return out
+}
+
+// count returns the number of values observed
+func (s *shape) count() int {
+	total := 0
+	for _, n := range s.types {
+		total += n
+	}
+	return total
+}
+
+// sortedKeys returns the keys of a set in sorted order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// orderedObject is a JSON object that marshals its fields in order
+type orderedObject []orderedField
+
+// orderedField is one member of an orderedObject
+type orderedField struct {
+	Key   string
+	Value interface{}
+}
+
+// MarshalJSON writes the fields in their given order
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(f.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// collectSamples parses every sample named by the options
+func (o InferSchemaOptions) collectSamples(input string) ([]*node, error) {
+	var texts []string
+	if strings.TrimSpace(input) != "" {
+		if o.JSONLines {
+			var lines JSONLinesResult
+			records := splitJSONLines(input, &lines)
+			if lines.ErrorCount > 0 {
+				first := lines.Errors[0]
+				return nil, fmt.Errorf("invalid JSON on line %d: %s", first.LineNumber, first.Message)
+			}
+			for _, record := range records {
+				texts = append(texts, record.text)
+			}
+		} else {
+			texts = append(texts, input)
+		}
+	}
+	for i, raw := range o.Samples {
+		text, err := documentOption(raw, fmt.Sprintf("samples[%d]", i))
+		if err != nil {
+			return nil, err
+		}
+		texts = append(texts, text)
+	}
+	for _, name := range o.Snippets {
+		text, err := loadSnippetJSON(name)
+		if err != nil {
+			return nil, err
+		}
+		texts = append(texts, text)
+	}
+
+	samples := make([]*node, 0, len(texts))
+	for i, text := range texts {
+		root, err := parseTree(text)
+		if err != nil {
+			return nil, fmt.Errorf("sample %d is not valid JSON: %w", i+1, err)
+		}
+		samples = append(samples, root)
+	}
+	return samples, nil
+}
+
+// runInferSchema generates a JSON Schema that the samples satisfy
+func runInferSchema(req OperationRequest) (interface{}, error) {
+	var opts InferSchemaOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+
+	draft := opts.Draft
+	if draft == "" {
+		draft = SchemaDraft2020
+	}
+	uri, ok := schemaURIs[draft]
+	if !ok {
+		return nil, fmt.Errorf("unsupported schema draft %q (supported: %s, %s)", draft, SchemaDraft07, SchemaDraft2020)
+	}
+	maxEnum := opts.MaxEnumValues
+	if maxEnum == 0 {
+		maxEnum = defaultMaxEnumValues
+	}
+
+	samples, err := opts.collectSamples(req.Input)
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no samples given: provide input, samples or snippets")
+	}
+
+	root := newShape()
+	for _, sample := range samples {
+		root.observe(sample, maxEnum)
+	}
+	schema := append(orderedObject{{"$schema", uri}}, root.schema(maxEnum)...)
+
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	text, err := formatTokens(string(data), currentFormatSettings().style())
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.SaveAs != "" {
+		if err := storeSchema(opts.SaveAs, text); err != nil {
+			return nil, err
+		}
+	}
+
+	return InferSchemaResult{Draft: draft, Samples: len(samples), Schema: text}, nil
+}
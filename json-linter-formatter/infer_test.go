@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRunInferSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		options string
+		want    map[string]string
+	}{
+		{
+			name:    "types and required members",
+			input:   "{\"id\": 1, \"name\": \"a\", \"tags\": [\"x\"]}\n{\"id\": 2, \"name\": \"b\", \"score\": 1.5}",
+			options: `{"jsonLines": true}`,
+			want: map[string]string{
+				"/properties/id/type":         `"integer"`,
+				"/properties/name/type":       `"string"`,
+				"/properties/tags/items/type": `"string"`,
+				"/properties/score/type":      `"number"`,
+				"/required":                   `["id","name"]`,
+			},
+		},
+		{
+			name:    "enum and formats",
+			options: `{"samples": [{"s": "on", "at": "2024-01-02T03:04:05Z", "id": "123e4567-e89b-12d3-a456-426614174000"}, {"s": "off", "at": "2024-02-02T00:00:00Z", "id": "123e4567-e89b-12d3-a456-426614174001"}, {"s": "on", "at": "2024-03-02T00:00:00Z", "id": "123e4567-e89b-12d3-a456-426614174002"}]}`,
+			want: map[string]string{
+				"/properties/s/enum":    `["off","on"]`,
+				"/properties/at/format": `"date-time"`,
+				"/properties/id/format": `"uuid"`,
+			},
+		},
+		{
+			name:    "mixed types",
+			options: `{"samples": [[1, "a", null]], "maxEnumValues": -1}`,
+			want: map[string]string{
+				"/items/type": `["string","integer","null"]`,
+			},
+		},
+		{
+			name:    "draft-07",
+			input:   `{"a": true}`,
+			options: `{"draft": "draft-07"}`,
+			want: map[string]string{
+				"/$schema":           `"http://json-schema.org/draft-07/schema#"`,
+				"/properties/a/type": `"boolean"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runForTest[InferSchemaResult](t, runInferSchema, tt.input, tt.options)
+			schema := decodeForTest(t, result.Schema)
+			for pointer, want := range tt.want {
+				value, err := resolvePointer(schema, pointer)
+				if err != nil {
+					t.Errorf("%s: %v", pointer, err)
+					continue
+				}
+				if got := canonicalForTest(t, value); got != canonicalForTest(t, decodeForTest(t, want)) {
+					t.Errorf("%s = %s, want %s", pointer, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestInferredSchemaAcceptsItsSamples(t *testing.T) {
+	samples := []string{
+		`{"id": 1, "price": 2.5, "tags": ["a"], "owner": {"name": "x", "email": "x@example.com"}}`,
+		`{"id": 2, "price": 3, "tags": [], "owner": null}`,
+		`{"id": 12345678901234567890, "price": 1e2, "tags": ["b", "c"]}`,
+	}
+	result := runForTest[InferSchemaResult](t, runInferSchema, strings.Join(samples, "\n"), `{"jsonLines": true}`)
+	schema := decodeForTest(t, result.Schema)
+
+	for _, sample := range samples {
+		doc, err := decodeJSON([]byte(sample))
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := validateAgainstSchema(doc, schema, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.IsValid {
+			t.Errorf("sample %s violates the inferred schema: %+v", sample, result.Violations)
+		}
+	}
+}
+
+func TestRunInferSchemaErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		options string
+	}{
+		{"no samples", "", `{}`},
+		{"unsupported draft", `{}`, `{"draft": "draft-04"}`},
+		{"invalid sample", `{"a": }`, `{}`},
+		{"invalid JSON Lines", "{}\n{", `{"jsonLines": true}`},
+		{"save without storage", `{}`, `{"saveAs": "inferred"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := runInferSchema(OperationRequest{Input: tt.input, Options: []byte(tt.options)}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func decodeForTest(t *testing.T, text string) interface{} {
+	t.Helper()
+	value, err := decodeJSON([]byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func canonicalForTest(t *testing.T, value interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestIsIntegerLiteral(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want bool
+	}{
+		{"0", true},
+		{"-42", true},
+		{"12345678901234567890", true},
+		{"1.0", false},
+		{"1.5", false},
+		{"1e3", false},
+		{"1E3", false},
+		{"-2e-1", false},
+	}
+
+	for _, tt := range tests {
+		if got := isIntegerLiteral(tt.raw); got != tt.want {
+			t.Errorf("isIntegerLiteral(%q) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
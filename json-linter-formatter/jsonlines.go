@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// maxLineErrors caps the number of per-line errors returned, so a file of
+// plain text does not produce one error per line
+const maxLineErrors = 100
+
+// JSON Lines actions
+const (
+	jsonLinesValidate  = "validate"
+	jsonLinesFormat    = "format"
+	jsonLinesMinify    = "minify"
+	jsonLinesToArray   = "toArray"
+	jsonLinesFromArray = "fromArray"
+)
+
+// JSONLinesOptions are the options of the jsonLines operation
+type JSONLinesOptions struct {
+	// Action is validate (the default), format, minify, toArray or fromArray
+	Action string `json:"action"`
+}
+
+// JSONLinesError is a problem with a single line of a JSON Lines document
+type JSONLinesError struct {
+	LineNumber int    `json:"lineNumber"`
+	Column     int    `json:"column,omitempty"`
+	Message    string `json:"message"`
+	Excerpt    string `json:"excerpt,omitempty"`
+}
+
+// JSONLinesResult is the result of the jsonLines operation. Output is only
+// set when every record is valid.
+type JSONLinesResult struct {
+	IsValid    bool             `json:"isValid"`
+	Records    int              `json:"records"`
+	BlankLines int              `json:"blankLines"`
+	ErrorCount int              `json:"errorCount"`
+	Errors     []JSONLinesError `json:"errors"`
+	Output     string           `json:"output,omitempty"`
+}
+
+// jsonLinesRecord is one non-blank line of a JSON Lines document
+type jsonLinesRecord struct {
+	lineNumber int
+	text       string
+}
+
+// splitJSONLines splits src into records, validating each on its own
+func splitJSONLines(src string, result *JSONLinesResult) []jsonLinesRecord {
+	var records []jsonLinesRecord
+
+	// A final newline terminates the last record rather than adding a line
+	src = strings.TrimSuffix(src, "\n")
+	for i, line := range strings.Split(src, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			result.BlankLines++
+			continue
+		}
+
+		var raw json.RawMessage
+		if err := json.Unmarshal([]byte(line), &raw); err != nil {
+			result.addError(i+1, line, err)
+			continue
+		}
+		records = append(records, jsonLinesRecord{lineNumber: i + 1, text: line})
+	}
+	result.Records = len(records) + result.ErrorCount
+	return records
+}
+
+// addError records a decoding error for a line
+func (r *JSONLinesResult) addError(lineNumber int, line string, err error) {
+	r.ErrorCount++
+	if len(r.Errors) >= maxLineErrors {
+		return
+	}
+
+	lineErr := JSONLinesError{LineNumber: lineNumber, Message: err.Error()}
+	if pos, ok := errorPosition(line, err); ok {
+		lineErr.Column = pos.Column
+		lineErr.Excerpt = sourceExcerpt(line, pos)
+	}
+	r.Errors = append(r.Errors, lineErr)
+}
+
+// runJSONLines validates or converts a newline-delimited JSON document
+func runJSONLines(req OperationRequest) (interface{}, error) {
+	var opts JSONLinesOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+
+	switch opts.Action {
+	case "", jsonLinesValidate, jsonLinesFormat, jsonLinesMinify, jsonLinesToArray, jsonLinesFromArray:
+	default:
+		return nil, fmt.Errorf("invalid action %q (expected validate, format, minify, toArray or fromArray)", opts.Action)
+	}
+
+	result := JSONLinesResult{Errors: []JSONLinesError{}}
+	if opts.Action == jsonLinesFromArray {
+		output, count, err := arrayToJSONLines(req.Input)
+		if err != nil {
+			return nil, err
+		}
+		result.IsValid = true
+		result.Records = count
+		result.Output = output
+		return result, nil
+	}
+
+	records := splitJSONLines(req.Input, &result)
+	result.IsValid = result.ErrorCount == 0
+	if !result.IsValid {
+		return result, nil
+	}
+
+	style := currentFormatSettings().style()
+	var out strings.Builder
+	switch opts.Action {
+	case "", jsonLinesValidate:
+	case jsonLinesFormat:
+		for _, record := range records {
+			if err := formatLine(&out, record.text, style); err != nil {
+				return nil, fmt.Errorf("line %d: %w", record.lineNumber, err)
+			}
+		}
+	case jsonLinesMinify:
+		for _, record := range records {
+			if err := compactLine(&out, record.text); err != nil {
+				return nil, fmt.Errorf("line %d: %w", record.lineNumber, err)
+			}
+		}
+	case jsonLinesToArray:
+		var array strings.Builder
+		array.WriteByte('[')
+		for i, record := range records {
+			if i > 0 {
+				array.WriteByte(',')
+			}
+			array.WriteString(record.text)
+		}
+		array.WriteByte(']')
+
+		formatted, err := formatTokens(array.String(), style)
+		if err != nil {
+			return nil, err
+		}
+		out.WriteString(formatted)
+	}
+
+	result.Output = out.String()
+	return result, nil
+}
+
+// formatLine writes text on a single line with one space after each colon
+// and comma, followed by a newline. Records must stay on one line for the
+// output to remain valid JSON Lines, so only the style's HTML escaping is
+// applied.
+func formatLine(out *strings.Builder, text string, style formatStyle) error {
+	tokens, err := collectTokens(newTokenizer(text))
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens[:len(tokens)-1] {
+		switch {
+		case t.Kind == tokenColon || t.Kind == tokenComma:
+			out.WriteString(t.Text + " ")
+		case t.Kind == tokenString && style.EscapeHTML:
+			out.WriteString(escapeHTML(t.Text))
+		default:
+			out.WriteString(t.Text)
+		}
+	}
+	out.WriteByte('\n')
+	return nil
+}
+
+// compactLine writes text without insignificant whitespace, followed by a
+// newline
+func compactLine(out *strings.Builder, text string) error {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(text)); err != nil {
+		return err
+	}
+	out.Write(buf.Bytes())
+	out.WriteByte('\n')
+	return nil
+}
+
+// arrayToJSONLines writes each element of a JSON array on its own line
+func arrayToJSONLines(src string) (string, int, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal([]byte(src), &items); err != nil {
+		if _, ok := err.(*json.UnmarshalTypeError); !ok {
+			return "", 0, fmt.Errorf("invalid JSON: %w", err)
+		}
+		items = nil
+	}
+	if !strings.HasPrefix(strings.TrimSpace(src), "[") {
+		return "", 0, fmt.Errorf("fromArray needs a JSON array as input")
+	}
+
+	var out strings.Builder
+	for i, item := range items {
+		if err := compactLine(&out, string(item)); err != nil {
+			return "", 0, fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+	return out.String(), len(items), nil
+}
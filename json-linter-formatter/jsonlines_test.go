@@ -0,0 +1,130 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRunJSONLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  string
+		input   string
+		records int
+		blank   int
+		output  string
+	}{
+		{
+			name:    "validate",
+			input:   "{\"a\": 1}\n\n[1, 2]\r\n\"s\"\n",
+			records: 3,
+			blank:   1,
+		},
+		{
+			name:    "format keeps one record per line",
+			action:  jsonLinesFormat,
+			input:   "{\"a\":1,\"b\":[1,{\"c\":\"\\u00e9\"}]}\n[ ]\n",
+			records: 2,
+			output:  "{\"a\": 1, \"b\": [1, {\"c\": \"\\u00e9\"}]}\n[]\n",
+		},
+		{
+			name:    "minify",
+			action:  jsonLinesMinify,
+			input:   "{ \"a\" : 1 }\n[ 1, 2 ]",
+			records: 2,
+			output:  "{\"a\":1}\n[1,2]\n",
+		},
+		{
+			name:    "to array",
+			action:  jsonLinesToArray,
+			input:   "1\n{\"a\": 2}\n",
+			records: 2,
+			output:  "[\n  1,\n  {\n    \"a\": 2\n  }\n]",
+		},
+		{
+			name:    "from array",
+			action:  jsonLinesFromArray,
+			input:   "[\n  {\"a\": 1},\n  [2, 3]\n]",
+			records: 2,
+			output:  "{\"a\":1}\n[2,3]\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := OperationRequest{Operation: "jsonLines", Input: tt.input, Options: []byte(`{"action": "` + tt.action + `"}`)}
+			value, err := runJSONLines(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := value.(JSONLinesResult)
+			if !result.IsValid {
+				t.Fatalf("errors = %+v", result.Errors)
+			}
+			if result.Records != tt.records || result.BlankLines != tt.blank {
+				t.Errorf("records = %d, blank = %d, want %d, %d", result.Records, result.BlankLines, tt.records, tt.blank)
+			}
+			if result.Output != tt.output {
+				t.Errorf("output =\n%q\nwant\n%q", result.Output, tt.output)
+			}
+		})
+	}
+}
+
+func TestJSONLinesFormatOutputIsValidJSONLines(t *testing.T) {
+	input := "{\"a\": {\"b\": [1, 2]}}\n[{\"c\": null}]\n"
+	formatted, err := runJSONLines(OperationRequest{Input: input, Options: []byte(`{"action": "format"}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revalidated, err := runJSONLines(OperationRequest{Input: formatted.(JSONLinesResult).Output})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := revalidated.(JSONLinesResult)
+	if !result.IsValid || result.Records != 2 {
+		t.Errorf("formatted output did not validate as JSON Lines: %+v", result)
+	}
+}
+
+func TestRunJSONLinesReportsLineErrors(t *testing.T) {
+	input := "{\"ok\": true}\n{\"a\": }\n\nnot json\n[1]\n"
+	value, err := runJSONLines(OperationRequest{Input: input})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := value.(JSONLinesResult)
+	if result.IsValid || result.ErrorCount != 2 || result.Records != 4 || result.Output != "" {
+		t.Fatalf("result = %+v", result)
+	}
+
+	var got [][2]int
+	for _, e := range result.Errors {
+		got = append(got, [2]int{e.LineNumber, e.Column})
+	}
+	if want := [][2]int{{2, 7}, {4, 2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("error positions = %v, want %v", got, want)
+	}
+}
+
+func TestRunJSONLinesErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		action string
+		input  string
+	}{
+		{"unknown action", "pretty", "1\n"},
+		{"from array with an object", jsonLinesFromArray, `{"a": 1}`},
+		{"from array with invalid JSON", jsonLinesFromArray, `[1,`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := OperationRequest{Operation: "jsonLines", Input: tt.input, Options: []byte(`{"action": "` + tt.action + `"}`)}
+			if _, err := runJSONLines(req); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,577 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Extensions recognised by the lenient parser
+const (
+	extComments        = "comments"
+	extTrailingCommas  = "trailingCommas"
+	extSingleQuotes    = "singleQuotes"
+	extUnquotedKeys    = "unquotedKeys"
+	extExtendedEscapes = "extendedEscapes"
+	extJSON5Numbers    = "json5Numbers"
+	extJSON5Whitespace = "json5Whitespace"
+)
+
+// LenientOptions are the options of the lenient operation
+type LenientOptions struct {
+	// Output is "json" for strict JSON or "jsonc" to keep comments
+	Output string `json:"output,omitempty"`
+}
+
+// LenientResult is the result of parsing a JSONC or JSON5 document
+type LenientResult struct {
+	IsValid      bool     `json:"isValid"`
+	Dialect      string   `json:"dialect"`
+	Extensions   []string `json:"extensions"`
+	Output       string   `json:"output,omitempty"`
+	ErrorMessage string   `json:"errorMessage,omitempty"`
+	LineNumber   int      `json:"lineNumber,omitempty"`
+	Column       int      `json:"column,omitempty"`
+	Offset       int      `json:"offset,omitempty"`
+	Excerpt      string   `json:"excerpt,omitempty"`
+}
+
+// lenientScanner reads JSONC and JSON5 tokens. Every token's Text is
+// rewritten to its strict JSON spelling, while Offset still points into
+// the original source.
+type lenientScanner struct {
+	src        string
+	pos        int
+	extensions map[string]bool
+}
+
+// scanRaw reads the next token, including comments and bare identifiers.
+// newline reports whether a line break preceded the token.
+func (l *lenientScanner) scanRaw() (tok token, newline bool, err error) {
+	newline = l.skipWhitespace()
+	if l.pos >= len(l.src) {
+		return token{Offset: l.pos}, newline, io.EOF
+	}
+
+	start := l.pos
+	single := func(kind tokenKind) (token, bool, error) {
+		l.pos++
+		return token{Kind: kind, Text: l.src[start:l.pos], Offset: start}, newline, nil
+	}
+
+	c := l.src[l.pos]
+	switch {
+	case c == '{':
+		return single(tokenBeginObject)
+	case c == '}':
+		return single(tokenEndObject)
+	case c == '[':
+		return single(tokenBeginArray)
+	case c == ']':
+		return single(tokenEndArray)
+	case c == ':':
+		return single(tokenColon)
+	case c == ',':
+		return single(tokenComma)
+	case c == '/':
+		text, err := l.scanComment()
+		return token{Kind: tokenComment, Text: text, Offset: start}, newline, err
+	case c == '"' || c == '\'':
+		text, err := l.scanString(c)
+		return token{Kind: tokenString, Text: text, Offset: start}, newline, err
+	case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+		text, err := l.scanNumber()
+		return token{Kind: tokenNumber, Text: text, Offset: start}, newline, err
+	case isIdentifierStart(c):
+		for l.pos < len(l.src) && isIdentifierPart(l.src[l.pos]) {
+			l.pos++
+		}
+		word := l.src[start:l.pos]
+		switch word {
+		case "true", "false", "null":
+			return token{Kind: tokenLiteral, Text: word, Offset: start}, newline, nil
+		case "Infinity", "NaN":
+			return token{Offset: start}, newline, &scanError{Offset: start, Msg: word + " cannot be represented in JSON"}
+		}
+		return token{Kind: tokenIdentifier, Text: word, Offset: start}, newline, nil
+	default:
+		r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+		return token{Offset: start}, newline, &scanError{Offset: start, Msg: fmt.Sprintf("invalid character %q", r)}
+	}
+}
+
+// skipWhitespace skips JSON and JSON5 whitespace and reports whether a
+// line break was crossed
+func (l *lenientScanner) skipWhitespace() bool {
+	newline := false
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; c {
+		case ' ', '\t', '\r':
+			l.pos++
+		case '\n':
+			newline = true
+			l.pos++
+		case '\v', '\f':
+			l.extensions[extJSON5Whitespace] = true
+			l.pos++
+		default:
+			if c < utf8.RuneSelf {
+				return newline
+			}
+			r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+			switch r {
+			case '\u00a0', '\ufeff', '\u2028', '\u2029':
+				l.extensions[extJSON5Whitespace] = true
+				l.pos += size
+				if r == '\u2028' || r == '\u2029' {
+					newline = true
+				}
+			default:
+				return newline
+			}
+		}
+	}
+	return newline
+}
+
+// scanComment reads a // or /* */ comment
+func (l *lenientScanner) scanComment() (string, error) {
+	start := l.pos
+	if strings.HasPrefix(l.src[l.pos:], "//") {
+		end := strings.IndexByte(l.src[l.pos:], '\n')
+		if end < 0 {
+			l.pos = len(l.src)
+		} else {
+			l.pos += end
+		}
+		l.extensions[extComments] = true
+		return strings.TrimRight(l.src[start:l.pos], "\r"), nil
+	}
+
+	if strings.HasPrefix(l.src[l.pos:], "/*") {
+		end := strings.Index(l.src[l.pos+2:], "*/")
+		if end < 0 {
+			return "", &scanError{Offset: start, Msg: "unterminated block comment"}
+		}
+		l.pos += end + 4
+		l.extensions[extComments] = true
+		return l.src[start:l.pos], nil
+	}
+
+	return "", &scanError{Offset: start, Msg: "invalid character '/'"}
+}
+
+// scanString reads a single- or double-quoted string and returns it as a
+// strict JSON string literal
+func (l *lenientScanner) scanString(quote byte) (string, error) {
+	start := l.pos
+	if quote == '\'' {
+		l.extensions[extSingleQuotes] = true
+	}
+
+	var out strings.Builder
+	out.WriteByte('"')
+	l.pos++
+
+	for l.pos < len(l.src) {
+		r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+		switch {
+		case r == rune(quote):
+			l.pos++
+			out.WriteByte('"')
+			return out.String(), nil
+		case r == '\\':
+			if err := l.scanEscape(&out); err != nil {
+				return "", err
+			}
+			continue
+		case r == '"':
+			out.WriteString(`\"`)
+		case r == '\n' || r == '\r':
+			return "", &scanError{Offset: l.pos, Msg: "unescaped line break in string"}
+		case r < 0x20:
+			l.extensions[extExtendedEscapes] = true
+			out.WriteString(controlEscape(r))
+		default:
+			out.WriteString(l.src[l.pos : l.pos+size])
+		}
+		l.pos += size
+	}
+	return "", &scanError{Offset: start, Msg: "unterminated string"}
+}
+
+// scanEscape translates one escape sequence into its strict JSON form
+func (l *lenientScanner) scanEscape(out *strings.Builder) error {
+	start := l.pos
+	l.pos++
+	if l.pos >= len(l.src) {
+		return &scanError{Offset: start, Msg: "unterminated escape sequence"}
+	}
+
+	r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+	l.pos += size
+	switch r {
+	case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+		out.WriteByte('\\')
+		out.WriteRune(r)
+		return nil
+	case 'u':
+		if l.pos+4 > len(l.src) || !isHex(l.src[l.pos:l.pos+4]) {
+			return &scanError{Offset: start, Msg: "invalid unicode escape"}
+		}
+		out.WriteString(`\u` + l.src[l.pos:l.pos+4])
+		l.pos += 4
+		return nil
+	}
+
+	// Everything below is a JSON5 extension
+	l.extensions[extExtendedEscapes] = true
+	switch r {
+	case '\'':
+		out.WriteByte('\'')
+	case 'v':
+		out.WriteString(`\u000b`)
+	case '0':
+		if l.pos < len(l.src) && l.src[l.pos] >= '0' && l.src[l.pos] <= '9' {
+			return &scanError{Offset: start, Msg: "octal escapes are not allowed"}
+		}
+		out.WriteString(`\u0000`)
+	case 'x':
+		if l.pos+2 > len(l.src) || !isHex(l.src[l.pos:l.pos+2]) {
+			return &scanError{Offset: start, Msg: "invalid hex escape"}
+		}
+		out.WriteString(`\u00` + l.src[l.pos:l.pos+2])
+		l.pos += 2
+	case '\r':
+		// Line continuation: the escaped line break is dropped
+		if l.pos < len(l.src) && l.src[l.pos] == '\n' {
+			l.pos++
+		}
+	case '\n', '\u2028', '\u2029':
+	default:
+		if r >= '1' && r <= '9' {
+			return &scanError{Offset: start, Msg: "octal escapes are not allowed"}
+		}
+		out.WriteRune(r)
+	}
+	return nil
+}
+
+// scanNumber reads a JSON or JSON5 number and returns its strict spelling
+func (l *lenientScanner) scanNumber() (string, error) {
+	start := l.pos
+	for l.pos < len(l.src) && isNumberPart(l.src[l.pos]) {
+		l.pos++
+	}
+	text := l.src[start:l.pos]
+
+	if strings.HasSuffix(text, "Infinity") || strings.HasSuffix(text, "NaN") {
+		return "", &scanError{Offset: start, Msg: text + " cannot be represented in JSON"}
+	}
+
+	strict, err := strictNumber(text)
+	if err != nil {
+		return "", &scanError{Offset: start, Msg: err.Error()}
+	}
+	if strict != text {
+		l.extensions[extJSON5Numbers] = true
+	}
+	return strict, nil
+}
+
+// strictNumber converts a JSON5 number literal to a JSON number literal
+func strictNumber(text string) (string, error) {
+	sign := ""
+	body := text
+	if strings.HasPrefix(body, "+") || strings.HasPrefix(body, "-") {
+		if body[0] == '-' {
+			sign = "-"
+		}
+		body = body[1:]
+	}
+
+	if strings.HasPrefix(body, "0x") || strings.HasPrefix(body, "0X") {
+		value, ok := new(big.Int).SetString(body[2:], 16)
+		if !ok || strings.ContainsAny(body[2:], "+-") {
+			return "", fmt.Errorf("invalid hexadecimal number %q", text)
+		}
+		if sign == "-" && value.Sign() == 0 {
+			sign = ""
+		}
+		return sign + value.String(), nil
+	}
+
+	mantissa, exponent := body, ""
+	if i := strings.IndexAny(body, "eE"); i >= 0 {
+		mantissa, exponent = body[:i], body[i:]
+	}
+	if strings.HasPrefix(mantissa, ".") {
+		mantissa = "0" + mantissa
+	}
+	mantissa = strings.TrimSuffix(mantissa, ".")
+
+	strict := sign + mantissa + exponent
+	if !isStrictNumber(strict) {
+		return "", fmt.Errorf("invalid number %q", text)
+	}
+	return strict, nil
+}
+
+// isStrictNumber reports whether text is a complete JSON number literal
+func isStrictNumber(text string) bool {
+	if text == "" {
+		return false
+	}
+	t := newTokenizer(text)
+	if err := t.scanNumber(); err != nil {
+		return false
+	}
+	return t.pos == len(text)
+}
+
+// lenientSource adapts lenientScanner to a tokenSource. It attaches
+// comments to the following token, quotes bare object keys and drops
+// trailing commas so the result reads as strict JSON.
+type lenientSource struct {
+	scanner     *lenientScanner
+	stack       []tokenKind
+	previous    tokenKind
+	buffered    *token
+	bufferedErr error
+	peeked      *token
+	started     bool
+	// err is the first error produced. It is returned for every later
+	// token so an error seen by peek is not skipped.
+	err error
+}
+
+// newLenientSource creates a lenient token source over src
+func newLenientSource(src string) *lenientSource {
+	return &lenientSource{
+		scanner: &lenientScanner{src: src, extensions: make(map[string]bool)},
+	}
+}
+
+// peek returns the next token without consuming it
+func (s *lenientSource) peek() (token, error) {
+	if s.peeked != nil {
+		return *s.peeked, nil
+	}
+	tok, err := s.produce()
+	if err != nil {
+		return tok, err
+	}
+	s.peeked = &tok
+	return tok, nil
+}
+
+// next consumes the next token. At the end of input it returns io.EOF
+// together with a token carrying any comments after the last value.
+func (s *lenientSource) next() (token, error) {
+	if s.peeked != nil {
+		tok := *s.peeked
+		s.peeked = nil
+		return tok, nil
+	}
+	return s.produce()
+}
+
+// readWithComments reads the next significant token and attaches the
+// comments in front of it
+func (s *lenientSource) readWithComments() (token, error) {
+	if s.buffered != nil {
+		tok, err := *s.buffered, s.bufferedErr
+		s.buffered, s.bufferedErr = nil, nil
+		return tok, err
+	}
+
+	var comments []token
+	for {
+		tok, newline, err := s.scanner.scanRaw()
+		if err != nil {
+			tok.Comments = comments
+			return tok, err
+		}
+		if tok.Kind == tokenComment {
+			tok.SameLine = !newline && (s.started || len(comments) > 0)
+			comments = append(comments, tok)
+			continue
+		}
+		tok.Comments = comments
+		return tok, nil
+	}
+}
+
+// produce yields the next token in strict JSON form
+func (s *lenientSource) produce() (token, error) {
+	if s.err != nil {
+		return token{Offset: s.scanner.pos}, s.err
+	}
+	tok, err := s.convert()
+	if err != nil && err != io.EOF {
+		s.err = err
+	}
+	return tok, err
+}
+
+// convert reads the next token and rewrites it to strict JSON
+func (s *lenientSource) convert() (token, error) {
+	tok, err := s.readWithComments()
+	if err != nil {
+		return tok, err
+	}
+
+	if tok.Kind == tokenComma {
+		following, err := s.readWithComments()
+		if err == nil && (following.Kind == tokenEndObject || following.Kind == tokenEndArray) {
+			s.scanner.extensions[extTrailingCommas] = true
+			following.Comments = append(tok.Comments, following.Comments...)
+			tok = following
+		} else {
+			s.buffered = &following
+			s.bufferedErr = err
+		}
+	}
+
+	if tok.Kind == tokenIdentifier {
+		inKeyPosition := len(s.stack) > 0 && s.stack[len(s.stack)-1] == tokenBeginObject &&
+			(s.previous == tokenBeginObject || s.previous == tokenComma)
+		if !inKeyPosition {
+			return tok, &scanError{Offset: tok.Offset, Msg: fmt.Sprintf("unexpected identifier %q", tok.Text)}
+		}
+		s.scanner.extensions[extUnquotedKeys] = true
+		tok.Kind = tokenString
+		tok.Text = `"` + tok.Text + `"`
+	}
+
+	switch tok.Kind {
+	case tokenBeginObject, tokenBeginArray:
+		s.stack = append(s.stack, tok.Kind)
+	case tokenEndObject, tokenEndArray:
+		if len(s.stack) > 0 {
+			s.stack = s.stack[:len(s.stack)-1]
+		}
+	}
+	s.previous = tok.Kind
+	s.started = true
+	return tok, nil
+}
+
+// extensionsUsed returns the sorted names of the extensions seen so far
+func (s *lenientSource) extensionsUsed() []string {
+	names := make([]string, 0, len(s.scanner.extensions))
+	for name := range s.scanner.extensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseLenient parses a JSONC or JSON5 document and renders it as strict
+// JSON or, when keepComments is set, as comment-preserving JSONC
+func parseLenient(src string, keepComments bool, style formatStyle) LenientResult {
+	result := LenientResult{Extensions: []string{}}
+
+	source := newLenientSource(src)
+	_, err := parseTokenTree(source)
+	result.Extensions = source.extensionsUsed()
+	result.Dialect = lenientDialect(result.Extensions)
+	if err != nil {
+		result.ErrorMessage = err.Error()
+		if scanErr, ok := err.(*scanError); ok {
+			pos := positionAt(src, scanErr.Offset)
+			result.ErrorMessage = scanErr.Msg
+			result.LineNumber = pos.Line
+			result.Column = pos.Column
+			result.Offset = pos.Offset
+			result.Excerpt = sourceExcerpt(src, pos)
+		}
+		return result
+	}
+
+	output, err := formatTokenStream(newLenientSource(src), style, keepComments)
+	if err != nil {
+		result.ErrorMessage = "Failed to format document: " + err.Error()
+		return result
+	}
+
+	result.IsValid = true
+	result.Output = output
+	return result
+}
+
+// lenientDialect names the least permissive dialect that covers the
+// extensions a document used
+func lenientDialect(extensions []string) string {
+	dialect := "json"
+	for _, ext := range extensions {
+		switch ext {
+		case extComments, extTrailingCommas:
+			if dialect == "json" {
+				dialect = "jsonc"
+			}
+		default:
+			dialect = "json5"
+		}
+	}
+	return dialect
+}
+
+// runLenient parses JSONC or JSON5 input and converts it
+func runLenient(req OperationRequest) (interface{}, error) {
+	var opts LenientOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+
+	switch opts.Output {
+	case "", "json":
+		return parseLenient(req.Input, false, currentFormatSettings().style()), nil
+	case "jsonc":
+		return parseLenient(req.Input, true, currentFormatSettings().style()), nil
+	default:
+		return nil, fmt.Errorf("invalid output %q (expected json or jsonc)", opts.Output)
+	}
+}
+
+// controlEscape returns the JSON escape for a control character
+func controlEscape(r rune) string {
+	switch r {
+	case '\b':
+		return `\b`
+	case '\f':
+		return `\f`
+	case '\n':
+		return `\n`
+	case '\r':
+		return `\r`
+	case '\t':
+		return `\t`
+	}
+	return fmt.Sprintf(`\u%04x`, r)
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func isIdentifierStart(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdentifierPart(c byte) bool {
+	return isIdentifierStart(c) || c >= '0' && c <= '9'
+}
+
+func isNumberPart(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '.' || c == '+' || c == '-'
+}
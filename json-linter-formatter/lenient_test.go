@@ -0,0 +1,134 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLenient(t *testing.T) {
+	minified := formatStyle{Minify: true}
+
+	tests := []struct {
+		name       string
+		input      string
+		want       string
+		dialect    string
+		extensions []string
+	}{
+		{
+			name:       "strict JSON",
+			input:      `{"a": [1, 2]}`,
+			want:       `{"a":[1,2]}`,
+			dialect:    "json",
+			extensions: []string{},
+		},
+		{
+			name:       "line and block comments",
+			input:      "{\n  // note\n  \"a\": /* inline */ 1\n}",
+			want:       `{"a":1}`,
+			dialect:    "jsonc",
+			extensions: []string{extComments},
+		},
+		{
+			name:       "trailing commas",
+			input:      `{"a": [1, 2,], "b": 3,}`,
+			want:       `{"a":[1,2],"b":3}`,
+			dialect:    "jsonc",
+			extensions: []string{extTrailingCommas},
+		},
+		{
+			name:       "single quotes",
+			input:      `{'a': 'it\'s "quoted"'}`,
+			want:       `{"a":"it's \"quoted\""}`,
+			dialect:    "json5",
+			extensions: []string{extExtendedEscapes, extSingleQuotes},
+		},
+		{
+			name:       "unquoted keys",
+			input:      `{unquoted: 1, $dollar_key2: 2}`,
+			want:       `{"unquoted":1,"$dollar_key2":2}`,
+			dialect:    "json5",
+			extensions: []string{extUnquotedKeys},
+		},
+		{
+			name:       "JSON5 numbers",
+			input:      `[0x1F, +1, .5, 5., -0x0]`,
+			want:       `[31,1,0.5,5,0]`,
+			dialect:    "json5",
+			extensions: []string{extJSON5Numbers},
+		},
+		{
+			name:       "JSON5 escapes",
+			input:      `["\x41\v\0", "line \` + "\n" + `continued"]`,
+			want:       `["\u0041\u000b\u0000","line continued"]`,
+			dialect:    "json5",
+			extensions: []string{extExtendedEscapes},
+		},
+		{
+			name:       "JSON5 whitespace",
+			input:      "[1, 2]",
+			want:       `[1,2]`,
+			dialect:    "json5",
+			extensions: []string{extJSON5Whitespace},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := parseLenient(tt.input, false, minified)
+			if !result.IsValid {
+				t.Fatalf("parse failed: %s at %d:%d", result.ErrorMessage, result.LineNumber, result.Column)
+			}
+			if result.Output != tt.want {
+				t.Errorf("output = %s, want %s", result.Output, tt.want)
+			}
+			if result.Dialect != tt.dialect {
+				t.Errorf("dialect = %q, want %q", result.Dialect, tt.dialect)
+			}
+			if !reflect.DeepEqual(result.Extensions, tt.extensions) {
+				t.Errorf("extensions = %v, want %v", result.Extensions, tt.extensions)
+			}
+		})
+	}
+}
+
+func TestParseLenientKeepsComments(t *testing.T) {
+	input := "{\n  // the answer\n  a: 42, /* trailing */\n}"
+	result := parseLenient(input, true, defaultFormatStyle)
+	if !result.IsValid {
+		t.Fatal(result.ErrorMessage)
+	}
+	want := "{\n  // the answer\n  \"a\": 42 /* trailing */\n}"
+	if result.Output != want {
+		t.Errorf("output =\n%s\nwant\n%s", result.Output, want)
+	}
+}
+
+func TestParseLenientErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		message string
+		line    int
+		column  int
+	}{
+		{"NaN", `[NaN]`, "NaN cannot be represented in JSON", 1, 2},
+		{"Infinity", `{"a": -Infinity}`, "-Infinity cannot be represented in JSON", 1, 7},
+		{"unterminated comment", "[1, /* never closed", "unterminated block comment", 1, 5},
+		{"octal escape", `["\01"]`, "octal escapes are not allowed", 1, 3},
+		{"unterminated string", "{\n  'a: 1}", "unterminated string", 2, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := parseLenient(tt.input, false, defaultFormatStyle)
+			if result.IsValid {
+				t.Fatal("expected a parse error")
+			}
+			if result.ErrorMessage != tt.message || result.LineNumber != tt.line || result.Column != tt.column {
+				t.Errorf("error = %q at %d:%d, want %q at %d:%d",
+					result.ErrorMessage, result.LineNumber, result.Column, tt.message, tt.line, tt.column)
+			}
+		})
+	}
+}
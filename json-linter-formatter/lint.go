@@ -0,0 +1,620 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Diagnostic severities. SeverityOff disables a lint rule.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+	SeverityOff     = "off"
+)
+
+// defaultLintProfile is used when a request does not name a profile
+const defaultLintProfile = "recommended"
+
+// Diagnostic is a single finding about a document, located by JSON Pointer
+// and by its position in the source
+type Diagnostic struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Path     string `json:"path"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Offset   int    `json:"offset"`
+}
+
+// LintRuleConfig sets the severity of a rule and its rule-specific options
+type LintRuleConfig struct {
+	Severity string          `json:"severity,omitempty"`
+	Options  json.RawMessage `json:"options,omitempty"`
+}
+
+// LintRuleInfo describes a registered rule for the frontend
+type LintRuleInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// lintRule is a pluggable check over a parsed document. Rules that take
+// options provide newOptions, which returns a pointer to their defaults.
+type lintRule struct {
+	description string
+	newOptions  func() interface{}
+	check       func(src string, root *node, severity string, options interface{}) []Diagnostic
+}
+
+// lintRules maps rule names to their implementations. New rules are
+// added here and then switched on in one or more profiles.
+var lintRules = map[string]lintRule{
+	"duplicate-key": {
+		description: "Objects must not repeat a key",
+		check:       checkDuplicateKeys,
+	},
+	"unsafe-integer": {
+		description: "Integers must fit in the IEEE-754 safe range used by JavaScript",
+		check:       checkUnsafeIntegers,
+	},
+	"number-precision": {
+		description: "Decimals must survive conversion to a 64-bit float",
+		check:       checkNumberPrecision,
+	},
+	"max-depth": {
+		description: "Nesting must not exceed a maximum depth",
+		newOptions:  func() interface{} { return &maxDepthOptions{Max: 20} },
+		check:       checkMaxDepth,
+	},
+	"key-naming": {
+		description: "Object keys must follow a naming convention",
+		newOptions:  func() interface{} { return &keyNamingOptions{Style: "camelCase"} },
+		check:       checkKeyNaming,
+	},
+	"no-empty-object": {
+		description: "Objects must not be empty",
+		check:       checkEmptyContainers(nodeObject),
+	},
+	"no-empty-array": {
+		description: "Arrays must not be empty",
+		check:       checkEmptyContainers(nodeArray),
+	},
+	"no-trailing-whitespace": {
+		description: "String values must not end with whitespace",
+		check:       checkTrailingWhitespace,
+	},
+	"no-mixed-array": {
+		description: "Array elements must all have the same type",
+		newOptions:  func() interface{} { return &mixedArrayOptions{AllowNull: true} },
+		check:       checkMixedArrays,
+	},
+	"no-null": {
+		description: "Null is not allowed at the listed paths or keys (everywhere if none are listed)",
+		newOptions:  func() interface{} { return &noNullOptions{} },
+		check:       checkNulls,
+	},
+	"no-embedded-json": {
+		description: "String values must not hold encoded JSON objects or arrays",
+		check:       checkEmbeddedJSON,
+	},
+}
+
+// lintProfiles are the built-in rule sets a request can switch on by name
+var lintProfiles = map[string]map[string]LintRuleConfig{
+	"minimal": {
+		"duplicate-key": {Severity: SeverityWarning},
+	},
+	"recommended": {
+		"duplicate-key":    {Severity: SeverityWarning},
+		"unsafe-integer":   {Severity: SeverityWarning},
+		"number-precision": {Severity: SeverityWarning},
+	},
+	"strict": {
+		"duplicate-key":          {Severity: SeverityError},
+		"unsafe-integer":         {Severity: SeverityError},
+		"number-precision":       {Severity: SeverityWarning},
+		"max-depth":              {Severity: SeverityWarning},
+		"no-empty-object":        {Severity: SeverityWarning},
+		"no-empty-array":         {Severity: SeverityInfo},
+		"no-trailing-whitespace": {Severity: SeverityWarning},
+		"no-mixed-array":         {Severity: SeverityWarning},
+		"no-embedded-json":       {Severity: SeverityInfo},
+	},
+}
+
+// maxDepthOptions configures the max-depth rule
+type maxDepthOptions struct {
+	Max int `json:"max"`
+}
+
+// keyNamingOptions configures the key-naming rule
+type keyNamingOptions struct {
+	Style  string   `json:"style"`
+	Ignore []string `json:"ignore"`
+}
+
+// mixedArrayOptions configures the no-mixed-array rule
+type mixedArrayOptions struct {
+	AllowNull bool `json:"allowNull"`
+}
+
+// noNullOptions configures the no-null rule. Paths are JSON Pointers in
+// which a "*" segment matches any key or index.
+type noNullOptions struct {
+	Paths []string `json:"paths"`
+	Keys  []string `json:"keys"`
+}
+
+// keyNamingStyles holds the patterns accepted by the key-naming rule
+var keyNamingStyles = map[string]*regexp.Regexp{
+	"camelCase":            regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`),
+	"PascalCase":           regexp.MustCompile(`^[A-Z][a-zA-Z0-9]*$`),
+	"snake_case":           regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`),
+	"SCREAMING_SNAKE_CASE": regexp.MustCompile(`^[A-Z][A-Z0-9]*(_[A-Z0-9]+)*$`),
+	"kebab-case":           regexp.MustCompile(`^[a-z][a-z0-9]*(-[a-z0-9]+)*$`),
+}
+
+// maxSafeInteger is the largest integer every IEEE-754 double can represent
+// exactly, i.e. Number.MAX_SAFE_INTEGER in JavaScript
+var maxSafeInteger = big.NewRat(1<<53-1, 1)
+
+// resolvedRule is a rule ready to run with its effective settings
+type resolvedRule struct {
+	name     string
+	rule     lintRule
+	severity string
+	options  interface{}
+}
+
+// resolveLintRules merges a profile with per-rule overrides and decodes
+// each enabled rule's options
+func resolveLintRules(profile string, overrides map[string]LintRuleConfig) ([]resolvedRule, error) {
+	if profile == "" {
+		profile = defaultLintProfile
+	}
+	base, ok := lintProfiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("unknown lint profile %q (available: %s)", profile, strings.Join(lintProfileNames(), ", "))
+	}
+
+	settings := make(map[string]LintRuleConfig, len(base)+len(overrides))
+	for name, cfg := range base {
+		settings[name] = cfg
+	}
+	for name, cfg := range overrides {
+		if _, ok := lintRules[name]; !ok {
+			return nil, fmt.Errorf("unknown lint rule %q", name)
+		}
+		merged := settings[name]
+		if cfg.Severity != "" {
+			merged.Severity = cfg.Severity
+		} else if merged.Severity == "" {
+			merged.Severity = SeverityWarning
+		}
+		if len(cfg.Options) > 0 {
+			merged.Options = cfg.Options
+		}
+		settings[name] = merged
+	}
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var resolved []resolvedRule
+	for _, name := range names {
+		cfg := settings[name]
+		switch cfg.Severity {
+		case SeverityOff:
+			continue
+		case SeverityError, SeverityWarning, SeverityInfo:
+		default:
+			return nil, fmt.Errorf("invalid severity %q for lint rule %q (expected error, warning, info or off)", cfg.Severity, name)
+		}
+
+		rule := lintRules[name]
+		var options interface{}
+		if rule.newOptions != nil {
+			options = rule.newOptions()
+			if len(bytes.TrimSpace(cfg.Options)) > 0 {
+				if err := json.Unmarshal(cfg.Options, options); err != nil {
+					return nil, fmt.Errorf("invalid options for lint rule %q: %w", name, err)
+				}
+			}
+		}
+		if err := validateRuleOptions(name, options); err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, resolvedRule{name: name, rule: rule, severity: cfg.Severity, options: options})
+	}
+	return resolved, nil
+}
+
+// validateRuleOptions rejects option values a rule cannot work with
+func validateRuleOptions(name string, options interface{}) error {
+	switch opts := options.(type) {
+	case *maxDepthOptions:
+		if opts.Max < 1 {
+			return fmt.Errorf("lint rule %q: max must be at least 1", name)
+		}
+	case *keyNamingOptions:
+		if _, ok := keyNamingStyles[opts.Style]; !ok {
+			styles := make([]string, 0, len(keyNamingStyles))
+			for style := range keyNamingStyles {
+				styles = append(styles, style)
+			}
+			sort.Strings(styles)
+			return fmt.Errorf("lint rule %q: unknown style %q (available: %s)", name, opts.Style, strings.Join(styles, ", "))
+		}
+	}
+	return nil
+}
+
+// runLintRules runs the resolved rules and returns their findings in
+// document order
+func runLintRules(src string, root *node, rules []resolvedRule) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, r := range rules {
+		for _, d := range r.rule.check(src, root, r.severity, r.options) {
+			d.Rule = r.name
+			diagnostics = append(diagnostics, d)
+		}
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Offset < diagnostics[j].Offset
+	})
+	return diagnostics
+}
+
+// lintProfileNames returns the built-in profile names in sorted order
+func lintProfileNames() []string {
+	names := make([]string, 0, len(lintProfiles))
+	for name := range lintProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runLintRulesInfo lists the available rules and profiles
+func runLintRulesInfo(req OperationRequest) (interface{}, error) {
+	rules := make([]LintRuleInfo, 0, len(lintRules))
+	for name, rule := range lintRules {
+		rules = append(rules, LintRuleInfo{Name: name, Description: rule.description})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+
+	return map[string]interface{}{
+		"rules":          rules,
+		"profiles":       lintProfiles,
+		"defaultProfile": defaultLintProfile,
+	}, nil
+}
+
+// newDiagnostic creates a diagnostic positioned at offset in src. The rule
+// name is filled in by runLintRules.
+func newDiagnostic(src string, offset int, severity, path, message string) Diagnostic {
+	pos := positionAt(src, offset)
+	return Diagnostic{
+		Severity: severity,
+		Message:  message,
+		Path:     path,
+		Line:     pos.Line,
+		Column:   pos.Column,
+		Offset:   pos.Offset,
+	}
+}
+
+// checkUnsafeIntegers flags integers that JavaScript and other
+// float64-based consumers will silently corrupt
+func checkUnsafeIntegers(src string, root *node, severity string, _ interface{}) []Diagnostic {
+	var diagnostics []Diagnostic
+
+	walkTree(root, "", func(n *node, path string) bool {
+		if n.Kind != nodeNumber {
+			return true
+		}
+
+		exact, ok := new(big.Rat).SetString(n.Raw)
+		if ok && exact.IsInt() && new(big.Rat).Abs(exact).Cmp(maxSafeInteger) > 0 {
+			diagnostics = append(diagnostics, newDiagnostic(src, n.Offset, severity, path,
+				fmt.Sprintf("integer %s is outside the safe range ±(2^53-1) and will be corrupted by JavaScript consumers", n.Raw)))
+		}
+		return true
+	})
+
+	return diagnostics
+}
+
+// checkNumberPrecision flags decimals that cannot be represented by a
+// 64-bit float without changing their value
+func checkNumberPrecision(src string, root *node, severity string, _ interface{}) []Diagnostic {
+	var diagnostics []Diagnostic
+
+	walkTree(root, "", func(n *node, path string) bool {
+		if n.Kind != nodeNumber {
+			return true
+		}
+
+		exact, ok := new(big.Rat).SetString(n.Raw)
+		if !ok || exact.IsInt() {
+			return true
+		}
+
+		f, err := strconv.ParseFloat(n.Raw, 64)
+		if err != nil {
+			diagnostics = append(diagnostics, newDiagnostic(src, n.Offset, severity, path,
+				fmt.Sprintf("number %s is out of range for a 64-bit float", n.Raw)))
+			return true
+		}
+
+		// A decimal survives a float64 round-trip when the shortest
+		// representation of the parsed float has the same value
+		shortest := strconv.FormatFloat(f, 'g', -1, 64)
+		if rounded, ok := new(big.Rat).SetString(shortest); !ok || rounded.Cmp(exact) != 0 {
+			diagnostics = append(diagnostics, newDiagnostic(src, n.Offset, severity, path,
+				fmt.Sprintf("number %s loses precision as a 64-bit float and would become %s", n.Raw, shortest)))
+		}
+		return true
+	})
+
+	return diagnostics
+}
+
+// checkDuplicateKeys reports every occurrence of a key that appears more
+// than once in the same object. Parsers disagree on which value wins, so
+// each occurrence is listed rather than only the later ones.
+func checkDuplicateKeys(src string, root *node, severity string, _ interface{}) []Diagnostic {
+	var diagnostics []Diagnostic
+
+	walkTree(root, "", func(n *node, path string) bool {
+		if n.Kind != nodeObject || len(n.Members) < 2 {
+			return true
+		}
+
+		occurrences := make(map[string][]member, len(n.Members))
+		var order []string
+		for _, m := range n.Members {
+			if _, seen := occurrences[m.Key]; !seen {
+				order = append(order, m.Key)
+			}
+			occurrences[m.Key] = append(occurrences[m.Key], m)
+		}
+
+		for _, key := range order {
+			members := occurrences[key]
+			if len(members) < 2 {
+				continue
+			}
+
+			first := positionAt(src, members[0].KeyOffset)
+			for i, m := range members {
+				message := fmt.Sprintf("key %q is defined %d times in this object", key, len(members))
+				if i > 0 {
+					message = fmt.Sprintf("duplicate key %q (occurrence %d of %d, first defined at line %d, column %d)",
+						key, i+1, len(members), first.Line, first.Column)
+				}
+				diagnostics = append(diagnostics, newDiagnostic(src, m.KeyOffset, severity, appendPointer(path, key), message))
+			}
+		}
+		return true
+	})
+
+	return diagnostics
+}
+
+// checkMaxDepth reports containers nested deeper than the configured
+// maximum. Only the outermost offending container of each branch is reported.
+func checkMaxDepth(src string, root *node, severity string, options interface{}) []Diagnostic {
+	max := options.(*maxDepthOptions).Max
+	var diagnostics []Diagnostic
+
+	var visit func(n *node, path string, depth int)
+	visit = func(n *node, path string, depth int) {
+		if n.Kind != nodeObject && n.Kind != nodeArray {
+			return
+		}
+		if depth > max {
+			diagnostics = append(diagnostics, newDiagnostic(src, n.Offset, severity, path,
+				fmt.Sprintf("%s is nested %d levels deep, more than the maximum of %d", n.Kind, depth, max)))
+			return
+		}
+		for _, m := range n.Members {
+			visit(m.Value, appendPointer(path, m.Key), depth+1)
+		}
+		for i, item := range n.Items {
+			visit(item, appendPointerIndex(path, i), depth+1)
+		}
+	}
+	visit(root, "", 1)
+
+	return diagnostics
+}
+
+// checkKeyNaming reports object keys that do not follow the configured style
+func checkKeyNaming(src string, root *node, severity string, options interface{}) []Diagnostic {
+	opts := options.(*keyNamingOptions)
+	pattern := keyNamingStyles[opts.Style]
+	ignored := make(map[string]bool, len(opts.Ignore))
+	for _, key := range opts.Ignore {
+		ignored[key] = true
+	}
+
+	var diagnostics []Diagnostic
+	walkTree(root, "", func(n *node, path string) bool {
+		for _, m := range n.Members {
+			if !ignored[m.Key] && !pattern.MatchString(m.Key) {
+				diagnostics = append(diagnostics, newDiagnostic(src, m.KeyOffset, severity, appendPointer(path, m.Key),
+					fmt.Sprintf("key %q is not %s", m.Key, opts.Style)))
+			}
+		}
+		return true
+	})
+
+	return diagnostics
+}
+
+// checkEmptyContainers builds a rule that reports empty objects or arrays
+func checkEmptyContainers(kind nodeKind) func(string, *node, string, interface{}) []Diagnostic {
+	return func(src string, root *node, severity string, _ interface{}) []Diagnostic {
+		var diagnostics []Diagnostic
+		walkTree(root, "", func(n *node, path string) bool {
+			if n.Kind == kind && len(n.Members) == 0 && len(n.Items) == 0 {
+				diagnostics = append(diagnostics, newDiagnostic(src, n.Offset, severity, path,
+					fmt.Sprintf("empty %s", kind)))
+			}
+			return true
+		})
+		return diagnostics
+	}
+}
+
+// checkTrailingWhitespace reports string values that end in whitespace,
+// which is usually left over from copy and paste
+func checkTrailingWhitespace(src string, root *node, severity string, _ interface{}) []Diagnostic {
+	var diagnostics []Diagnostic
+
+	walkTree(root, "", func(n *node, path string) bool {
+		if n.Kind != nodeString {
+			return true
+		}
+
+		value, err := unquoteString(token{Text: n.Raw, Offset: n.Offset})
+		if err != nil || value == "" {
+			return true
+		}
+		if last, _ := utf8.DecodeLastRuneInString(value); unicode.IsSpace(last) {
+			diagnostics = append(diagnostics, newDiagnostic(src, n.Offset, severity, path,
+				"string value ends with whitespace"))
+		}
+		return true
+	})
+
+	return diagnostics
+}
+
+// checkEmbeddedJSON reports string values holding an encoded object or
+// array, which the expand operation can decode in place
+func checkEmbeddedJSON(src string, root *node, severity string, _ interface{}) []Diagnostic {
+	var diagnostics []Diagnostic
+
+	walkTree(root, "", func(n *node, path string) bool {
+		if doc, ok := embeddedDocument(n, false); ok {
+			diagnostics = append(diagnostics, newDiagnostic(src, n.Offset, severity, path,
+				fmt.Sprintf("string value holds an encoded JSON %s", doc.Kind)))
+		}
+		return true
+	})
+
+	return diagnostics
+}
+
+// checkMixedArrays reports arrays whose elements are not all the same type
+func checkMixedArrays(src string, root *node, severity string, options interface{}) []Diagnostic {
+	allowNull := options.(*mixedArrayOptions).AllowNull
+	var diagnostics []Diagnostic
+
+	walkTree(root, "", func(n *node, path string) bool {
+		if n.Kind != nodeArray {
+			return true
+		}
+
+		seen := make(map[nodeKind]bool)
+		var kinds []string
+		for _, item := range n.Items {
+			if (item.Kind == nodeNull && allowNull) || seen[item.Kind] {
+				continue
+			}
+			seen[item.Kind] = true
+			kinds = append(kinds, item.Kind.String())
+		}
+		if len(kinds) > 1 {
+			diagnostics = append(diagnostics, newDiagnostic(src, n.Offset, severity, path,
+				fmt.Sprintf("array mixes element types: %s", strings.Join(kinds, ", "))))
+		}
+		return true
+	})
+
+	return diagnostics
+}
+
+// checkNulls reports null values at the configured paths or keys, or every
+// null when neither is configured
+func checkNulls(src string, root *node, severity string, options interface{}) []Diagnostic {
+	opts := options.(*noNullOptions)
+	keys := make(map[string]bool, len(opts.Keys))
+	for _, key := range opts.Keys {
+		keys[key] = true
+	}
+	everywhere := len(opts.Paths) == 0 && len(opts.Keys) == 0
+
+	var diagnostics []Diagnostic
+	walkTree(root, "", func(n *node, path string) bool {
+		for _, m := range n.Members {
+			if m.Value.Kind != nodeNull {
+				continue
+			}
+			childPath := appendPointer(path, m.Key)
+			if everywhere || keys[m.Key] || matchesAnyPointer(childPath, opts.Paths) {
+				diagnostics = append(diagnostics, newDiagnostic(src, m.Value.Offset, severity, childPath,
+					fmt.Sprintf("%q must not be null", m.Key)))
+			}
+		}
+		for i, item := range n.Items {
+			if item.Kind != nodeNull {
+				continue
+			}
+			childPath := appendPointerIndex(path, i)
+			if everywhere || matchesAnyPointer(childPath, opts.Paths) {
+				diagnostics = append(diagnostics, newDiagnostic(src, item.Offset, severity, childPath,
+					"array element must not be null"))
+			}
+		}
+		return true
+	})
+
+	return diagnostics
+}
+
+// matchesAnyPointer reports whether pointer matches one of the patterns.
+// A "*" segment in a pattern matches any single key or index.
+func matchesAnyPointer(pointer string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+	segments, err := splitPointer(pointer)
+	if err != nil {
+		return false
+	}
+
+	for _, pattern := range patterns {
+		wanted, err := splitPointer(pattern)
+		if err != nil || len(wanted) != len(segments) {
+			continue
+		}
+		matched := true
+		for i := range wanted {
+			if wanted[i] != "*" && wanted[i] != segments[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
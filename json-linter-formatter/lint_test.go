@@ -0,0 +1,226 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// lintForTest parses src and runs the named rules at warning severity
+func lintForTest(t *testing.T, src string, overrides map[string]LintRuleConfig) []Diagnostic {
+	t.Helper()
+	root, err := parseTree(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := resolveLintRules("minimal", overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return runLintRules(src, root, rules)
+}
+
+// diagnosticPaths returns the rule and path of each diagnostic
+func diagnosticPaths(diagnostics []Diagnostic) []string {
+	var paths []string
+	for _, d := range diagnostics {
+		paths = append(paths, d.Rule+" "+d.Path)
+	}
+	return paths
+}
+
+func TestNumberRules(t *testing.T) {
+	rules := map[string]LintRuleConfig{
+		"unsafe-integer":   {Severity: SeverityWarning},
+		"number-precision": {Severity: SeverityWarning},
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"safe integers", `[9007199254740991, -9007199254740991, 1e3]`, nil},
+		{"unsafe integer", `{"id": 9007199254740993}`, []string{"unsafe-integer /id"}},
+		{"negative unsafe integer", `[-9007199254740992]`, []string{"unsafe-integer /0"}},
+		{"exponent spelling", `[1e20]`, []string{"unsafe-integer /0"}},
+		{"exact decimal", `[0.5, 1.25, 0.1]`, nil},
+		{"lossy decimal", `{"p": 0.10000000000000000001}`, []string{"number-precision /p"}},
+		{"out of range", `[1e400]`, []string{"unsafe-integer /0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diagnosticPaths(lintForTest(t, tt.input, rules))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diagnostics = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateKeepsNumbersAndWarnsAboutUnsafeIntegers(t *testing.T) {
+	result := validateAndFormatJSON(`{"id": 12345678901234567890, "ratio": 1.50}`, ValidateOptions{})
+	if !result.IsValid {
+		t.Fatalf("expected valid document, got %q", result.ErrorMessage)
+	}
+	want := "{\n  \"id\": 12345678901234567890,\n  \"ratio\": 1.50\n}"
+	if result.FormattedJSON != want {
+		t.Errorf("formatted =\n%s\nwant\n%s", result.FormattedJSON, want)
+	}
+	if got := diagnosticPaths(result.Diagnostics); !reflect.DeepEqual(got, []string{"unsafe-integer /id"}) {
+		t.Errorf("diagnostics = %v", got)
+	}
+}
+
+func TestDuplicateKeys(t *testing.T) {
+	rules := map[string]LintRuleConfig{"duplicate-key": {Severity: SeverityWarning}}
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"no duplicates", `{"a": 1, "b": {"a": 2}}`, nil},
+		{"top level", `{"a": 1, "a": 2}`, []string{"duplicate-key /a", "duplicate-key /a"}},
+		{"nested", `{"x": [{"k": 1, "j": 2, "k": 3}]}`, []string{"duplicate-key /x/0/k", "duplicate-key /x/0/k"}},
+		{"escaped key", `{"a~b": 1, "a~b": 2}`, []string{"duplicate-key /a~0b", "duplicate-key /a~0b"}},
+		{"three occurrences", `{"s/t": 1, "s/t": 2, "s/t": 3}`, []string{"duplicate-key /s~1t", "duplicate-key /s~1t", "duplicate-key /s~1t"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diagnosticPaths(lintForTest(t, tt.input, rules))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diagnostics = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDuplicateKeySeverity(t *testing.T) {
+	input := "{\n  \"a\": 1,\n  \"a\": 2\n}"
+
+	tests := []struct {
+		setting     string
+		valid       bool
+		diagnostics int
+	}{
+		{"", true, 2},
+		{"warning", true, 2},
+		{"error", false, 2},
+		{"ignore", true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.setting, func(t *testing.T) {
+			result := validateAndFormatJSON(input, ValidateOptions{DuplicateKeys: tt.setting})
+			if result.IsValid != tt.valid {
+				t.Errorf("isValid = %v, want %v", result.IsValid, tt.valid)
+			}
+			if len(result.Diagnostics) != tt.diagnostics {
+				t.Errorf("diagnostics = %+v, want %d", result.Diagnostics, tt.diagnostics)
+			}
+			if !tt.valid && (result.LineNumber != 2 || result.Column != 3) {
+				t.Errorf("error position = %d:%d, want 2:3", result.LineNumber, result.Column)
+			}
+		})
+	}
+
+	if err := (ValidateOptions{DuplicateKeys: "sometimes"}).check(); err == nil {
+		t.Error("expected an invalid duplicateKeys setting to be rejected")
+	}
+}
+
+func TestLintRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		opts  string
+		input string
+		want  []string
+	}{
+		{"max depth", "max-depth", `{"max": 2}`, `{"a": {"b": {"c": {}}}, "d": [1]}`, []string{"max-depth /a/b"}},
+		{"key naming camelCase", "key-naming", ``, `{"okKey": 1, "bad_key": {"AlsoBad": 2}}`, []string{"key-naming /bad_key", "key-naming /bad_key/AlsoBad"}},
+		{"key naming ignore", "key-naming", `{"style": "snake_case", "ignore": ["ID"]}`, `{"ID": 1, "user_id": 2, "userId": 3}`, []string{"key-naming /userId"}},
+		{"empty object", "no-empty-object", ``, `{"a": {}, "b": []}`, []string{"no-empty-object /a"}},
+		{"empty array", "no-empty-array", ``, `{"a": {}, "b": []}`, []string{"no-empty-array /b"}},
+		{"trailing whitespace", "no-trailing-whitespace", ``, `["ok", "tab\t", "space ", "nbsp\u00a0"]`, []string{"no-trailing-whitespace /1", "no-trailing-whitespace /2", "no-trailing-whitespace /3"}},
+		{"mixed array", "no-mixed-array", ``, `[[1, null, 2], [1, "a"]]`, []string{"no-mixed-array /1"}},
+		{"mixed array without nulls", "no-mixed-array", `{"allowNull": false}`, `[1, null]`, []string{"no-mixed-array "}},
+		{"null everywhere", "no-null", ``, `{"a": null, "b": [null]}`, []string{"no-null /a", "no-null /b/0"}},
+		{"null at paths", "no-null", `{"paths": ["/items/*/id"]}`, `{"items": [{"id": null, "name": null}], "id": null}`, []string{"no-null /items/0/id"}},
+		{"null keys", "no-null", `{"keys": ["name"]}`, `{"items": [{"id": null, "name": null}]}`, []string{"no-null /items/0/name"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := LintRuleConfig{Severity: SeverityWarning}
+			if tt.opts != "" {
+				cfg.Options = []byte(tt.opts)
+			}
+			overrides := map[string]LintRuleConfig{tt.rule: cfg, "duplicate-key": {Severity: SeverityOff}}
+
+			got := diagnosticPaths(lintForTest(t, tt.input, overrides))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diagnostics = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveLintRules(t *testing.T) {
+	tests := []struct {
+		name      string
+		profile   string
+		overrides map[string]LintRuleConfig
+		rules     []string
+		wantErr   bool
+	}{
+		{name: "default profile", rules: []string{"duplicate-key", "number-precision", "unsafe-integer"}},
+		{name: "minimal", profile: "minimal", rules: []string{"duplicate-key"}},
+		{
+			name:      "override turns a rule off and another on",
+			profile:   "recommended",
+			overrides: map[string]LintRuleConfig{"number-precision": {Severity: SeverityOff}, "max-depth": {}},
+			rules:     []string{"duplicate-key", "max-depth", "unsafe-integer"},
+		},
+		{name: "unknown profile", profile: "lenient", wantErr: true},
+		{name: "unknown rule", overrides: map[string]LintRuleConfig{"no-tabs": {}}, wantErr: true},
+		{name: "invalid severity", overrides: map[string]LintRuleConfig{"max-depth": {Severity: "fatal"}}, wantErr: true},
+		{name: "invalid options", overrides: map[string]LintRuleConfig{"max-depth": {Options: []byte(`{"max": 0}`)}}, wantErr: true},
+		{name: "unknown key style", overrides: map[string]LintRuleConfig{"key-naming": {Options: []byte(`{"style": "Train-Case"}`)}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved, err := resolveLintRules(tt.profile, tt.overrides)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, r := range resolved {
+				names = append(names, r.name)
+			}
+			if !reflect.DeepEqual(names, tt.rules) {
+				t.Errorf("rules = %v, want %v", names, tt.rules)
+			}
+		})
+	}
+}
+
+func TestValidateReportsErrorLevelRules(t *testing.T) {
+	opts := ValidateOptions{Rules: map[string]LintRuleConfig{"no-empty-array": {Severity: SeverityError}}}
+	result := validateAndFormatJSON("{\n  \"a\": []\n}", opts)
+	if result.IsValid {
+		t.Fatal("expected an error-level diagnostic to make the document invalid")
+	}
+	if result.ErrorMessage != "empty array (no-empty-array)" || result.LineNumber != 2 || result.Column != 8 {
+		t.Errorf("result = %+v", result)
+	}
+}
@@ -1,142 +1,262 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"log"
 	"os"
 	"strings"
-	"time"
 
 	sdk "github.com/PortableSheep/delve-sdk"
 )
 
+// Message types for plugin communication
+const (
+	MessageTypeValidate  = 1
+	MessageTypeOperation = 2
+	// MessageTypeProgress carries progress events for long-running jobs
+	MessageTypeProgress = 3
+)
+
+// APIRequest represents an incoming request from the host
+type APIRequest struct {
+	RequestID string          `json:"requestId"`
+	JSON      *string         `json:"json"`
+	Options   ValidateOptions `json:"options"`
+}
+
+// APIResponse is sent back to the host for every message it sends
+type APIResponse struct {
+	RequestID string      `json:"requestId,omitempty"`
+	Success   bool        `json:"success"`
+	Data      interface{} `json:"data,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
 // JSONValidationResult represents the result of JSON validation and formatting
 type JSONValidationResult struct {
-	IsValid       bool   `json:"isValid"`
-	FormattedJSON string `json:"formattedJson"`
-	ErrorMessage  string `json:"errorMessage,omitempty"`
-	LineNumber    int    `json:"lineNumber,omitempty"`
+	IsValid       bool         `json:"isValid"`
+	FormattedJSON string       `json:"formattedJson"`
+	ErrorMessage  string       `json:"errorMessage,omitempty"`
+	LineNumber    int          `json:"lineNumber,omitempty"`
+	Column        int          `json:"column,omitempty"`
+	Offset        int          `json:"offset,omitempty"`
+	Excerpt       string       `json:"excerpt,omitempty"`
+	Diagnostics   []Diagnostic `json:"diagnostics,omitempty"`
+	// RepairAvailable is set when the repair operation can fix a syntax error
+	RepairAvailable bool `json:"repairAvailable,omitempty"`
+}
+
+// ValidateOptions controls the lint rules run during validation
+type ValidateOptions struct {
+	// Profile names the built-in lint profile to start from
+	Profile string `json:"profile,omitempty"`
+	// Rules overrides the severity or options of individual lint rules
+	Rules map[string]LintRuleConfig `json:"rules,omitempty"`
+	// DuplicateKeys is a shorthand for the duplicate-key rule severity:
+	// "error", "warning" or "ignore"
+	DuplicateKeys string `json:"duplicateKeys,omitempty"`
+}
+
+// resolveRules combines the profile, rule overrides and duplicate key
+// shorthand into the set of rules to run
+func (o ValidateOptions) resolveRules() ([]resolvedRule, error) {
+	overrides := make(map[string]LintRuleConfig, len(o.Rules)+1)
+	for name, cfg := range o.Rules {
+		overrides[name] = cfg
+	}
+
+	switch o.DuplicateKeys {
+	case "":
+	case "ignore":
+		overrides["duplicate-key"] = LintRuleConfig{Severity: SeverityOff}
+	case SeverityError, SeverityWarning:
+		overrides["duplicate-key"] = LintRuleConfig{Severity: o.DuplicateKeys}
+	default:
+		return nil, fmt.Errorf("invalid duplicateKeys setting %q (expected error, warning or ignore)", o.DuplicateKeys)
+	}
+
+	return resolveLintRules(o.Profile, overrides)
+}
+
+// check rejects option values validateAndFormatJSON does not understand
+func (o ValidateOptions) check() error {
+	_, err := o.resolveRules()
+	return err
 }
 
 var plugin *sdk.Plugin
 
 // handleHostMessage processes messages from the host application
 func handleHostMessage(messageType int, data []byte) {
-	log.Printf("JSON Linter received message: Type=%d, Data=%s", messageType, string(data))
+	log.Printf("JSON Linter received message: Type=%d, Size=%d bytes", messageType, len(data))
+
+	var response APIResponse
 
 	switch messageType {
-	case 1: // JSON validation request
-		result := validateAndFormatJSON(string(data))
-		log.Printf("Validation result: %+v", result)
+	case MessageTypeValidate:
+		request := parseValidateRequest(data)
+		if err := request.Options.check(); err != nil {
+			response = APIResponse{
+				RequestID: request.RequestID,
+				Success:   false,
+				Error:     err.Error(),
+			}
+			break
+		}
+
+		result := validateAndFormatJSON(*request.JSON, request.Options)
+		log.Printf("Validation result: valid=%t, diagnostics=%d", result.IsValid, len(result.Diagnostics))
+		response = APIResponse{
+			RequestID: request.RequestID,
+			Success:   true,
+			Data:      result,
+		}
+
+	case MessageTypeOperation:
+		response = handleOperation(data)
+
+	default:
+		log.Printf("Unknown message type: %d", messageType)
+		response = APIResponse{
+			RequestID: extractRequestID(data),
+			Success:   false,
+			Error:     "Unknown message type",
+		}
+	}
+
+	sendResponse(messageType, response)
+}
+
+// parseValidateRequest decodes a validation request. Hosts that predate the
+// request envelope send the raw document, which is accepted as-is. A payload
+// is only treated as an envelope when it carries a request ID and nothing
+// but envelope fields, so documents that happen to have a "json" member are
+// still validated whole.
+func parseValidateRequest(data []byte) APIRequest {
+	raw := string(data)
+	document := APIRequest{JSON: &raw}
+
+	var request APIRequest
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil || decoder.More() {
+		return document
+	}
+	if request.RequestID == "" || request.JSON == nil {
+		return document
+	}
+	return request
+}
+
+// extractRequestID pulls the caller's request ID out of a payload, if any
+func extractRequestID(data []byte) string {
+	var envelope struct {
+		RequestID string `json:"requestId"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return ""
+	}
+	return envelope.RequestID
+}
+
+// sendResponse marshals a response and delivers it to the host
+func sendResponse(messageType int, response APIResponse) {
+	responseData, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("Error marshaling response: %v", err)
+		return
+	}
+
+	if plugin == nil {
+		log.Printf("Plugin not connected, dropping response of %d bytes", len(responseData))
+		return
+	}
+
+	if err := plugin.SendEvent(messageType, responseData); err != nil {
+		log.Printf("Error sending response: %v", err)
 	}
 }
 
 // validateAndFormatJSON takes a JSON string, validates it, and returns formatted JSON
-func validateAndFormatJSON(jsonStr string) JSONValidationResult {
+func validateAndFormatJSON(jsonStr string, opts ValidateOptions) JSONValidationResult {
+	result := validateJSON(jsonStr, opts)
+
+	// Only documents that failed to parse are offered a repair
+	if !result.IsValid && result.FormattedJSON == "" {
+		if repaired, err := repairJSON(jsonStr); err == nil && repaired.Validation.IsValid {
+			result.RepairAvailable = true
+		}
+	}
+	return result
+}
+
+// validateJSON validates and formats a JSON string without probing for a
+// repair, so the repair operation can use it to check its own output
+func validateJSON(jsonStr string, opts ValidateOptions) JSONValidationResult {
 	result := JSONValidationResult{
 		IsValid: false,
 	}
 
-	// Trim whitespace
-	jsonStr = strings.TrimSpace(jsonStr)
-
-	if jsonStr == "" {
+	if strings.TrimSpace(jsonStr) == "" {
 		result.ErrorMessage = "Empty JSON input"
 		return result
 	}
 
-	// Parse the JSON to check if it's valid
-	var parsed interface{}
+	// Parse the JSON to check if it's valid. The untrimmed input is decoded
+	// so that error offsets line up with what the user sees in the editor.
+	var parsed json.RawMessage
 	err := json.Unmarshal([]byte(jsonStr), &parsed)
 
 	if err != nil {
 		result.ErrorMessage = err.Error()
 
-		// Try to extract line number from error message if available
-		if strings.Contains(err.Error(), "line") {
-			// This is a simple approach - real implementation might need more sophisticated parsing
-			parts := strings.Split(err.Error(), "line")
-			if len(parts) > 1 {
-				// Extract line number - this is simplified
-				result.LineNumber = 1 // Default to line 1 if we can't parse it properly
-			}
+		if pos, ok := errorPosition(jsonStr, err); ok {
+			result.LineNumber = pos.Line
+			result.Column = pos.Column
+			result.Offset = pos.Offset
+			result.Excerpt = sourceExcerpt(jsonStr, pos)
 		}
 		return result
 	}
 
-	// If valid, format it nicely
-	formatted, err := json.MarshalIndent(parsed, "", "  ")
+	// If valid, format it nicely while keeping the original key order
+	formatted, err := formatTokens(jsonStr, currentFormatSettings().style())
 	if err != nil {
 		result.ErrorMessage = "Failed to format JSON: " + err.Error()
 		return result
 	}
 
 	result.IsValid = true
-	result.FormattedJSON = string(formatted)
-	return result
-}
+	result.FormattedJSON = formatted
 
-// demonstrateStorage shows how to use the plugin storage system for JSON snippets
-// This is optional and can be disabled by setting DISABLE_STORAGE_DEMO=true
-func demonstrateStorage(p *sdk.Plugin) {
-	// Check if storage demo is disabled
-	if os.Getenv("DISABLE_STORAGE_DEMO") == "true" {
-		log.Println("Storage demonstration disabled (DISABLE_STORAGE_DEMO=true)")
-		return
+	rules, err := opts.resolveRules()
+	if err != nil {
+		log.Printf("Skipping lint rules: %v", err)
+		return result
 	}
+	tree, err := parseTree(jsonStr)
+	if err != nil {
+		return result
+	}
+	result.Diagnostics = runLintRules(jsonStr, tree, rules)
 
-	go func() {
-		log.Println("=== JSON Linter Plugin Storage Demonstration (Background) ===")
-
-		// Store some configuration for the JSON linter
-		config := map[string]interface{}{
-			"theme":           "monokai",
-			"autoFormat":      true,
-			"validateOnType":  true,
-			"tabSize":         2,
-			"autoSave":        true,
-			"saveInterval":    500,
-			"persistentState": true,
-			"syntaxHighlight": true,
-		}
-
-		// Try to store config with timeout
-		err := p.StoreConfig("linter_settings", config, "1.0.0")
-		if err != nil {
-			log.Printf("⚠️  Storage not available - linter config not stored: %v", err)
-		} else {
-			log.Println("✓ Stored JSON linter configuration")
-		}
-
-		// Store some sample JSON snippets
-		sampleJSON := map[string]interface{}{
-			"snippets": []map[string]interface{}{
-				{"name": "API Response", "json": `{"status": "success", "data": {"id": 1, "name": "John Doe"}}`},
-				{"name": "Config File", "json": `{"server": {"host": "localhost", "port": 3000}, "database": {"url": "mongodb://localhost:27017"}}`},
-				{"name": "User Profile", "json": `{"user": {"id": 123, "username": "john_doe", "email": "john@example.com", "preferences": {"theme": "dark", "notifications": true}}}`},
-			},
-			"createdAt": time.Now(),
-			"version":   "1.0.0",
-		}
-
-		err = p.StoreData("json_snippets", sampleJSON, "1.0.0")
-		if err != nil {
-			log.Printf("⚠️  Storage not available - JSON snippets not stored: %v", err)
-		} else {
-			log.Println("✓ Stored JSON snippets")
-		}
-
-		// Show storage statistics
-		stats, err := p.GetStats()
-		if err != nil {
-			log.Printf("⚠️  Storage stats not available: %v", err)
-		} else {
-			log.Printf("\n=== Storage Statistics ===")
-			for storageType, count := range stats {
-				log.Printf("%s: %d items", storageType, count)
-			}
+	// A document that breaks an error-level rule is reported as invalid,
+	// pointing at the first offending location
+	for _, d := range result.Diagnostics {
+		if d.Severity != SeverityError {
+			continue
 		}
-	}()
+		result.IsValid = false
+		result.ErrorMessage = fmt.Sprintf("%s (%s)", d.Message, d.Rule)
+		result.LineNumber = d.Line
+		result.Column = d.Column
+		result.Offset = d.Offset
+		result.Excerpt = sourceExcerpt(jsonStr, positionAt(jsonStr, d.Offset))
+		break
+	}
+	return result
 }
 
 func main() {
@@ -157,9 +277,6 @@ func main() {
 		log.Fatalf("Failed to start JSON linter plugin: %v", err)
 	}
 
-	// Demonstrate storage functionality (non-blocking, optional)
-	demonstrateStorage(plugin)
-
 	// Test the JSON validation functionality
 	log.Println("=== Testing JSON Validation ===")
 
@@ -178,7 +295,7 @@ func main() {
 
 	for i, testCase := range testCases {
 		log.Printf("Test case %d: %s", i+1, testCase)
-		result := validateAndFormatJSON(testCase)
+		result := validateAndFormatJSON(testCase, ValidateOptions{})
 		if result.IsValid {
 			log.Printf("✓ Valid JSON, formatted:\n%s", result.FormattedJSON)
 		} else {
@@ -190,8 +307,7 @@ func main() {
 	// Start listening for events from the host
 	log.Println("JSON Linter Plugin is running and listening for host events.")
 	log.Println("Plugin supports persistent state using global window state.")
-	log.Println("Note: Storage operations run in background and may timeout if storage service is unavailable.")
-	log.Println("To disable storage demo entirely, set environment variable DISABLE_STORAGE_DEMO=true")
+	log.Println("Snippets, settings and schemas are read from plugin storage on demand.")
 	plugin.Listen(handleHostMessage)
 
 	log.Println("JSON Linter Plugin shutting down.")
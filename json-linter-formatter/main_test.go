@@ -0,0 +1,70 @@
+package main
+
+import "testing"
+
+func TestParseValidateRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		payload   string
+		requestID string
+		document  string
+	}{
+		{
+			name:      "envelope",
+			payload:   `{"requestId": "r1", "json": "{\"a\": 1}"}`,
+			requestID: "r1",
+			document:  `{"a": 1}`,
+		},
+		{
+			name:      "envelope with options",
+			payload:   `{"requestId": "r2", "json": "[]", "options": {"profile": "strict"}}`,
+			requestID: "r2",
+			document:  `[]`,
+		},
+		{
+			name:     "raw document",
+			payload:  `{"a": 1}`,
+			document: `{"a": 1}`,
+		},
+		{
+			name:     "document with a json member and other fields",
+			payload:  `{"json": "not json at all", "other": 1}`,
+			document: `{"json": "not json at all", "other": 1}`,
+		},
+		{
+			name:     "document with only a json member",
+			payload:  `{"json": "not json at all"}`,
+			document: `{"json": "not json at all"}`,
+		},
+		{
+			name:     "request ID without a document",
+			payload:  `{"requestId": "r3"}`,
+			document: `{"requestId": "r3"}`,
+		},
+		{
+			name:     "invalid document",
+			payload:  `{"requestId": `,
+			document: `{"requestId": `,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := parseValidateRequest([]byte(tt.payload))
+			if request.RequestID != tt.requestID {
+				t.Errorf("requestId = %q, want %q", request.RequestID, tt.requestID)
+			}
+			if request.JSON == nil || *request.JSON != tt.document {
+				t.Errorf("document = %v, want %q", request.JSON, tt.document)
+			}
+		})
+	}
+}
+
+func TestValidateRawDocumentWithJSONMember(t *testing.T) {
+	request := parseValidateRequest([]byte(`{"json": "not json at all", "other": 1}`))
+	result := validateAndFormatJSON(*request.JSON, request.Options)
+	if !result.IsValid {
+		t.Errorf("expected document to be valid, got %q", result.ErrorMessage)
+	}
+}
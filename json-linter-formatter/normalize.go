@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// Key ordering modes
+const (
+	keyOrderLexical = "lexical"
+	keyOrderNatural = "natural"
+	keyOrderNone    = "none"
+)
+
+// NormalizeOptions are the options of the normalize operation
+type NormalizeOptions struct {
+	// KeyOrder is "lexical" (the default, by code point), "natural" (digit
+	// runs compare as numbers, so "item2" sorts before "item10") or "none"
+	KeyOrder string `json:"keyOrder"`
+	// KeyPriority lists keys placed first, in this order, ahead of the
+	// keys sorted by KeyOrder
+	KeyPriority []string `json:"keyPriority"`
+	// SortArrays sorts arrays holding only scalars: null, then booleans,
+	// numbers and strings
+	SortArrays bool `json:"sortArrays"`
+	// SortObjectsBy sorts arrays of objects by the scalar value of this
+	// member; objects without it keep their order at the end
+	SortObjectsBy string `json:"sortObjectsBy"`
+	// Dedupe removes array elements equal to an earlier element
+	Dedupe bool `json:"dedupe"`
+}
+
+// NormalizeResult is the result of the normalize operation
+type NormalizeResult struct {
+	Normalized        string `json:"normalized"`
+	Changed           bool   `json:"changed"`
+	ObjectsReordered  int    `json:"objectsReordered"`
+	ArraysSorted      int    `json:"arraysSorted"`
+	DuplicatesRemoved int    `json:"duplicatesRemoved"`
+}
+
+// normalizer rewrites a tree into its normal form
+type normalizer struct {
+	opts     NormalizeOptions
+	priority map[string]int
+	result   NormalizeResult
+}
+
+// runNormalize rewrites the input with sorted keys and, optionally, sorted
+// and de-duplicated arrays, so that equivalent documents serialize the
+// same way
+func runNormalize(req OperationRequest) (interface{}, error) {
+	var opts NormalizeOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+	switch opts.KeyOrder {
+	case "":
+		opts.KeyOrder = keyOrderLexical
+	case keyOrderLexical, keyOrderNatural, keyOrderNone:
+	default:
+		return nil, fmt.Errorf("invalid key order %q (expected lexical, natural or none)", opts.KeyOrder)
+	}
+
+	root, err := parseTree(req.Input)
+	if err != nil {
+		return nil, fmt.Errorf("invalid input JSON: %w", err)
+	}
+
+	nz := &normalizer{opts: opts, priority: make(map[string]int)}
+	for i, key := range opts.KeyPriority {
+		if _, ok := nz.priority[key]; !ok {
+			nz.priority[key] = i
+		}
+	}
+	nz.normalize(root)
+
+	normalized, err := formatTokens(root.compact(), currentFormatSettings().style())
+	if err != nil {
+		return nil, err
+	}
+	nz.result.Normalized = normalized
+	nz.result.Changed = normalized != req.Input
+	return nz.result, nil
+}
+
+// normalize rewrites n and its descendants in place
+func (nz *normalizer) normalize(n *node) {
+	switch n.Kind {
+	case nodeObject:
+		for _, m := range n.Members {
+			nz.normalize(m.Value)
+		}
+		if nz.opts.KeyOrder == keyOrderNone && len(nz.priority) == 0 {
+			return
+		}
+		sorted := sort.SliceIsSorted(n.Members, func(i, j int) bool {
+			return nz.lessKey(n.Members[i].Key, n.Members[j].Key)
+		})
+		if !sorted {
+			sort.SliceStable(n.Members, func(i, j int) bool {
+				return nz.lessKey(n.Members[i].Key, n.Members[j].Key)
+			})
+			nz.result.ObjectsReordered++
+		}
+	case nodeArray:
+		for _, item := range n.Items {
+			nz.normalize(item)
+		}
+		if nz.opts.Dedupe {
+			nz.dedupe(n)
+		}
+		nz.sortArray(n)
+	}
+}
+
+// lessKey orders object keys: priority keys first, then by KeyOrder
+func (nz *normalizer) lessKey(a, b string) bool {
+	pa, aFirst := nz.priority[a]
+	pb, bFirst := nz.priority[b]
+	switch {
+	case aFirst && bFirst:
+		return pa < pb
+	case aFirst != bFirst:
+		return aFirst
+	}
+	return nz.lessString(a, b)
+}
+
+// lessString compares strings by KeyOrder
+func (nz *normalizer) lessString(a, b string) bool {
+	switch nz.opts.KeyOrder {
+	case keyOrderNatural:
+		return naturalLess(a, b)
+	case keyOrderNone:
+		return false
+	}
+	return a < b
+}
+
+// dedupe drops array items equal to an earlier item
+func (nz *normalizer) dedupe(n *node) {
+	seen := make(map[string]bool)
+	items := n.Items[:0]
+	for _, item := range n.Items {
+		key := equalityKey(item)
+		if seen[key] {
+			nz.result.DuplicatesRemoved++
+			continue
+		}
+		seen[key] = true
+		items = append(items, item)
+	}
+	n.Items = items
+}
+
+// sortArray sorts an array of scalars when SortArrays is set, or an array
+// of objects when SortObjectsBy is set
+func (nz *normalizer) sortArray(n *node) {
+	if len(n.Items) < 2 {
+		return
+	}
+	scalars, objects := true, true
+	for _, item := range n.Items {
+		scalars = scalars && item.Kind != nodeObject && item.Kind != nodeArray
+		objects = objects && item.Kind == nodeObject
+	}
+
+	var less func(i, j int) bool
+	switch {
+	case scalars && nz.opts.SortArrays:
+		less = func(i, j int) bool { return nz.compareScalars(n.Items[i], n.Items[j]) < 0 }
+	case objects && nz.opts.SortObjectsBy != "":
+		keys := make(map[*node]*node, len(n.Items))
+		for _, item := range n.Items {
+			keys[item] = sortKeyOf(item, nz.opts.SortObjectsBy)
+		}
+		less = func(i, j int) bool {
+			a, b := keys[n.Items[i]], keys[n.Items[j]]
+			if a == nil || b == nil {
+				return a != nil
+			}
+			return nz.compareScalars(a, b) < 0
+		}
+	default:
+		return
+	}
+
+	if !sort.SliceIsSorted(n.Items, less) {
+		sort.SliceStable(n.Items, less)
+		nz.result.ArraysSorted++
+	}
+}
+
+// sortKeyOf returns the scalar value of the named member of obj, or nil
+// when it is missing or not a scalar. With repeated keys the last wins.
+func sortKeyOf(obj *node, key string) *node {
+	_, values := lastMembers(obj)
+	value := values[key]
+	if value == nil || value.Kind == nodeObject || value.Kind == nodeArray {
+		return nil
+	}
+	return value
+}
+
+// scalarRank orders scalars of different types
+var scalarRank = map[nodeKind]int{nodeNull: 0, nodeBool: 1, nodeNumber: 2, nodeString: 3}
+
+// compareScalars orders two scalars: by type, then numbers by value,
+// booleans false first and strings as keys are ordered
+func (nz *normalizer) compareScalars(a, b *node) int {
+	if ra, rb := scalarRank[a.Kind], scalarRank[b.Kind]; ra != rb {
+		return ra - rb
+	}
+	switch a.Kind {
+	case nodeBool:
+		return strings.Compare(a.Raw, b.Raw)
+	case nodeNumber:
+		x, okX := new(big.Rat).SetString(a.Raw)
+		y, okY := new(big.Rat).SetString(b.Raw)
+		if okX && okY {
+			return x.Cmp(y)
+		}
+		return strings.Compare(a.Raw, b.Raw)
+	case nodeString:
+		x, y := scalarText(a), scalarText(b)
+		switch {
+		case nz.opts.KeyOrder == keyOrderNatural && naturalLess(x, y):
+			return -1
+		case nz.opts.KeyOrder == keyOrderNatural && naturalLess(y, x):
+			return 1
+		}
+		return strings.Compare(x, y)
+	}
+	return 0
+}
+
+// naturalLess compares strings with runs of digits compared by numeric
+// value. Strings that only differ in leading zeros fall back to code
+// point order.
+func naturalLess(a, b string) bool {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if isDigit(a[i]) && isDigit(b[j]) {
+			si, sj := i, j
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+			x := strings.TrimLeft(a[si:i], "0")
+			y := strings.TrimLeft(b[sj:j], "0")
+			if len(x) != len(y) {
+				return len(x) < len(y)
+			}
+			if x != y {
+				return x < y
+			}
+			continue
+		}
+		if a[i] != b[j] {
+			return a[i] < b[j]
+		}
+		i++
+		j++
+	}
+	if len(a)-i != len(b)-j {
+		return len(a)-i < len(b)-j
+	}
+	return a < b
+}
+
+// isDigit reports whether c is an ASCII digit
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
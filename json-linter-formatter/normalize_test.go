@@ -0,0 +1,156 @@
+package main
+
+import "testing"
+
+func TestRunNormalize(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		options   string
+		want      string
+		reordered int
+		sorted    int
+		removed   int
+	}{
+		{
+			name:      "lexical key order is the default",
+			input:     `{"b": 1, "a": {"d": 2, "c": 3}, "C": 4}`,
+			options:   `{}`,
+			want:      `{"C":4,"a":{"c":3,"d":2},"b":1}`,
+			reordered: 2,
+		},
+		{
+			name:      "natural key order",
+			input:     `{"item10": 1, "item2": 2, "item1": 3}`,
+			options:   `{"keyOrder": "natural"}`,
+			want:      `{"item1":3,"item2":2,"item10":1}`,
+			reordered: 1,
+		},
+		{
+			name:      "priority keys come first",
+			input:     `{"b": 1, "name": "x", "a": 2, "id": 7}`,
+			options:   `{"keyPriority": ["id", "name"]}`,
+			want:      `{"id":7,"name":"x","a":2,"b":1}`,
+			reordered: 1,
+		},
+		{
+			name:      "priority keys without sorting",
+			input:     `{"b": 1, "id": 7, "a": 2}`,
+			options:   `{"keyOrder": "none", "keyPriority": ["id"]}`,
+			want:      `{"id":7,"b":1,"a":2}`,
+			reordered: 1,
+		},
+		{
+			name:    "arrays keep their order by default",
+			input:   `[3, 1, 2]`,
+			options: `{}`,
+			want:    `[3,1,2]`,
+		},
+		{
+			name:    "scalar arrays sort by type then value",
+			input:   `["b", 10, true, null, 9.5, "a", false, 1e1]`,
+			options: `{"sortArrays": true}`,
+			want:    `[null,false,true,9.5,10,1e1,"a","b"]`,
+			sorted:  1,
+		},
+		{
+			name:    "numbers keep their spelling",
+			input:   `[1.50, 1.0E0, 0.5]`,
+			options: `{"sortArrays": true}`,
+			want:    `[0.5,1.0E0,1.50]`,
+			sorted:  1,
+		},
+		{
+			name:    "mixed arrays are left alone",
+			input:   `[2, [1], 1]`,
+			options: `{"sortArrays": true}`,
+			want:    `[2,[1],1]`,
+		},
+		{
+			name:    "objects sort by a member",
+			input:   `[{"id": 3}, {"x": 1}, {"id": 1}, {"id": [0]}, {"id": 2}]`,
+			options: `{"sortObjectsBy": "id", "keyOrder": "none"}`,
+			want:    `[{"id":1},{"id":2},{"id":3},{"x":1},{"id":[0]}]`,
+			sorted:  1,
+		},
+		{
+			name:    "dedupe ignores key order and number spelling",
+			input:   `[{"a": 1, "b": 2}, {"b": 2, "a": 1.0}, 1, 1, "1"]`,
+			options: `{"dedupe": true, "keyOrder": "none"}`,
+			want:    `[{"a":1,"b":2},1,"1"]`,
+			removed: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := runNormalize(OperationRequest{Operation: "normalize", Input: tt.input, Options: []byte(tt.options)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := value.(NormalizeResult)
+			if got := compactForTest(t, result.Normalized); got != tt.want {
+				t.Errorf("normalized = %s, want %s", got, tt.want)
+			}
+			if result.ObjectsReordered != tt.reordered || result.ArraysSorted != tt.sorted || result.DuplicatesRemoved != tt.removed {
+				t.Errorf("counts = %d reordered, %d sorted, %d removed; want %d, %d, %d",
+					result.ObjectsReordered, result.ArraysSorted, result.DuplicatesRemoved, tt.reordered, tt.sorted, tt.removed)
+			}
+		})
+	}
+}
+
+func TestRunNormalizeReportsUnchangedInput(t *testing.T) {
+	input, err := formatTokens(`{"a":[1,2],"b":"x"}`, currentFormatSettings().style())
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := runNormalize(OperationRequest{Operation: "normalize", Input: input})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := value.(NormalizeResult); result.Changed {
+		t.Errorf("expected already normalized input to be unchanged, got %q", result.Normalized)
+	}
+}
+
+func TestRunNormalizeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		options string
+	}{
+		{"invalid input", `{"a": }`, `{}`},
+		{"unknown key order", `{}`, `{"keyOrder": "random"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := runNormalize(OperationRequest{Operation: "normalize", Input: tt.input, Options: []byte(tt.options)}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"item2", "item10", true},
+		{"item10", "item2", false},
+		{"a1b2", "a1b10", true},
+		{"file007", "file7", true},
+		{"file7", "file007", false},
+		{"abc", "abd", true},
+		{"ab", "abc", true},
+		{"x", "x", false},
+	}
+
+	for _, tt := range tests {
+		if got := naturalLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("naturalLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
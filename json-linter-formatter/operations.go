@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ProtocolVersion is the newest operation envelope version this backend speaks
+const ProtocolVersion = 1
+
+// OperationRequest is the envelope the host sends with MessageTypeOperation
+type OperationRequest struct {
+	Version   int             `json:"version"`
+	RequestID string          `json:"requestId"`
+	Operation string          `json:"operation"`
+	Options   json.RawMessage `json:"options,omitempty"`
+	Input     string          `json:"input"`
+	// Session identifies the editor session whose history records the
+	// documents produced by editing operations
+	Session string `json:"session,omitempty"`
+}
+
+// operationHandler executes a single named operation and returns its result
+type operationHandler func(req OperationRequest) (interface{}, error)
+
+// operations maps operation names to their handlers. New backend
+// capabilities are exposed by adding an entry here.
+var operations = map[string]operationHandler{
+	"validate":       runValidate,
+	"format":         runFormat,
+	"minify":         runMinify,
+	"validateSchema": runValidateSchema,
+	"saveSchema":     runSaveSchema,
+	"lintRules":      runLintRulesInfo,
+	"lenient":        runLenient,
+	"repair":         runRepair,
+	"getSettings":    runGetSettings,
+	"saveSettings":   runSaveSettings,
+	"stream":         runStream,
+	"cancelStream":   runCancelStream,
+	"jsonLines":      runJSONLines,
+	"query":          runQuery,
+	"diff":           runDiff,
+	"patch":          runPatch,
+	"inferSchema":    runInferSchema,
+	"generateTypes":  runGenerateTypes,
+	"convert":        runConvert,
+	"canonicalize":   runCanonicalize,
+	"createSnippet":  runCreateSnippet,
+	"getSnippet":     runGetSnippet,
+	"updateSnippet":  runUpdateSnippet,
+	"deleteSnippet":  runDeleteSnippet,
+	"listSnippets":   runListSnippets,
+	"analyze":        runAnalyze,
+	"normalize":      runNormalize,
+	"expand":         runExpand,
+	"stringify":      runStringify,
+	"historyRecord":  runHistoryRecord,
+	"historyList":    runHistoryList,
+	"historyGet":     runHistoryGet,
+	"historyUndo":    runHistoryUndo,
+	"historyRedo":    runHistoryRedo,
+	"historyDiff":    runHistoryDiff,
+	"historyClear":   runHistoryClear,
+}
+
+// MinifyResult is the result of the minify operation
+type MinifyResult struct {
+	Minified     string `json:"minified"`
+	OriginalSize int    `json:"originalSize"`
+	MinifiedSize int    `json:"minifiedSize"`
+}
+
+// handleOperation decodes an operation envelope and dispatches it
+func handleOperation(data []byte) APIResponse {
+	var req OperationRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return APIResponse{
+			RequestID: extractRequestID(data),
+			Success:   false,
+			Error:     fmt.Sprintf("Invalid operation request: %v", err),
+		}
+	}
+
+	result, err := dispatchOperation(req)
+	if err != nil {
+		return APIResponse{
+			RequestID: req.RequestID,
+			Success:   false,
+			Error:     err.Error(),
+		}
+	}
+
+	return APIResponse{
+		RequestID: req.RequestID,
+		Success:   true,
+		Data:      result,
+	}
+}
+
+// dispatchOperation checks the envelope version and runs the named operation
+func dispatchOperation(req OperationRequest) (interface{}, error) {
+	if req.Version > ProtocolVersion {
+		return nil, fmt.Errorf("unsupported protocol version %d (newest supported is %d)", req.Version, ProtocolVersion)
+	}
+
+	if req.Operation == "" {
+		return nil, fmt.Errorf("missing operation name (supported: %s)", strings.Join(operationNames(), ", "))
+	}
+
+	handler, ok := operations[req.Operation]
+	if !ok {
+		return nil, fmt.Errorf("unknown operation %q (supported: %s)", req.Operation, strings.Join(operationNames(), ", "))
+	}
+
+	result, err := handler(req)
+	if err != nil {
+		return nil, err
+	}
+	recordOperation(req, result)
+	return result, nil
+}
+
+// operationNames returns the registered operation names in sorted order
+func operationNames() []string {
+	names := make([]string, 0, len(operations))
+	for name := range operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// decodeOptions unmarshals the request options into opts. Missing options
+// leave opts at its defaults.
+func decodeOptions(req OperationRequest, opts interface{}) error {
+	if len(bytes.TrimSpace(req.Options)) == 0 || string(bytes.TrimSpace(req.Options)) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(req.Options, opts); err != nil {
+		return fmt.Errorf("invalid options for %s: %w", req.Operation, err)
+	}
+	return nil
+}
+
+// runValidate validates the input and returns the full validation result
+func runValidate(req OperationRequest) (interface{}, error) {
+	var opts ValidateOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+	if err := opts.check(); err != nil {
+		return nil, err
+	}
+	return validateAndFormatJSON(req.Input, opts), nil
+}
+
+// runFormat pretty-prints the input. Invalid input is reported as an error.
+func runFormat(req OperationRequest) (interface{}, error) {
+	result := validateAndFormatJSON(req.Input, ValidateOptions{DuplicateKeys: "ignore"})
+	if !result.IsValid {
+		return nil, fmt.Errorf("cannot format invalid JSON: %s", result.ErrorMessage)
+	}
+	return result, nil
+}
+
+// runMinify strips all insignificant whitespace from the input
+func runMinify(req OperationRequest) (interface{}, error) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(req.Input)); err != nil {
+		return nil, fmt.Errorf("cannot minify invalid JSON: %w", err)
+	}
+
+	return MinifyResult{
+		Minified:     buf.String(),
+		OriginalSize: len(req.Input),
+		MinifiedSize: buf.Len(),
+	}, nil
+}
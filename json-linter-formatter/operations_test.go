@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleOperation(t *testing.T) {
+	tests := []struct {
+		name      string
+		payload   string
+		requestID string
+		success   bool
+		errorPart string
+	}{
+		{
+			name:      "validate",
+			payload:   `{"version": 1, "requestId": "r1", "operation": "validate", "input": "{\"a\": 1}"}`,
+			requestID: "r1",
+			success:   true,
+		},
+		{
+			name:      "missing version is accepted",
+			payload:   `{"requestId": "r2", "operation": "minify", "input": "[1, 2]"}`,
+			requestID: "r2",
+			success:   true,
+		},
+		{
+			name:      "newer protocol version",
+			payload:   `{"version": 2, "requestId": "r3", "operation": "validate", "input": "{}"}`,
+			requestID: "r3",
+			errorPart: "unsupported protocol version 2",
+		},
+		{
+			name:      "missing operation",
+			payload:   `{"version": 1, "requestId": "r4"}`,
+			requestID: "r4",
+			errorPart: "missing operation name",
+		},
+		{
+			name:      "unknown operation",
+			payload:   `{"version": 1, "requestId": "r5", "operation": "frobnicate"}`,
+			requestID: "r5",
+			errorPart: `unknown operation "frobnicate"`,
+		},
+		{
+			name:      "malformed envelope keeps request ID",
+			payload:   `{"requestId": "r6", "version": "one"}`,
+			requestID: "r6",
+			errorPart: "Invalid operation request",
+		},
+		{
+			name:      "invalid options",
+			payload:   `{"requestId": "r7", "operation": "validate", "options": [], "input": "{}"}`,
+			requestID: "r7",
+			errorPart: "invalid options for validate",
+		},
+		{
+			name:      "format rejects invalid input",
+			payload:   `{"requestId": "r8", "operation": "format", "input": "{"}`,
+			requestID: "r8",
+			errorPart: "cannot format invalid JSON",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := handleOperation([]byte(tt.payload))
+			if response.RequestID != tt.requestID {
+				t.Errorf("requestId = %q, want %q", response.RequestID, tt.requestID)
+			}
+			if response.Success != tt.success {
+				t.Fatalf("success = %v, want %v (error %q)", response.Success, tt.success, response.Error)
+			}
+			if !strings.Contains(response.Error, tt.errorPart) {
+				t.Errorf("error = %q, want it to contain %q", response.Error, tt.errorPart)
+			}
+		})
+	}
+}
+
+func TestRunMinify(t *testing.T) {
+	result, err := runMinify(OperationRequest{Operation: "minify", Input: "{\n  \"a\": [1, 2]\n}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := result.(MinifyResult)
+	if got.Minified != `{"a":[1,2]}` || got.OriginalSize != 17 || got.MinifiedSize != 11 {
+		t.Errorf("runMinify = %+v", got)
+	}
+}
+
+// runForTest runs an operation handler on input with the given JSON
+// options and returns its result, failing the test on an error
+func runForTest[T any](t *testing.T, run func(OperationRequest) (interface{}, error), input, options string) T {
+	t.Helper()
+	value, err := run(OperationRequest{Input: input, Options: []byte(options)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, ok := value.(T)
+	if !ok {
+		t.Fatalf("result is a %T", value)
+	}
+	return result
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Patch formats
+const (
+	patchJSONPatch = "jsonpatch"
+	patchMerge     = "merge"
+)
+
+// PatchOptions are the options of the patch operation. Patch holds an
+// RFC 6902 JSON Patch (an array of operations) or an RFC 7386 merge patch,
+// either inline or as JSON text. Format is detected from the patch when
+// empty.
+type PatchOptions struct {
+	Patch    json.RawMessage `json:"patch"`
+	Format   string          `json:"format"`
+	Validate ValidateOptions `json:"validate"`
+}
+
+// PatchResult is the result of the patch operation. Validation lints the
+// patched document with the given validate options.
+type PatchResult struct {
+	Format     string               `json:"format"`
+	Applied    int                  `json:"applied"`
+	Document   string               `json:"document"`
+	Validation JSONValidationResult `json:"validation"`
+}
+
+// patchOperation is a JSON Patch operation as sent by the host. Pointers
+// distinguish missing members from empty ones.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// runPatch applies a JSON Patch or merge patch to the input
+func runPatch(req OperationRequest) (interface{}, error) {
+	var opts PatchOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+	if err := opts.Validate.check(); err != nil {
+		return nil, err
+	}
+
+	patchText, err := documentOption(opts.Patch, "patch")
+	if err != nil {
+		return nil, err
+	}
+	patch, err := parseTree(patchText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid patch JSON: %w", err)
+	}
+
+	format := opts.Format
+	if format == "" {
+		format = patchMerge
+		if patch.Kind == nodeArray {
+			format = patchJSONPatch
+		}
+	}
+
+	doc, err := parseTree(req.Input)
+	if err != nil {
+		return nil, fmt.Errorf("invalid input JSON: %w", err)
+	}
+
+	result := PatchResult{Format: format}
+	switch format {
+	case patchJSONPatch:
+		var ops []patchOperation
+		if err := json.Unmarshal([]byte(patchText), &ops); err != nil {
+			return nil, fmt.Errorf("a JSON Patch must be an array of operations: %w", err)
+		}
+		if doc, err = applyJSONPatch(doc, ops); err != nil {
+			return nil, err
+		}
+		result.Applied = len(ops)
+	case patchMerge:
+		doc = applyMergePatch(doc, patch)
+		result.Applied = 1
+	default:
+		return nil, fmt.Errorf("invalid format %q (expected jsonpatch or merge)", opts.Format)
+	}
+
+	result.Document, err = formatTokens(doc.compact(), currentFormatSettings().style())
+	if err != nil {
+		return nil, err
+	}
+	result.Validation = validateAndFormatJSON(result.Document, opts.Validate)
+	return result, nil
+}
+
+// applyJSONPatch applies the operations in order. The first failure stops
+// the patch and is reported with the operation's index.
+func applyJSONPatch(doc *node, ops []patchOperation) (*node, error) {
+	for i, op := range ops {
+		var err error
+		if doc, err = applyPatchOperation(doc, op); err != nil {
+			path := ""
+			if op.Path != nil {
+				path = " " + *op.Path
+			}
+			return nil, fmt.Errorf("patch operation %d (%s%s) failed: %w", i, op.Op, path, err)
+		}
+	}
+	return doc, nil
+}
+
+// applyPatchOperation applies one operation and returns the new root
+func applyPatchOperation(doc *node, op patchOperation) (*node, error) {
+	if op.Path == nil {
+		return nil, fmt.Errorf("missing \"path\"")
+	}
+	path := *op.Path
+
+	value := func() (*node, error) {
+		if op.Value == nil {
+			return nil, fmt.Errorf("missing \"value\"")
+		}
+		return parseTree(string(op.Value))
+	}
+	from := func() (string, error) {
+		if op.From == nil {
+			return "", fmt.Errorf("missing \"from\"")
+		}
+		return *op.From, nil
+	}
+
+	switch op.Op {
+	case "add":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		return addAtPointer(doc, path, v)
+	case "remove":
+		_, root, err := removeAtPointer(doc, path)
+		return root, err
+	case "replace":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		return replaceAtPointer(doc, path, v)
+	case "move":
+		source, err := from()
+		if err != nil {
+			return nil, err
+		}
+		if source == path {
+			_, err := lookupPointer(doc, source)
+			return doc, err
+		}
+		if strings.HasPrefix(path, source+"/") {
+			return nil, fmt.Errorf("cannot move %q into its own child %q", source, path)
+		}
+		moved, root, err := removeAtPointer(doc, source)
+		if err != nil {
+			return nil, fmt.Errorf("from: %w", err)
+		}
+		return addAtPointer(root, path, moved)
+	case "copy":
+		source, err := from()
+		if err != nil {
+			return nil, err
+		}
+		copied, err := lookupPointer(doc, source)
+		if err != nil {
+			return nil, fmt.Errorf("from: %w", err)
+		}
+		return addAtPointer(doc, path, cloneNode(copied))
+	case "test":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		actual, err := lookupPointer(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if equalityKey(actual) != equalityKey(v) {
+			return nil, fmt.Errorf("test failed: expected %s, found %s", v.compact(), actual.compact())
+		}
+		return doc, nil
+	case "":
+		return nil, fmt.Errorf("missing \"op\"")
+	default:
+		return nil, fmt.Errorf("unknown op %q (expected add, remove, replace, move, copy or test)", op.Op)
+	}
+}
+
+// lookupPointer finds the node a JSON Pointer refers to
+func lookupPointer(doc *node, pointer string) (*node, error) {
+	tokens, err := splitPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	current := doc
+	for i, token := range tokens {
+		location := "/" + strings.Join(tokens[:i], "/")
+		switch current.Kind {
+		case nodeObject:
+			index := memberIndex(current, token)
+			if index < 0 {
+				return nil, fmt.Errorf("path %q not found: no member %q", pointer, token)
+			}
+			current = current.Members[index].Value
+		case nodeArray:
+			index, err := arrayIndex(token, len(current.Items))
+			if err != nil {
+				return nil, fmt.Errorf("path %q not found: %v", pointer, err)
+			}
+			current = current.Items[index]
+		default:
+			return nil, fmt.Errorf("path %q not found: %q is a %s", pointer, location, current.Kind)
+		}
+	}
+	return current, nil
+}
+
+// memberIndex returns the index of the last member named key, or -1
+func memberIndex(obj *node, key string) int {
+	for i := len(obj.Members) - 1; i >= 0; i-- {
+		if obj.Members[i].Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitParent splits a pointer into the parent container and final token
+func splitParent(doc *node, pointer string) (*node, string, error) {
+	i := strings.LastIndexByte(pointer, '/')
+	if i < 0 {
+		return nil, "", fmt.Errorf("invalid JSON pointer %q: must start with '/'", pointer)
+	}
+	parent, err := lookupPointer(doc, pointer[:i])
+	if err != nil {
+		return nil, "", err
+	}
+	if parent.Kind != nodeObject && parent.Kind != nodeArray {
+		return nil, "", fmt.Errorf("path %q not found: %q is a %s", pointer, pointer[:i], parent.Kind)
+	}
+	return parent, pointerUnescaper.Replace(pointer[i+1:]), nil
+}
+
+// addAtPointer adds value at pointer following RFC 6902 add semantics and
+// returns the new root
+func addAtPointer(doc *node, pointer string, value *node) (*node, error) {
+	if pointer == "" {
+		return value, nil
+	}
+	parent, token, err := splitParent(doc, pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	if parent.Kind == nodeObject {
+		setMember(parent, token, value)
+		return doc, nil
+	}
+
+	index := len(parent.Items)
+	if token != "-" {
+		if index, err = arrayIndex(token, len(parent.Items)+1); err != nil {
+			return nil, fmt.Errorf("path %q: cannot insert at %q in an array of length %d", pointer, token, len(parent.Items))
+		}
+	}
+	parent.Items = append(parent.Items, nil)
+	copy(parent.Items[index+1:], parent.Items[index:])
+	parent.Items[index] = value
+	return doc, nil
+}
+
+// replaceAtPointer swaps the existing value at pointer for value, keeping
+// its position, and returns the new root
+func replaceAtPointer(doc *node, pointer string, value *node) (*node, error) {
+	if pointer == "" {
+		return value, nil
+	}
+	if _, err := lookupPointer(doc, pointer); err != nil {
+		return nil, err
+	}
+	parent, token, err := splitParent(doc, pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	if parent.Kind == nodeObject {
+		setMember(parent, token, value)
+	} else {
+		index, _ := arrayIndex(token, len(parent.Items))
+		parent.Items[index] = value
+	}
+	return doc, nil
+}
+
+// removeAtPointer removes the value at pointer and returns it along with
+// the root
+func removeAtPointer(doc *node, pointer string) (*node, *node, error) {
+	if pointer == "" {
+		return nil, nil, fmt.Errorf("cannot remove the document root")
+	}
+	parent, token, err := splitParent(doc, pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if parent.Kind == nodeObject {
+		index := memberIndex(parent, token)
+		if index < 0 {
+			return nil, nil, fmt.Errorf("path %q not found: no member %q", pointer, token)
+		}
+		removed := parent.Members[index].Value
+		deleteMember(parent, token)
+		return removed, doc, nil
+	}
+
+	index, err := arrayIndex(token, len(parent.Items))
+	if err != nil {
+		return nil, nil, fmt.Errorf("path %q not found: %v", pointer, err)
+	}
+	removed := parent.Items[index]
+	parent.Items = append(parent.Items[:index], parent.Items[index+1:]...)
+	return removed, doc, nil
+}
+
+// setMember replaces the value of key in place, dropping any duplicates,
+// or appends a new member
+func setMember(obj *node, key string, value *node) {
+	index := memberIndex(obj, key)
+	if index < 0 {
+		obj.Members = append(obj.Members, member{Key: key, KeyRaw: jsonQuote(key), Value: value})
+		return
+	}
+	obj.Members[index].Value = value
+
+	kept := obj.Members[:0]
+	for i, m := range obj.Members {
+		if m.Key != key || i == index {
+			kept = append(kept, m)
+		}
+	}
+	obj.Members = kept
+}
+
+// deleteMember removes every member named key
+func deleteMember(obj *node, key string) {
+	kept := obj.Members[:0]
+	for _, m := range obj.Members {
+		if m.Key != key {
+			kept = append(kept, m)
+		}
+	}
+	obj.Members = kept
+}
+
+// applyMergePatch applies an RFC 7386 merge patch and returns the result
+func applyMergePatch(target, patch *node) *node {
+	if patch.Kind != nodeObject {
+		return patch
+	}
+	if target == nil || target.Kind != nodeObject {
+		target = &node{Kind: nodeObject}
+	}
+
+	for _, m := range patch.Members {
+		if m.Value.Kind == nodeNull {
+			deleteMember(target, m.Key)
+			continue
+		}
+		var existing *node
+		if index := memberIndex(target, m.Key); index >= 0 {
+			existing = target.Members[index].Value
+		}
+		setMember(target, m.Key, applyMergePatch(existing, m.Value))
+	}
+	return target
+}
+
+// cloneNode returns a deep copy of n
+func cloneNode(n *node) *node {
+	c := *n
+	if n.Members != nil {
+		c.Members = make([]member, len(n.Members))
+		for i, m := range n.Members {
+			m.Value = cloneNode(m.Value)
+			c.Members[i] = m
+		}
+	}
+	if n.Items != nil {
+		c.Items = make([]*node, len(n.Items))
+		for i, item := range n.Items {
+			c.Items[i] = cloneNode(item)
+		}
+	}
+	return &c
+}
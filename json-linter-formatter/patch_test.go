@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// patchOptions returns the options of a patch request
+func patchOptions(patch, format string) string {
+	options, _ := json.Marshal(map[string]interface{}{"patch": json.RawMessage(patch), "format": format})
+	return string(options)
+}
+
+func TestRunPatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		patch  string
+		format string
+		want   string
+	}{
+		{"add member", `{"a": 1}`, `[{"op": "add", "path": "/b", "value": [1]}]`, patchJSONPatch, `{"a":1,"b":[1]}`},
+		{"add to array end", `[1, 2]`, `[{"op": "add", "path": "/-", "value": 3}]`, patchJSONPatch, `[1,2,3]`},
+		{"insert into array", `[1, 3]`, `[{"op": "add", "path": "/1", "value": 2}]`, patchJSONPatch, `[1,2,3]`},
+		{"remove", `{"a": 1, "b": 2}`, `[{"op": "remove", "path": "/a"}]`, patchJSONPatch, `{"b":2}`},
+		{"replace keeps member order", `{"a": 1, "b": 2}`, `[{"op": "replace", "path": "/a", "value": "x"}]`, patchJSONPatch, `{"a":"x","b":2}`},
+		{"replace root", `{"a": 1}`, `[{"op": "replace", "path": "", "value": [true]}]`, patchJSONPatch, `[true]`},
+		{"move", `{"a": {"b": 1}, "c": {}}`, `[{"op": "move", "from": "/a/b", "path": "/c/d"}]`, patchJSONPatch, `{"a":{},"c":{"d":1}}`},
+		{"copy", `{"a": [1]}`, `[{"op": "copy", "from": "/a", "path": "/b"}]`, patchJSONPatch, `{"a":[1],"b":[1]}`},
+		{"test passes", `{"n": 1.0}`, `[{"op": "test", "path": "/n", "value": 1}]`, patchJSONPatch, `{"n":1.0}`},
+		{"escaped pointer", `{"a/b": {"c~d": 1}}`, `[{"op": "remove", "path": "/a~1b/c~0d"}]`, patchJSONPatch, `{"a/b":{}}`},
+		{"keeps number spelling", `{"big": 12345678901234567890}`, `[{"op": "add", "path": "/x", "value": 1e3}]`, patchJSONPatch, `{"big":12345678901234567890,"x":1e3}`},
+		{"merge patch", `{"a": 1, "b": {"c": 2, "d": 3}}`, `{"a": null, "b": {"c": 4}, "e": 5}`, "", `{"b":{"c":4,"d":3},"e":5}`},
+		{"merge patch replaces non-objects", `[1, 2]`, `{"a": 1}`, "", `{"a":1}`},
+		{"detected JSON Patch", `{}`, `[{"op": "add", "path": "/a", "value": 1}]`, "", `{"a":1}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runForTest[PatchResult](t, runPatch, tt.input, patchOptions(tt.patch, tt.format))
+			if got := compactForTest(t, result.Document); got != tt.want {
+				t.Errorf("document = %s, want %s", got, tt.want)
+			}
+			if !result.Validation.IsValid {
+				t.Errorf("patched document is invalid: %s", result.Validation.ErrorMessage)
+			}
+		})
+	}
+}
+
+func TestRunPatchErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		patch string
+		want  string
+	}{
+		{"failed test", `{"a": 1}`, `[{"op": "test", "path": "/a", "value": 2}]`, "operation 0"},
+		{"missing path", `{}`, `[{"op": "remove", "path": "/a"}]`, "operation 0"},
+		{"array index out of range", `[1]`, `[{"op": "add", "path": "/1", "value": 0}, {"op": "add", "path": "/5", "value": 0}]`, "operation 1"},
+		{"unknown op", `{}`, `[{"op": "merge", "path": ""}]`, "operation 0"},
+		{"move into own child", `{"a": {}}`, `[{"op": "move", "from": "/a", "path": "/a/b"}]`, "operation 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runPatch(OperationRequest{Input: tt.input, Options: []byte(patchOptions(tt.patch, patchJSONPatch))})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffPatchRoundTrip(t *testing.T) {
+	pairs := [][2]string{
+		{`{"a": 1, "b": [1, 2, 3], "c": {"d": true}}`, `{"a": 2, "b": [0, 1, 3, 4], "e": null}`},
+		{`[{"id": 1}, {"id": 2}, {"id": 3}]`, `[{"id": 3}, {"id": 1, "x": 1}]`},
+		{`{"k/~": [[1], [2]]}`, `{"k/~": [[2], [1], []]}`},
+	}
+
+	for _, pair := range pairs {
+		options, _ := json.Marshal(map[string]interface{}{"target": json.RawMessage(pair[1])})
+		diff, err := runDiff(OperationRequest{Input: pair[0], Options: options})
+		if err != nil {
+			t.Fatal(err)
+		}
+		patch, _ := json.Marshal(diff.(DiffResult).Patch)
+
+		result := runForTest[PatchResult](t, runPatch, pair[0], patchOptions(string(patch), patchJSONPatch))
+		if got, want := compactForTest(t, result.Document), compactForTest(t, pair[1]); got != want {
+			t.Errorf("patched = %s, want %s", got, want)
+		}
+	}
+}
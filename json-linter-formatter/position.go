@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// excerptWidth is the maximum number of characters shown on either side of
+// the error column, so minified single-line payloads stay readable
+const excerptWidth = 40
+
+// SourcePosition describes a location inside the input document
+type SourcePosition struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// errorOffset extracts the byte offset of a decoding error, if it carries one
+func errorOffset(err error) (int64, bool) {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return syntaxErr.Offset, true
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return typeErr.Offset, true
+	}
+
+	return 0, false
+}
+
+// positionAt converts a byte offset into a 1-based line and column.
+// Columns count characters rather than bytes so they match what editors show.
+func positionAt(src string, offset int) SourcePosition {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(src) {
+		offset = len(src)
+	}
+
+	lineStart := strings.LastIndexByte(src[:offset], '\n') + 1
+	return SourcePosition{
+		Offset: offset,
+		Line:   strings.Count(src[:offset], "\n") + 1,
+		Column: utf8.RuneCountInString(src[lineStart:offset]) + 1,
+	}
+}
+
+// errorPosition returns the position the decoder stopped at. The offset
+// reported by encoding/json points just past the offending byte, so it is
+// stepped back by one to land on the character that caused the failure.
+func errorPosition(src string, err error) (SourcePosition, bool) {
+	offset, ok := errorOffset(err)
+	if !ok {
+		return SourcePosition{}, false
+	}
+
+	pos := int(offset)
+	if pos > 0 && pos <= len(src) {
+		pos--
+		for pos > 0 && !utf8.RuneStart(src[pos]) {
+			pos--
+		}
+	}
+	return positionAt(src, pos), true
+}
+
+// sourceExcerpt renders the line containing pos with a caret under the
+// offending character. Long lines are clipped around the column.
+func sourceExcerpt(src string, pos SourcePosition) string {
+	lineStart := strings.LastIndexByte(src[:pos.Offset], '\n') + 1
+	lineEnd := strings.IndexByte(src[pos.Offset:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(src)
+	} else {
+		lineEnd += pos.Offset
+	}
+
+	line := []rune(strings.TrimRight(src[lineStart:lineEnd], "\r"))
+	col := pos.Column - 1
+
+	start, end := 0, len(line)
+	prefix, suffix := "", ""
+	if col-start > excerptWidth {
+		start = col - excerptWidth
+		prefix = "..."
+	}
+	if end-col > excerptWidth {
+		end = col + excerptWidth
+		suffix = "..."
+	}
+	if end < start {
+		end = start
+	}
+
+	// Keep tabs in the caret line so the marker lines up with the source
+	var marker strings.Builder
+	marker.WriteString(strings.Repeat(" ", len(prefix)))
+	for _, r := range line[start:min(col, end)] {
+		if r == '\t' {
+			marker.WriteRune('\t')
+		} else {
+			marker.WriteByte(' ')
+		}
+	}
+	marker.WriteByte('^')
+
+	return prefix + string(line[start:end]) + suffix + "\n" + marker.String()
+}
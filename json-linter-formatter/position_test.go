@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPositionAt(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		offset int
+		want   SourcePosition
+	}{
+		{"start", `{"a": 1}`, 0, SourcePosition{Offset: 0, Line: 1, Column: 1}},
+		{"same line", `{"a": 1}`, 6, SourcePosition{Offset: 6, Line: 1, Column: 7}},
+		{"second line", "{\n  \"a\": 1\n}", 4, SourcePosition{Offset: 4, Line: 2, Column: 3}},
+		{"multibyte characters count once", `{"é": x}`, 7, SourcePosition{Offset: 7, Line: 1, Column: 7}},
+		{"negative offset clamps", `{}`, -3, SourcePosition{Offset: 0, Line: 1, Column: 1}},
+		{"offset past end clamps", "{\n}", 10, SourcePosition{Offset: 3, Line: 2, Column: 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := positionAt(tt.src, tt.offset); got != tt.want {
+				t.Errorf("positionAt(%q, %d) = %+v, want %+v", tt.src, tt.offset, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsErrorPosition(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		line    int
+		column  int
+		excerpt string
+	}{
+		{
+			name:    "missing value",
+			input:   `{"invalid": "json", "missing": }`,
+			line:    1,
+			column:  32,
+			excerpt: "{\"invalid\": \"json\", \"missing\": }\n                               ^",
+		},
+		{
+			name:    "error on later line",
+			input:   "{\n  \"a\": 1,\n  \"b\": tru\n}",
+			line:    3,
+			column:  11,
+			excerpt: "  \"b\": tru\n          ^",
+		},
+		{
+			name:    "tabs are kept in the caret line",
+			input:   "{\n\t\"a\": ?\n}",
+			line:    2,
+			column:  7,
+			excerpt: "\t\"a\": ?\n\t     ^",
+		},
+		{
+			name:    "bare word",
+			input:   `invalid json string`,
+			line:    1,
+			column:  1,
+			excerpt: "invalid json string\n^",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := validateAndFormatJSON(tt.input, ValidateOptions{})
+			if result.IsValid {
+				t.Fatalf("expected %q to be invalid", tt.input)
+			}
+			if result.LineNumber != tt.line || result.Column != tt.column {
+				t.Errorf("position = %d:%d, want %d:%d", result.LineNumber, result.Column, tt.line, tt.column)
+			}
+			if result.Excerpt != tt.excerpt {
+				t.Errorf("excerpt =\n%s\nwant\n%s", result.Excerpt, tt.excerpt)
+			}
+		})
+	}
+}
+
+func TestSourceExcerptClipsLongLines(t *testing.T) {
+	src := `[` + strings.Repeat("1,", 60) + `x]`
+	pos := positionAt(src, len(src)-2)
+
+	got := sourceExcerpt(src, pos)
+	want := "..." + src[pos.Offset-excerptWidth:] + "\n" + strings.Repeat(" ", 3+excerptWidth) + "^"
+	if got != want {
+		t.Errorf("sourceExcerpt =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestErrorPositionWithoutOffset(t *testing.T) {
+	if _, ok := errorPosition(`{}`, errors.New("no offset")); ok {
+		t.Error("expected errors without an offset to have no position")
+	}
+}
@@ -0,0 +1,1233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// defaultQueryLimit caps the number of matches returned unless the caller
+// asks for more
+const defaultQueryLimit = 1000
+
+// Query languages
+const (
+	queryJSONPath = "jsonpath"
+	queryJQ       = "jq"
+)
+
+// QueryOptions are the options of the query operation. Language is
+// jsonpath or jq; when empty it is jsonpath for queries starting with $
+// and jq otherwise.
+type QueryOptions struct {
+	Query    string `json:"query"`
+	Language string `json:"language"`
+	Limit    int    `json:"limit"`
+}
+
+// QueryMatch is one value produced by a query. Values computed by the
+// query, such as comparisons or lengths, have no location in the document.
+type QueryMatch struct {
+	Pointer  string          `json:"pointer"`
+	Computed bool            `json:"computed,omitempty"`
+	Value    json.RawMessage `json:"value"`
+}
+
+// QueryResult is the result of the query operation
+type QueryResult struct {
+	Language  string       `json:"language"`
+	Count     int          `json:"count"`
+	Truncated bool         `json:"truncated,omitempty"`
+	Matches   []QueryMatch `json:"matches"`
+}
+
+// runQuery evaluates a JSONPath or jq expression against the input
+func runQuery(req OperationRequest) (interface{}, error) {
+	var opts QueryOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(opts.Query) == "" {
+		return nil, fmt.Errorf("query is required")
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = defaultQueryLimit
+	}
+
+	language := opts.Language
+	if language == "" {
+		language = queryJQ
+		if strings.HasPrefix(strings.TrimSpace(opts.Query), "$") {
+			language = queryJSONPath
+		}
+	}
+
+	var filter queryFilter
+	var err error
+	switch language {
+	case queryJSONPath:
+		filter, err = parseJSONPath(opts.Query)
+	case queryJQ:
+		filter, err = parseJQ(opts.Query)
+	default:
+		return nil, fmt.Errorf("invalid language %q (expected jsonpath or jq)", opts.Language)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s query: %w", language, err)
+	}
+
+	root, err := parseTree(req.Input)
+	if err != nil {
+		return nil, fmt.Errorf("invalid input JSON: %w", err)
+	}
+
+	env := &queryEnv{root: located{n: root}}
+	values, err := filter.eval(env, env.root)
+	if err != nil {
+		return nil, err
+	}
+
+	result := QueryResult{Language: language, Count: len(values), Matches: []QueryMatch{}}
+	for _, v := range values {
+		if len(result.Matches) == opts.Limit {
+			result.Truncated = true
+			break
+		}
+		result.Matches = append(result.Matches, QueryMatch{
+			Pointer:  v.pointer,
+			Computed: v.computed,
+			Value:    json.RawMessage(v.n.compact()),
+		})
+	}
+	return result, nil
+}
+
+// located is a value together with where it sits in the document
+type located struct {
+	n        *node
+	pointer  string
+	computed bool
+}
+
+// computedValue wraps a value produced by the query itself
+func computedValue(n *node) located {
+	return located{n: n, computed: true}
+}
+
+func boolNode(b bool) *node {
+	return &node{Kind: nodeBool, Raw: strconv.FormatBool(b)}
+}
+
+func nullNode() *node {
+	return &node{Kind: nodeNull, Raw: "null"}
+}
+
+func numberNode(n int) *node {
+	return &node{Kind: nodeNumber, Raw: strconv.Itoa(n)}
+}
+
+func stringNode(s string) *node {
+	return &node{Kind: nodeString, Raw: jsonQuote(s)}
+}
+
+// truthy applies jq truthiness: everything except false and null is true
+func truthy(n *node) bool {
+	return n.Kind != nodeNull && !(n.Kind == nodeBool && n.Raw == "false")
+}
+
+// queryEnv holds state shared by every filter of one evaluation
+type queryEnv struct {
+	root located
+}
+
+// queryFilter is one step of a compiled query. It maps an input value to
+// zero or more output values.
+type queryFilter interface {
+	eval(env *queryEnv, input located) ([]located, error)
+}
+
+type identityFilter struct{}
+
+func (identityFilter) eval(_ *queryEnv, input located) ([]located, error) {
+	return []located{input}, nil
+}
+
+type rootFilter struct{}
+
+func (rootFilter) eval(env *queryEnv, _ located) ([]located, error) {
+	return []located{env.root}, nil
+}
+
+type literalFilter struct{ n *node }
+
+func (f literalFilter) eval(_ *queryEnv, _ located) ([]located, error) {
+	return []located{computedValue(f.n)}, nil
+}
+
+// fieldFilter selects an object member. In strict (JSONPath) mode anything
+// other than an object with that member yields nothing; in jq mode a
+// missing member or null input yields null.
+type fieldFilter struct {
+	name   string
+	strict bool
+}
+
+func (f fieldFilter) eval(_ *queryEnv, input located) ([]located, error) {
+	if input.n.Kind == nodeObject {
+		for i := len(input.n.Members) - 1; i >= 0; i-- {
+			if m := input.n.Members[i]; m.Key == f.name {
+				return []located{{n: m.Value, pointer: appendPointer(input.pointer, m.Key), computed: input.computed}}, nil
+			}
+		}
+	}
+	if f.strict {
+		return nil, nil
+	}
+	if input.n.Kind != nodeObject && input.n.Kind != nodeNull {
+		return nil, fmt.Errorf("cannot index %s with %q", input.n.Kind, f.name)
+	}
+	return []located{computedValue(nullNode())}, nil
+}
+
+// indexFilter selects an array item. Negative indexes count from the end.
+type indexFilter struct {
+	index  int
+	strict bool
+}
+
+func (f indexFilter) eval(_ *queryEnv, input located) ([]located, error) {
+	if input.n.Kind == nodeArray {
+		i := f.index
+		if i < 0 {
+			i += len(input.n.Items)
+		}
+		if i >= 0 && i < len(input.n.Items) {
+			return []located{input.child(i)}, nil
+		}
+	}
+	if f.strict {
+		return nil, nil
+	}
+	if input.n.Kind != nodeArray && input.n.Kind != nodeNull {
+		return nil, fmt.Errorf("cannot index %s with number", input.n.Kind)
+	}
+	return []located{computedValue(nullNode())}, nil
+}
+
+// child returns the located array item at i
+func (l located) child(i int) located {
+	return located{n: l.n.Items[i], pointer: appendPointerIndex(l.pointer, i), computed: l.computed}
+}
+
+// sliceFilter selects a range of array items, as in Python slices
+type sliceFilter struct {
+	start, end *int
+	step       int
+	strict     bool
+}
+
+func (f sliceFilter) eval(_ *queryEnv, input located) ([]located, error) {
+	if input.n.Kind != nodeArray {
+		if f.strict || input.n.Kind == nodeNull {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot slice %s", input.n.Kind)
+	}
+
+	length := len(input.n.Items)
+	bound := func(p *int, def int) int {
+		if p == nil {
+			return def
+		}
+		i := *p
+		if i < 0 {
+			i += length
+		}
+		if i < 0 {
+			i = -1
+		}
+		if i > length {
+			i = length
+		}
+		return i
+	}
+
+	var out []located
+	switch {
+	case f.step > 0:
+		start, end := bound(f.start, 0), bound(f.end, length)
+		if start < 0 {
+			start = 0
+		}
+		for i := start; i < end; i += f.step {
+			out = append(out, input.child(i))
+		}
+	case f.step < 0:
+		start, end := bound(f.start, length-1), bound(f.end, -1)
+		if start >= length {
+			start = length - 1
+		}
+		for i := start; i > end && i >= 0; i += f.step {
+			out = append(out, input.child(i))
+		}
+	}
+	return out, nil
+}
+
+// iterateFilter yields every member value or array item
+type iterateFilter struct{ strict bool }
+
+func (f iterateFilter) eval(_ *queryEnv, input located) ([]located, error) {
+	switch input.n.Kind {
+	case nodeObject:
+		out := make([]located, 0, len(input.n.Members))
+		for _, m := range input.n.Members {
+			out = append(out, located{n: m.Value, pointer: appendPointer(input.pointer, m.Key), computed: input.computed})
+		}
+		return out, nil
+	case nodeArray:
+		out := make([]located, 0, len(input.n.Items))
+		for i := range input.n.Items {
+			out = append(out, input.child(i))
+		}
+		return out, nil
+	}
+	if f.strict {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("cannot iterate over %s", input.n.Kind)
+}
+
+// recurseFilter yields the input and everything below it, depth first
+type recurseFilter struct{}
+
+func (recurseFilter) eval(_ *queryEnv, input located) ([]located, error) {
+	var out []located
+	var walk func(l located)
+	walk = func(l located) {
+		out = append(out, l)
+		children, _ := iterateFilter{strict: true}.eval(nil, l)
+		for _, child := range children {
+			walk(child)
+		}
+	}
+	walk(input)
+	return out, nil
+}
+
+// pipeFilter feeds every output of left into right. On error the outputs
+// produced before it are returned too, so try can keep them.
+type pipeFilter struct{ left, right queryFilter }
+
+func (f pipeFilter) eval(env *queryEnv, input located) ([]located, error) {
+	lefts, err := f.left.eval(env, input)
+	if err != nil {
+		return nil, err
+	}
+	var out []located
+	for _, l := range lefts {
+		rights, err := f.right.eval(env, l)
+		out = append(out, rights...)
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+// commaFilter concatenates the outputs of both sides
+type commaFilter struct{ left, right queryFilter }
+
+func (f commaFilter) eval(env *queryEnv, input located) ([]located, error) {
+	lefts, err := f.left.eval(env, input)
+	if err != nil {
+		return lefts, err
+	}
+	rights, err := f.right.eval(env, input)
+	return append(lefts, rights...), err
+}
+
+// tryFilter drops the errors of its inner filter, like jq's ? suffix. As
+// in jq, the outputs produced before the error are kept.
+type tryFilter struct{ inner queryFilter }
+
+func (f tryFilter) eval(env *queryEnv, input located) ([]located, error) {
+	out, _ := f.inner.eval(env, input)
+	return out, nil
+}
+
+// selectFilter passes the input through when the condition holds
+type selectFilter struct{ cond queryFilter }
+
+func (f selectFilter) eval(env *queryEnv, input located) ([]located, error) {
+	results, err := f.cond.eval(env, input)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		if truthy(r.n) {
+			return []located{input}, nil
+		}
+	}
+	return nil, nil
+}
+
+// existsFilter is true when its inner filter produces anything. JSONPath
+// filters use it for bare paths such as [?@.isbn].
+type existsFilter struct{ inner queryFilter }
+
+func (f existsFilter) eval(env *queryEnv, input located) ([]located, error) {
+	results, err := f.inner.eval(env, input)
+	if err != nil {
+		return nil, err
+	}
+	return []located{computedValue(boolNode(len(results) > 0))}, nil
+}
+
+// logicFilter combines conditions with and, or or not. Not ignores right.
+type logicFilter struct {
+	op          string
+	left, right queryFilter
+}
+
+func (f logicFilter) eval(env *queryEnv, input located) ([]located, error) {
+	holds := func(cond queryFilter) (bool, error) {
+		results, err := cond.eval(env, input)
+		if err != nil {
+			return false, err
+		}
+		for _, r := range results {
+			if truthy(r.n) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	left, err := holds(f.left)
+	if err != nil {
+		return nil, err
+	}
+
+	var value bool
+	switch f.op {
+	case "not":
+		value = !left
+	case "and":
+		value = left
+		if left {
+			if value, err = holds(f.right); err != nil {
+				return nil, err
+			}
+		}
+	case "or":
+		value = left
+		if !left {
+			if value, err = holds(f.right); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return []located{computedValue(boolNode(value))}, nil
+}
+
+// compareFilter compares the outputs of both sides. In singular (JSONPath)
+// mode each side is a single value or nothing, and nothing only equals
+// nothing; in jq mode every pair of outputs is compared.
+type compareFilter struct {
+	op          string
+	left, right queryFilter
+	singular    bool
+}
+
+func (f compareFilter) eval(env *queryEnv, input located) ([]located, error) {
+	lefts, err := f.left.eval(env, input)
+	if err != nil {
+		return nil, err
+	}
+	rights, err := f.right.eval(env, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.singular {
+		if len(lefts) != 1 || len(rights) != 1 {
+			bothEmpty := len(lefts) == 0 && len(rights) == 0
+			value := (f.op == "==" && bothEmpty) || (f.op == "!=" && !bothEmpty)
+			return []located{computedValue(boolNode(value))}, nil
+		}
+		return []located{computedValue(boolNode(compareNodes(f.op, lefts[0].n, rights[0].n, false)))}, nil
+	}
+
+	var out []located
+	for _, r := range rights {
+		for _, l := range lefts {
+			out = append(out, computedValue(boolNode(compareNodes(f.op, l.n, r.n, true))))
+		}
+	}
+	return out, nil
+}
+
+// compareNodes applies a comparison operator. JSONPath only orders numbers
+// and strings; jq orders values of different types by type first.
+func compareNodes(op string, a, b *node, ordered bool) bool {
+	av, bv := a.value(), b.value()
+	if op == "==" {
+		return jsonEqual(av, bv)
+	}
+	if op == "!=" {
+		return !jsonEqual(av, bv)
+	}
+
+	var cmp int
+	ra, aNum := numberRat(av)
+	rb, bNum := numberRat(bv)
+	as, aStr := av.(string)
+	bs, bStr := bv.(string)
+	switch {
+	case aNum && bNum:
+		cmp = ra.Cmp(rb)
+	case aStr && bStr:
+		cmp = strings.Compare(as, bs)
+	case !ordered:
+		return false
+	case jqTypeRank(a) != jqTypeRank(b):
+		cmp = jqTypeRank(a) - jqTypeRank(b)
+	case jsonEqual(av, bv):
+		cmp = 0
+	default:
+		cmp = strings.Compare(a.compact(), b.compact())
+	}
+
+	switch op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+// jqTypeRank orders types the way jq sorts them
+func jqTypeRank(n *node) int {
+	switch n.Kind {
+	case nodeNull:
+		return 0
+	case nodeBool:
+		if n.Raw == "false" {
+			return 1
+		}
+		return 2
+	case nodeNumber:
+		return 3
+	case nodeString:
+		return 4
+	case nodeArray:
+		return 5
+	default:
+		return 6
+	}
+}
+
+// builtinFilter implements the jq builtins length and keys
+type builtinFilter struct{ name string }
+
+func (f builtinFilter) eval(_ *queryEnv, input located) ([]located, error) {
+	n := input.n
+	switch f.name {
+	case "length":
+		switch n.Kind {
+		case nodeObject:
+			return []located{computedValue(numberNode(len(n.Members)))}, nil
+		case nodeArray:
+			return []located{computedValue(numberNode(len(n.Items)))}, nil
+		case nodeString:
+			s, _ := n.value().(string)
+			return []located{computedValue(numberNode(len([]rune(s))))}, nil
+		case nodeNull:
+			return []located{computedValue(numberNode(0))}, nil
+		case nodeNumber:
+			r, _ := new(big.Rat).SetString(n.Raw)
+			return []located{computedValue(&node{Kind: nodeNumber, Raw: r.Abs(r).RatString()})}, nil
+		}
+		return nil, fmt.Errorf("%s has no length", n.Kind)
+	case "keys":
+		switch n.Kind {
+		case nodeObject:
+			keys := make([]string, 0, len(n.Members))
+			seen := make(map[string]bool)
+			for _, m := range n.Members {
+				if !seen[m.Key] {
+					seen[m.Key] = true
+					keys = append(keys, m.Key)
+				}
+			}
+			sort.Strings(keys)
+			arr := &node{Kind: nodeArray}
+			for _, k := range keys {
+				arr.Items = append(arr.Items, stringNode(k))
+			}
+			return []located{computedValue(arr)}, nil
+		case nodeArray:
+			arr := &node{Kind: nodeArray}
+			for i := range n.Items {
+				arr.Items = append(arr.Items, numberNode(i))
+			}
+			return []located{computedValue(arr)}, nil
+		}
+		return nil, fmt.Errorf("%s has no keys", n.Kind)
+	}
+	return nil, fmt.Errorf("unknown function %s", f.name)
+}
+
+// queryToken is a lexical element of a query expression
+type queryToken struct {
+	kind string // "op", "name", "string", "number" or "eof"
+	text string
+	pos  int
+}
+
+// queryOperators lists the punctuation tokens, longest first
+var queryOperators = []string{"..", "==", "!=", "<=", ">=", "&&", "||", ".", "[", "]", "(", ")", ",", ":", "?", "*", "|", "$", "@", "<", ">", "!"}
+
+// lexQuery splits a query into tokens
+func lexQuery(src string) ([]queryToken, error) {
+	var tokens []queryToken
+	pos := 0
+	for {
+		for pos < len(src) && unicode.IsSpace(rune(src[pos])) {
+			pos++
+		}
+		if pos >= len(src) {
+			return append(tokens, queryToken{kind: "eof", pos: pos}), nil
+		}
+
+		start := pos
+		c := src[pos]
+		switch {
+		case c == '"' || c == '\'':
+			text, end, err := lexQueryString(src, pos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, queryToken{kind: "string", text: text, pos: start})
+			pos = end
+			continue
+		case c >= '0' && c <= '9' || c == '-' && pos+1 < len(src) && src[pos+1] >= '0' && src[pos+1] <= '9':
+			pos++
+			for pos < len(src) && (src[pos] >= '0' && src[pos] <= '9' || strings.IndexByte(".eE+-", src[pos]) >= 0) {
+				if (src[pos] == '+' || src[pos] == '-') && src[pos-1] != 'e' && src[pos-1] != 'E' {
+					break
+				}
+				pos++
+			}
+			tokens = append(tokens, queryToken{kind: "number", text: src[start:pos], pos: start})
+			continue
+		case c == '_' || c < 0x80 && unicode.IsLetter(rune(c)) || c >= 0x80:
+			for pos < len(src) {
+				r := rune(src[pos])
+				if r == '_' || r >= 0x80 || unicode.IsLetter(r) || unicode.IsDigit(r) {
+					pos++
+					continue
+				}
+				break
+			}
+			tokens = append(tokens, queryToken{kind: "name", text: src[start:pos], pos: start})
+			continue
+		}
+
+		matched := false
+		for _, op := range queryOperators {
+			if strings.HasPrefix(src[pos:], op) {
+				tokens = append(tokens, queryToken{kind: "op", text: op, pos: start})
+				pos += len(op)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("unexpected character %q at position %d", c, pos+1)
+		}
+	}
+}
+
+// lexQueryString reads a single- or double-quoted string starting at pos
+func lexQueryString(src string, pos int) (string, int, error) {
+	quote := src[pos]
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := pos + 1; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == quote:
+			b.WriteByte('"')
+			var text string
+			if err := json.Unmarshal([]byte(b.String()), &text); err != nil {
+				return "", 0, fmt.Errorf("invalid string at position %d", pos+1)
+			}
+			return text, i + 1, nil
+		case c == '\\' && i+1 < len(src):
+			i++
+			if src[i] == '\'' {
+				b.WriteByte('\'')
+			} else {
+				b.WriteByte('\\')
+				b.WriteByte(src[i])
+			}
+		case c == '"':
+			b.WriteString(`\"`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string at position %d", pos+1)
+}
+
+// queryParser is a recursive descent parser over query tokens
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+	t := p.tokens[p.pos]
+	if t.kind != "eof" {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the operator op
+func (p *queryParser) accept(op string) bool {
+	if t := p.peek(); t.kind == "op" && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// expect consumes the operator op or fails
+func (p *queryParser) expect(op string) error {
+	if !p.accept(op) {
+		return p.unexpected("'" + op + "'")
+	}
+	return nil
+}
+
+// unexpected reports the next token as an error
+func (p *queryParser) unexpected(want string) error {
+	t := p.peek()
+	if t.kind == "eof" {
+		return fmt.Errorf("unexpected end of query, expected %s", want)
+	}
+	return fmt.Errorf("unexpected %q at position %d, expected %s", t.text, t.pos+1, want)
+}
+
+// integer parses an integer token
+func (p *queryParser) integer() (int, error) {
+	t := p.peek()
+	if t.kind != "number" {
+		return 0, p.unexpected("integer")
+	}
+	i, err := strconv.Atoi(t.text)
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer %q at position %d", t.text, t.pos+1)
+	}
+	p.pos++
+	return i, nil
+}
+
+// literal parses a string, number, true, false or null literal
+func (p *queryParser) literal() (queryFilter, bool, error) {
+	t := p.peek()
+	switch {
+	case t.kind == "string":
+		p.pos++
+		return literalFilter{stringNode(t.text)}, true, nil
+	case t.kind == "number":
+		if _, ok := new(big.Rat).SetString(t.text); !ok || !isStrictNumber(t.text) {
+			return nil, false, fmt.Errorf("invalid number %q at position %d", t.text, t.pos+1)
+		}
+		p.pos++
+		return literalFilter{&node{Kind: nodeNumber, Raw: t.text}}, true, nil
+	case t.kind == "name" && (t.text == "true" || t.text == "false"):
+		p.pos++
+		return literalFilter{boolNode(t.text == "true")}, true, nil
+	case t.kind == "name" && t.text == "null":
+		p.pos++
+		return literalFilter{nullNode()}, true, nil
+	}
+	return nil, false, nil
+}
+
+// comparison parses an optional comparison operator
+func (p *queryParser) comparison() (string, bool) {
+	t := p.peek()
+	if t.kind == "op" {
+		switch t.text {
+		case "==", "!=", "<", "<=", ">", ">=":
+			p.pos++
+			return t.text, true
+		}
+	}
+	return "", false
+}
+
+// chain joins filters with a pipe, skipping identities
+func chain(left, right queryFilter) queryFilter {
+	if _, ok := left.(identityFilter); ok {
+		return right
+	}
+	return pipeFilter{left, right}
+}
+
+// parseJSONPath compiles a JSONPath expression (RFC 9535)
+func parseJSONPath(src string) (queryFilter, error) {
+	tokens, err := lexQuery(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens}
+	if err := p.expect("$"); err != nil {
+		return nil, err
+	}
+
+	f, err := p.pathSegments(rootFilter{})
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != "eof" {
+		return nil, p.unexpected("end of query")
+	}
+	return f, nil
+}
+
+// pathSegments parses the child and descendant segments after $ or @
+func (p *queryParser) pathSegments(f queryFilter) (queryFilter, error) {
+	for {
+		switch {
+		case p.accept(".."):
+			sel, err := p.dotSelector()
+			if err != nil {
+				return nil, err
+			}
+			f = chain(f, pipeFilter{recurseFilter{}, sel})
+		case p.accept("."):
+			sel, err := p.dotSelector()
+			if err != nil {
+				return nil, err
+			}
+			f = chain(f, sel)
+		case p.accept("["):
+			sel, err := p.bracketSelectors()
+			if err != nil {
+				return nil, err
+			}
+			f = chain(f, sel)
+		default:
+			return f, nil
+		}
+	}
+}
+
+// dotSelector parses the name, wildcard or bracket after . or ..
+func (p *queryParser) dotSelector() (queryFilter, error) {
+	t := p.peek()
+	switch {
+	case t.kind == "name":
+		p.pos++
+		return fieldFilter{name: t.text, strict: true}, nil
+	case p.accept("*"):
+		return iterateFilter{strict: true}, nil
+	case p.accept("["):
+		return p.bracketSelectors()
+	}
+	return nil, p.unexpected("member name or '*'")
+}
+
+// bracketSelectors parses a comma-separated selector list up to ]
+func (p *queryParser) bracketSelectors() (queryFilter, error) {
+	var f queryFilter
+	for {
+		sel, err := p.bracketSelector()
+		if err != nil {
+			return nil, err
+		}
+		if f == nil {
+			f = sel
+		} else {
+			f = commaFilter{f, sel}
+		}
+
+		if p.accept("]") {
+			return f, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, p.unexpected("',' or ']'")
+		}
+	}
+}
+
+// bracketSelector parses one name, wildcard, index, slice or filter
+func (p *queryParser) bracketSelector() (queryFilter, error) {
+	t := p.peek()
+	switch {
+	case t.kind == "string":
+		p.pos++
+		return fieldFilter{name: t.text, strict: true}, nil
+	case p.accept("*"):
+		return iterateFilter{strict: true}, nil
+	case p.accept("?"):
+		cond, err := p.pathOr()
+		if err != nil {
+			return nil, err
+		}
+		return pipeFilter{iterateFilter{strict: true}, selectFilter{cond}}, nil
+	case t.kind == "number" || t.kind == "op" && t.text == ":":
+		return p.indexOrSlice(true)
+	}
+	return nil, p.unexpected("selector")
+}
+
+// indexOrSlice parses n, start:end or start:end:step
+func (p *queryParser) indexOrSlice(strict bool) (queryFilter, error) {
+	var bounds [3]*int
+	for part := 0; part < 3; part++ {
+		if p.peek().kind == "number" {
+			i, err := p.integer()
+			if err != nil {
+				return nil, err
+			}
+			bounds[part] = &i
+		}
+		if part == 0 && bounds[0] != nil && !(p.peek().kind == "op" && p.peek().text == ":") {
+			return indexFilter{index: *bounds[0], strict: strict}, nil
+		}
+		if part == 2 || !p.accept(":") {
+			break
+		}
+	}
+
+	step := 1
+	if bounds[2] != nil {
+		step = *bounds[2]
+	}
+	return sliceFilter{start: bounds[0], end: bounds[1], step: step, strict: strict}, nil
+}
+
+// pathOr parses a JSONPath filter expression: || has the lowest precedence
+func (p *queryParser) pathOr() (queryFilter, error) {
+	left, err := p.pathAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.pathAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicFilter{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) pathAnd() (queryFilter, error) {
+	left, err := p.pathUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.pathUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = logicFilter{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) pathUnary() (queryFilter, error) {
+	if p.accept("!") {
+		inner, err := p.pathUnary()
+		if err != nil {
+			return nil, err
+		}
+		return logicFilter{op: "not", left: inner}, nil
+	}
+	if p.accept("(") {
+		inner, err := p.pathOr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	}
+
+	left, isPath, err := p.pathComparable()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.comparison()
+	if !ok {
+		if !isPath {
+			return nil, p.unexpected("comparison operator")
+		}
+		return existsFilter{left}, nil
+	}
+	right, _, err := p.pathComparable()
+	if err != nil {
+		return nil, err
+	}
+	return compareFilter{op: op, left: left, right: right, singular: true}, nil
+}
+
+// pathComparable parses a literal or a query starting with @ or $
+func (p *queryParser) pathComparable() (queryFilter, bool, error) {
+	if lit, ok, err := p.literal(); ok || err != nil {
+		return lit, false, err
+	}
+	switch {
+	case p.accept("@"):
+		f, err := p.pathSegments(identityFilter{})
+		return f, true, err
+	case p.accept("$"):
+		f, err := p.pathSegments(rootFilter{})
+		return f, true, err
+	}
+	return nil, false, p.unexpected("value or path")
+}
+
+// parseJQ compiles a jq filter. The supported subset covers paths (.a.b,
+// .[0], .[], .[1:3], ..), pipes, commas, comparisons, and/or/not, literals,
+// parentheses and the builtins select, length and keys.
+func parseJQ(src string) (queryFilter, error) {
+	tokens, err := lexQuery(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens}
+	f, err := p.jqPipe()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != "eof" {
+		return nil, p.unexpected("end of filter")
+	}
+	return f, nil
+}
+
+func (p *queryParser) jqPipe() (queryFilter, error) {
+	left, err := p.jqComma()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("|") {
+		right, err := p.jqComma()
+		if err != nil {
+			return nil, err
+		}
+		left = pipeFilter{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) jqComma() (queryFilter, error) {
+	left, err := p.jqOr()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(",") {
+		right, err := p.jqOr()
+		if err != nil {
+			return nil, err
+		}
+		left = commaFilter{left, right}
+	}
+	return left, nil
+}
+
+// acceptName consumes the next token if it is the keyword name
+func (p *queryParser) acceptName(name string) bool {
+	if t := p.peek(); t.kind == "name" && t.text == name {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) jqOr() (queryFilter, error) {
+	left, err := p.jqAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptName("or") {
+		right, err := p.jqAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicFilter{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) jqAnd() (queryFilter, error) {
+	left, err := p.jqCompare()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptName("and") {
+		right, err := p.jqCompare()
+		if err != nil {
+			return nil, err
+		}
+		left = logicFilter{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) jqCompare() (queryFilter, error) {
+	left, err := p.jqPostfix()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.comparison()
+	if !ok {
+		return left, nil
+	}
+	right, err := p.jqPostfix()
+	if err != nil {
+		return nil, err
+	}
+	return compareFilter{op: op, left: left, right: right}, nil
+}
+
+// jqPostfix parses a term followed by any number of .name, [..] and ?
+func (p *queryParser) jqPostfix() (queryFilter, error) {
+	f, err := p.jqTerm()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.accept("."):
+			sel, err := p.jqDotSuffix(false)
+			if err != nil {
+				return nil, err
+			}
+			f = chain(f, sel)
+		case p.accept("["):
+			sel, err := p.jqBracket()
+			if err != nil {
+				return nil, err
+			}
+			f = chain(f, sel)
+		case p.accept("?"):
+			f = tryFilter{f}
+		default:
+			return f, nil
+		}
+	}
+}
+
+// jqDotSuffix parses what follows a dot. A lone dot is the identity when
+// it starts a term.
+func (p *queryParser) jqDotSuffix(allowIdentity bool) (queryFilter, error) {
+	t := p.peek()
+	switch {
+	case t.kind == "name" || t.kind == "string":
+		p.pos++
+		return fieldFilter{name: t.text}, nil
+	case p.accept("["):
+		return p.jqBracket()
+	case allowIdentity:
+		return identityFilter{}, nil
+	}
+	return nil, p.unexpected("field name")
+}
+
+// jqBracket parses the inside of [...] after the opening bracket
+func (p *queryParser) jqBracket() (queryFilter, error) {
+	if p.accept("]") {
+		return iterateFilter{}, nil
+	}
+
+	var f queryFilter
+	t := p.peek()
+	switch {
+	case t.kind == "string":
+		p.pos++
+		f = fieldFilter{name: t.text}
+	case t.kind == "number" || t.kind == "op" && t.text == ":":
+		var err error
+		if f, err = p.indexOrSlice(false); err != nil {
+			return nil, err
+		}
+		if slice, ok := f.(sliceFilter); ok && slice.step != 1 {
+			return nil, fmt.Errorf("jq slices do not take a step")
+		}
+	default:
+		return nil, p.unexpected("index, slice or field name")
+	}
+	return f, p.expect("]")
+}
+
+// jqTerm parses a path, literal, parenthesised filter or builtin call
+func (p *queryParser) jqTerm() (queryFilter, error) {
+	if p.accept("..") {
+		return recurseFilter{}, nil
+	}
+	if p.accept(".") {
+		return p.jqDotSuffix(true)
+	}
+	if p.accept("(") {
+		f, err := p.jqPipe()
+		if err != nil {
+			return nil, err
+		}
+		return f, p.expect(")")
+	}
+	if lit, ok, err := p.literal(); ok || err != nil {
+		return lit, err
+	}
+
+	t := p.peek()
+	if t.kind == "name" {
+		p.pos++
+		switch t.text {
+		case "select":
+			if err := p.expect("("); err != nil {
+				return nil, err
+			}
+			cond, err := p.jqPipe()
+			if err != nil {
+				return nil, err
+			}
+			return selectFilter{cond}, p.expect(")")
+		case "not":
+			return logicFilter{op: "not", left: identityFilter{}}, nil
+		case "length", "keys":
+			return builtinFilter{name: t.text}, nil
+		case "empty":
+			return emptyFilter{}, nil
+		}
+		return nil, fmt.Errorf("unsupported function %q at position %d", t.text, t.pos+1)
+	}
+	return nil, p.unexpected("filter")
+}
+
+// emptyFilter produces no output
+type emptyFilter struct{}
+
+func (emptyFilter) eval(_ *queryEnv, _ located) ([]located, error) {
+	return nil, nil
+}
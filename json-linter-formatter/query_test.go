@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const queryDocument = `{
+  "store": {
+    "books": [
+      {"title": "A", "price": 8.95, "tags": ["x"]},
+      {"title": "B", "price": 12.99},
+      {"title": "C", "price": 8.950, "isbn": "0-553"}
+    ],
+    "owner": null
+  },
+  "a/b": 1
+}`
+
+func TestRunQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		language string
+		want     []string
+	}{
+		{"jsonpath child", "$.store.books[0].title", queryJSONPath, []string{`/store/books/0/title "A"`}},
+		{"jsonpath wildcard", "$.store.books[*].price", queryJSONPath, []string{`/store/books/0/price 8.95`, `/store/books/1/price 12.99`, `/store/books/2/price 8.950`}},
+		{"jsonpath recursive descent", "$..isbn", queryJSONPath, []string{`/store/books/2/isbn "0-553"`}},
+		{"jsonpath slice", "$.store.books[-2:].title", queryJSONPath, []string{`/store/books/1/title "B"`, `/store/books/2/title "C"`}},
+		{"jsonpath filter", "$.store.books[?(@.price < 10)].title", queryJSONPath, []string{`/store/books/0/title "A"`, `/store/books/2/title "C"`}},
+		{"jsonpath filter exists", "$.store.books[?(@.tags)].title", queryJSONPath, []string{`/store/books/0/title "A"`}},
+		{"jsonpath escaped key", "$['a/b']", queryJSONPath, []string{`/a~1b 1`}},
+		{"jq field", ".store.owner", queryJQ, []string{`/store/owner null`}},
+		{"jq iterate and select", `.store.books[] | select(.price > 10) | .title`, queryJQ, []string{`/store/books/1/title "B"`}},
+		{"jq comma", ".store.books[0].title, .store.books[1].title", queryJQ, []string{`/store/books/0/title "A"`, `/store/books/1/title "B"`}},
+		{"jq optional", `.store.books[].tags[]?`, queryJQ, []string{`/store/books/0/tags/0 "x"`}},
+		{"jq length is computed", ".store.books | length", queryJQ, []string{` 3 computed`}},
+		{"jq keys", ".store | keys", queryJQ, []string{` ["books","owner"] computed`}},
+		{"jq numbers compare by value", `.store.books[] | select(.price == 8.95) | .title`, queryJQ, []string{`/store/books/0/title "A"`, `/store/books/2/title "C"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options, _ := json.Marshal(QueryOptions{Query: tt.query})
+			value, err := runQuery(OperationRequest{Operation: "query", Input: queryDocument, Options: options})
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := value.(QueryResult)
+			if result.Language != tt.language {
+				t.Errorf("language = %q, want %q", result.Language, tt.language)
+			}
+
+			var got []string
+			for _, m := range result.Matches {
+				match := m.Pointer + " " + string(m.Value)
+				if m.Computed {
+					match += " computed"
+				}
+				got = append(got, match)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("matches =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestRunQueryLimit(t *testing.T) {
+	value, err := runQuery(OperationRequest{Input: `[1, 2, 3]`, Options: []byte(`{"query": ".[]", "limit": 2}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := value.(QueryResult)
+	if result.Count != 3 || len(result.Matches) != 2 || !result.Truncated {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestRunQueryErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+		input   string
+	}{
+		{"missing query", `{}`, `{}`},
+		{"unknown language", `{"query": ".", "language": "xpath"}`, `{}`},
+		{"jsonpath syntax", `{"query": "$.store[", "language": "jsonpath"}`, `{}`},
+		{"jq syntax", `{"query": ".a |", "language": "jq"}`, `{}`},
+		{"invalid input", `{"query": "."}`, `{"a": }`},
+		{"jq type error", `{"query": ".a | keys"}`, `{"a": 1}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := runQuery(OperationRequest{Input: tt.input, Options: []byte(tt.options)}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// RepairFix describes one change made while repairing a document. The
+// position refers to the original input.
+type RepairFix struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Offset  int    `json:"offset"`
+}
+
+// RepairResult is the result of the repair operation
+type RepairResult struct {
+	Repaired   string               `json:"repaired"`
+	Fixes      []RepairFix          `json:"fixes"`
+	Changed    bool                 `json:"changed"`
+	Validation JSONValidationResult `json:"validation"`
+}
+
+// repairer rewrites common JSON mistakes into valid JSON
+type repairer struct {
+	src    string
+	pos    int
+	tokens []token
+	fixes  []RepairFix
+}
+
+// fix records a repair at offset
+func (r *repairer) fix(offset int, kind, format string, args ...interface{}) {
+	pos := positionAt(r.src, offset)
+	r.fixes = append(r.fixes, RepairFix{
+		Kind:    kind,
+		Message: fmt.Sprintf(format, args...),
+		Line:    pos.Line,
+		Column:  pos.Column,
+		Offset:  pos.Offset,
+	})
+}
+
+// repairJSON repairs src and returns strict, formatted JSON
+func repairJSON(src string) (RepairResult, error) {
+	if strings.TrimSpace(src) == "" {
+		return RepairResult{}, fmt.Errorf("nothing to repair: input is empty")
+	}
+
+	r := &repairer{src: src}
+	r.tokenize()
+	compact, err := r.rebuild()
+	if err != nil {
+		return RepairResult{}, err
+	}
+
+	formatted, err := formatTokens(compact, currentFormatSettings().style())
+	if err != nil {
+		return RepairResult{}, fmt.Errorf("repair produced unparseable output: %w", err)
+	}
+
+	fixes := append([]RepairFix{}, r.fixes...)
+	sort.SliceStable(fixes, func(i, j int) bool { return fixes[i].Offset < fixes[j].Offset })
+
+	return RepairResult{
+		Repaired:   formatted,
+		Fixes:      fixes,
+		Changed:    len(r.fixes) > 0,
+		Validation: validateJSON(formatted, ValidateOptions{}),
+	}, nil
+}
+
+// tokenize splits the input into tokens, fixing lexical problems as it goes
+func (r *repairer) tokenize() {
+	for {
+		for r.pos < len(r.src) && strings.IndexByte(" \t\r\n", r.src[r.pos]) >= 0 {
+			r.pos++
+		}
+		if r.pos >= len(r.src) {
+			return
+		}
+
+		start := r.pos
+		emit := func(kind tokenKind, text string) {
+			r.tokens = append(r.tokens, token{Kind: kind, Text: text, Offset: start})
+		}
+
+		c := r.src[r.pos]
+		switch {
+		case strings.IndexByte("{}[]:,", c) >= 0:
+			r.pos++
+			emit(map[byte]tokenKind{
+				'{': tokenBeginObject, '}': tokenEndObject,
+				'[': tokenBeginArray, ']': tokenEndArray,
+				':': tokenColon, ',': tokenComma,
+			}[c], string(c))
+		case c == '/' && r.pos+1 < len(r.src) && (r.src[r.pos+1] == '/' || r.src[r.pos+1] == '*'):
+			r.skipComment()
+			r.fix(start, "comment", "removed comment")
+		case c == '"' || c == '\'':
+			emit(tokenString, r.scanString(c))
+		case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+			r.scanNumber(start, emit)
+		case isIdentifierStart(c):
+			for r.pos < len(r.src) && isIdentifierPart(r.src[r.pos]) {
+				r.pos++
+			}
+			r.scanWord(start, r.src[start:r.pos], emit)
+		default:
+			ch, size := utf8.DecodeRuneInString(r.src[r.pos:])
+			r.pos += size
+			r.fix(start, "invalid-character", "removed unexpected character %q", ch)
+		}
+	}
+}
+
+// skipComment advances past a // or /* */ comment
+func (r *repairer) skipComment() {
+	if r.src[r.pos+1] == '/' {
+		if end := strings.IndexByte(r.src[r.pos:], '\n'); end >= 0 {
+			r.pos += end
+		} else {
+			r.pos = len(r.src)
+		}
+		return
+	}
+	if end := strings.Index(r.src[r.pos+2:], "*/"); end >= 0 {
+		r.pos += end + 4
+	} else {
+		r.pos = len(r.src)
+	}
+}
+
+// scanString reads a quoted string, converting single quotes, escaping
+// raw control characters and invalid escapes, and closing strings cut off
+// by the end of input
+func (r *repairer) scanString(quote byte) string {
+	start := r.pos
+	if quote == '\'' {
+		r.fix(start, "single-quotes", "replaced single quotes with double quotes")
+	}
+
+	var out strings.Builder
+	out.WriteByte('"')
+	r.pos++
+
+	escapedControl := false
+	for r.pos < len(r.src) {
+		ch, size := utf8.DecodeRuneInString(r.src[r.pos:])
+		switch {
+		case ch == rune(quote):
+			r.pos++
+			out.WriteByte('"')
+			return out.String()
+		case ch == '\\' && r.pos+1 < len(r.src):
+			next := r.src[r.pos+1]
+			switch {
+			case next == '\'':
+				out.WriteByte('\'')
+			case next == 'u' && r.pos+6 <= len(r.src) && isHex(r.src[r.pos+2:r.pos+6]):
+				out.WriteString(r.src[r.pos : r.pos+6])
+				r.pos += 6
+				continue
+			case strings.IndexByte(`"\/bfnrt`, next) >= 0:
+				out.WriteByte('\\')
+				out.WriteByte(next)
+			default:
+				r.fix(r.pos, "invalid-escape", "replaced invalid escape \\%c", next)
+				out.WriteString(`\\`)
+				out.WriteByte(next)
+			}
+			r.pos += 2
+			continue
+		case ch == '\\':
+			r.fix(r.pos, "invalid-escape", "escaped backslash at the end of input")
+			out.WriteString(`\\`)
+		case ch == '"':
+			out.WriteString(`\"`)
+		case ch < 0x20:
+			if !escapedControl {
+				r.fix(r.pos, "control-character", "escaped control character in string")
+				escapedControl = true
+			}
+			out.WriteString(controlEscape(ch))
+		default:
+			out.WriteString(r.src[r.pos : r.pos+size])
+		}
+		r.pos += size
+	}
+
+	r.fix(start, "unterminated-string", "closed string cut off by the end of input")
+	out.WriteByte('"')
+	return out.String()
+}
+
+// scanNumber reads a number, normalising JSON5 spellings and replacing
+// NaN and Infinity with null
+func (r *repairer) scanNumber(start int, emit func(tokenKind, string)) {
+	for r.pos < len(r.src) && isNumberPart(r.src[r.pos]) {
+		r.pos++
+	}
+	text := r.src[start:r.pos]
+
+	if strings.HasSuffix(text, "Infinity") || strings.HasSuffix(text, "NaN") {
+		r.fix(start, "non-finite-number", "replaced %s with null", text)
+		emit(tokenLiteral, "null")
+		return
+	}
+
+	strict, err := strictNumber(text)
+	if err != nil {
+		r.fix(start, "invalid-number", "quoted invalid number %s", text)
+		emit(tokenString, fmt.Sprintf("%q", text))
+		return
+	}
+	if strict != text {
+		r.fix(start, "number-format", "rewrote number %s as %s", text, strict)
+	}
+	emit(tokenNumber, strict)
+}
+
+// pythonLiterals maps literals from Python and JavaScript to JSON
+var pythonLiterals = map[string]string{
+	"True":      "true",
+	"False":     "false",
+	"None":      "null",
+	"TRUE":      "true",
+	"FALSE":     "false",
+	"NULL":      "null",
+	"Null":      "null",
+	"undefined": "null",
+	"NaN":       "null",
+	"Infinity":  "null",
+}
+
+// scanWord handles a bare word: JSON literals pass through, foreign
+// literals are translated, and anything else becomes a string
+func (r *repairer) scanWord(start int, word string, emit func(tokenKind, string)) {
+	switch word {
+	case "true", "false", "null":
+		emit(tokenLiteral, word)
+		return
+	}
+
+	if literal, ok := pythonLiterals[word]; ok {
+		r.fix(start, "foreign-literal", "replaced %s with %s", word, literal)
+		emit(tokenLiteral, literal)
+		return
+	}
+
+	emit(tokenIdentifier, `"`+word+`"`)
+}
+
+// rebuild walks the tokens with a small state machine, inserting and
+// dropping punctuation until the sequence forms a single JSON value
+func (r *repairer) rebuild() (string, error) {
+	var out strings.Builder
+	var stack []tokenKind
+
+	// expectValue is true where a value (or, in an object, a key) may start
+	expectValue := true
+	expectKey := false
+	done := false
+
+	closerFor := func(open tokenKind) (tokenKind, string) {
+		if open == tokenBeginObject {
+			return tokenEndObject, "}"
+		}
+		return tokenEndArray, "]"
+	}
+
+	for i := 0; i < len(r.tokens); i++ {
+		t := r.tokens[i]
+		inObject := len(stack) > 0 && stack[len(stack)-1] == tokenBeginObject
+
+		if done {
+			r.fix(t.Offset, "extra-data", "removed data after the top-level value")
+			break
+		}
+
+		isValueStart := t.Kind == tokenBeginObject || t.Kind == tokenBeginArray || t.Kind == tokenString ||
+			t.Kind == tokenNumber || t.Kind == tokenLiteral || t.Kind == tokenIdentifier
+
+		switch {
+		case isValueStart && expectKey:
+			if t.Kind == tokenIdentifier {
+				r.fix(t.Offset, "unquoted-key", "quoted key %s", t.Text)
+			} else if t.Kind != tokenString {
+				r.fix(t.Offset, "invalid-key", "converted %s key to a string", t.Text)
+				t.Text = fmt.Sprintf("%q", strings.Trim(t.Text, `"`))
+			}
+			out.WriteString(t.Text)
+			expectKey = false
+			expectValue = false
+
+			if i+1 >= len(r.tokens) || r.tokens[i+1].Kind != tokenColon {
+				r.fix(t.Offset, "missing-colon", "inserted missing ':' after key")
+				out.WriteByte(':')
+				expectValue = true
+				if i+1 >= len(r.tokens) || !isValueToken(r.tokens[i+1]) {
+					r.fix(t.Offset, "missing-value", "inserted null for missing value")
+					out.WriteString("null")
+					expectValue = false
+				}
+			}
+			continue
+
+		case isValueStart && !expectValue:
+			r.fix(t.Offset, "missing-comma", "inserted missing comma")
+			out.WriteByte(',')
+			if inObject {
+				expectKey = true
+				i--
+				continue
+			}
+			fallthrough
+
+		case isValueStart:
+			if t.Kind == tokenIdentifier {
+				r.fix(t.Offset, "bare-word", "quoted bare word %s", t.Text)
+			}
+			out.WriteString(t.Text)
+			expectValue = false
+			switch t.Kind {
+			case tokenBeginObject, tokenBeginArray:
+				stack = append(stack, t.Kind)
+				expectValue = true
+				expectKey = t.Kind == tokenBeginObject
+			default:
+				done = len(stack) == 0
+			}
+
+		case t.Kind == tokenColon:
+			if expectValue || !inObject {
+				r.fix(t.Offset, "stray-colon", "removed unexpected ':'")
+				continue
+			}
+			out.WriteByte(':')
+			expectValue = true
+
+		case t.Kind == tokenComma:
+			if strings.HasSuffix(out.String(), ":") {
+				r.fix(t.Offset, "missing-value", "inserted null for missing value")
+				out.WriteString("null")
+				expectValue = false
+			}
+			if expectValue || len(stack) == 0 {
+				r.fix(t.Offset, "extra-comma", "removed extra comma")
+				continue
+			}
+			if i+1 < len(r.tokens) && (r.tokens[i+1].Kind == tokenEndObject || r.tokens[i+1].Kind == tokenEndArray) {
+				r.fix(t.Offset, "trailing-comma", "removed trailing comma")
+				continue
+			}
+			out.WriteByte(',')
+			expectValue = true
+			expectKey = inObject
+
+		case t.Kind == tokenEndObject || t.Kind == tokenEndArray:
+			if len(stack) == 0 {
+				r.fix(t.Offset, "unbalanced-bracket", "removed unmatched %s", t.Text)
+				continue
+			}
+			if strings.HasSuffix(out.String(), ":") {
+				r.fix(t.Offset, "missing-value", "inserted null for missing value")
+				out.WriteString("null")
+			}
+
+			// A closer that matches an outer container closes the inner
+			// ones first; otherwise it is taken as a typo for the right one
+			match := len(stack) - 1
+			for ; match >= 0; match-- {
+				if kind, _ := closerFor(stack[match]); kind == t.Kind {
+					break
+				}
+			}
+			if match < 0 {
+				_, text := closerFor(stack[len(stack)-1])
+				r.fix(t.Offset, "mismatched-bracket", "replaced %s with %s", t.Text, text)
+				match = len(stack) - 1
+			}
+			for len(stack)-1 > match {
+				_, text := closerFor(stack[len(stack)-1])
+				r.fix(t.Offset, "unclosed-bracket", "added missing %s", text)
+				out.WriteString(text)
+				stack = stack[:len(stack)-1]
+			}
+			_, text := closerFor(stack[match])
+			out.WriteString(text)
+			stack = stack[:match]
+			expectValue = false
+			expectKey = false
+			done = len(stack) == 0
+		}
+	}
+
+	repaired := out.String()
+	if repaired == "" {
+		return "", fmt.Errorf("could not find any JSON value to repair")
+	}
+
+	// Input cut off mid-container can leave a dangling comma or colon
+	if strings.HasSuffix(repaired, ",") {
+		r.fix(len(r.src), "trailing-comma", "removed trailing comma")
+		repaired = strings.TrimSuffix(repaired, ",")
+	}
+	if strings.HasSuffix(repaired, ":") {
+		r.fix(len(r.src), "missing-value", "inserted null for missing value")
+		repaired += "null"
+	}
+	for len(stack) > 0 {
+		_, text := closerFor(stack[len(stack)-1])
+		r.fix(len(r.src), "truncated", "added missing %s at end of input", text)
+		repaired += text
+		stack = stack[:len(stack)-1]
+	}
+	return repaired, nil
+}
+
+// isValueToken reports whether t can start a value
+func isValueToken(t token) bool {
+	switch t.Kind {
+	case tokenBeginObject, tokenBeginArray, tokenString, tokenNumber, tokenLiteral, tokenIdentifier:
+		return true
+	}
+	return false
+}
+
+// runRepair repairs the input document
+func runRepair(req OperationRequest) (interface{}, error) {
+	return repairJSON(req.Input)
+}
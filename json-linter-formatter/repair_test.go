@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestRepairJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		kind  string
+	}{
+		{"comment", "{\"a\": 1 // note\n}", `{"a":1}`, "comment"},
+		{"single quotes", `{'a': 'b'}`, `{"a":"b"}`, "single-quotes"},
+		{"invalid character", `{"a": 1 @}`, `{"a":1}`, "invalid-character"},
+		{"invalid escape", `["\q"]`, `["\\q"]`, "invalid-escape"},
+		{"invalid unicode escape", `"\uZZZZ"`, `"\\uZZZZ"`, "invalid-escape"},
+		{"short unicode escape", `["\u12"]`, `["\\u12"]`, "invalid-escape"},
+		{"backslash at end of input", `["a\`, `["a\\"]`, "invalid-escape"},
+		{"control character", "[\"a\tb\"]", `["a\tb"]`, "control-character"},
+		{"unterminated string", `["abc`, `["abc"]`, "unterminated-string"},
+		{"non-finite number", `[NaN, -Infinity]`, `[null,null]`, "non-finite-number"},
+		{"invalid number", `[1.2.3]`, `["1.2.3"]`, "invalid-number"},
+		{"number format", `[0x10, .5, +1]`, `[16,0.5,1]`, "number-format"},
+		{"foreign literal", `[True, None]`, `[true,null]`, "foreign-literal"},
+		{"unquoted key", `{a: 1}`, `{"a":1}`, "unquoted-key"},
+		{"invalid key", `{1: 2}`, `{"1":2}`, "invalid-key"},
+		{"missing colon", `{"a" 1}`, `{"a":1}`, "missing-colon"},
+		{"missing value", `{"a": , "b": 2}`, `{"a":null,"b":2}`, "missing-value"},
+		{"missing comma", `[1 2]`, `[1,2]`, "missing-comma"},
+		{"missing comma between members", `{"a": 1 "b": 2}`, `{"a":1,"b":2}`, "missing-comma"},
+		{"bare word", `[hello]`, `["hello"]`, "bare-word"},
+		{"stray colon", `[1: 2]`, `[1,2]`, "stray-colon"},
+		{"extra comma", `[1,, 2]`, `[1,2]`, "extra-comma"},
+		{"trailing comma", `{"a": 1,}`, `{"a":1}`, "trailing-comma"},
+		{"unbalanced bracket", `[1]]`, `[1]`, "extra-data"},
+		{"mismatched bracket", `[1, 2}`, `[1,2]`, "mismatched-bracket"},
+		{"unclosed bracket", `{"a": [1}`, `{"a":[1]}`, "unclosed-bracket"},
+		{"extra data", `{"a": 1} {"b": 2}`, `{"a":1}`, "extra-data"},
+		{"truncated", `{"a": [1, {"b": 2`, `{"a":[1,{"b":2}]}`, "truncated"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := repairJSON(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.Validation.IsValid {
+				t.Fatalf("repaired output is invalid: %s\n%s", result.Validation.ErrorMessage, result.Repaired)
+			}
+
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, []byte(result.Repaired)); err != nil {
+				t.Fatal(err)
+			}
+			if compact.String() != tt.want {
+				t.Errorf("repaired = %s, want %s", compact.String(), tt.want)
+			}
+
+			found := false
+			for _, fix := range result.Fixes {
+				found = found || fix.Kind == tt.kind
+			}
+			if !found || !result.Changed {
+				t.Errorf("fixes = %+v, want a %s fix", result.Fixes, tt.kind)
+			}
+		})
+	}
+}
+
+func TestRepairJSONReportsFixPositions(t *testing.T) {
+	result, err := repairJSON("{\n  a: 'b',\n}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []RepairFix{
+		{Kind: "unquoted-key", Line: 2, Column: 3},
+		{Kind: "single-quotes", Line: 2, Column: 6},
+		{Kind: "trailing-comma", Line: 2, Column: 9},
+	}
+	if len(result.Fixes) != len(want) {
+		t.Fatalf("fixes = %+v", result.Fixes)
+	}
+	for i, fix := range result.Fixes {
+		if fix.Kind != want[i].Kind || fix.Line != want[i].Line || fix.Column != want[i].Column {
+			t.Errorf("fix %d = %+v, want %s at %d:%d", i, fix, want[i].Kind, want[i].Line, want[i].Column)
+		}
+	}
+}
+
+func TestRepairJSONLeavesValidInputUnchanged(t *testing.T) {
+	result, err := repairJSON(`{"a": [1, "é"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Changed || len(result.Fixes) != 0 {
+		t.Errorf("fixes = %+v, want none", result.Fixes)
+	}
+}
+
+func TestRepairJSONErrors(t *testing.T) {
+	for _, input := range []string{"", "   ", "// only a comment", "]}"} {
+		if _, err := repairJSON(input); err == nil {
+			t.Errorf("repairJSON(%q) succeeded, want an error", input)
+		}
+	}
+}
+
+// Validation probes for a repair; a repair whose output is still invalid
+// must not send validation back into the repairer
+func TestValidateInvalidUnicodeEscapeOffersRepair(t *testing.T) {
+	for _, input := range []string{`"\uZZZZ"`, `{"a": "\u12"}`, `["\`} {
+		result := validateAndFormatJSON(input, ValidateOptions{})
+		if result.IsValid {
+			t.Errorf("%s: expected invalid", input)
+		}
+		if !result.RepairAvailable {
+			t.Errorf("%s: expected a repair to be available", input)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// tokenKind identifies the lexical class of a JSON token
+type tokenKind int
+
+const (
+	tokenBeginObject tokenKind = iota
+	tokenEndObject
+	tokenBeginArray
+	tokenEndArray
+	tokenColon
+	tokenComma
+	tokenString
+	tokenNumber
+	tokenLiteral
+	tokenComment
+	tokenIdentifier
+)
+
+// token is a single lexical element with its exact source text. Comments
+// holds any comments that appeared directly before the token in lenient
+// sources.
+type token struct {
+	Kind     tokenKind
+	Text     string
+	Offset   int
+	Comments []token
+	SameLine bool
+}
+
+// tokenSource yields JSON tokens one at a time
+type tokenSource interface {
+	next() (token, error)
+	peek() (token, error)
+}
+
+// scanError is a lexical error at a byte offset in the source
+type scanError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *scanError) Error() string {
+	return fmt.Sprintf("%s at offset %d", e.Msg, e.Offset)
+}
+
+// tokenizer splits JSON source into tokens without building any values, so
+// the original spelling of strings and numbers is kept intact
+type tokenizer struct {
+	src    string
+	pos    int
+	peeked *token
+	// err is the first scan error. It is returned for every later token so
+	// an error seen by peek is not skipped when the caller moves on.
+	err error
+}
+
+// newTokenizer creates a tokenizer over src
+func newTokenizer(src string) *tokenizer {
+	return &tokenizer{src: src}
+}
+
+// peek returns the next token without consuming it
+func (t *tokenizer) peek() (token, error) {
+	if t.peeked != nil {
+		return *t.peeked, nil
+	}
+	tok, err := t.scan()
+	if err != nil {
+		return tok, err
+	}
+	t.peeked = &tok
+	return tok, nil
+}
+
+// next consumes and returns the next token. It returns io.EOF once the
+// source is exhausted.
+func (t *tokenizer) next() (token, error) {
+	if t.peeked != nil {
+		tok := *t.peeked
+		t.peeked = nil
+		return tok, nil
+	}
+	return t.scan()
+}
+
+// scan reads one token from the current position
+func (t *tokenizer) scan() (token, error) {
+	if t.err != nil {
+		return token{Offset: t.pos}, t.err
+	}
+	tok, err := t.scanToken()
+	if err != nil && err != io.EOF {
+		t.err = err
+	}
+	return tok, err
+}
+
+// scanToken reads one token, leaving pos after it
+func (t *tokenizer) scanToken() (token, error) {
+	t.skipWhitespace()
+	if t.pos >= len(t.src) {
+		return token{Offset: t.pos}, io.EOF
+	}
+
+	start := t.pos
+	single := func(kind tokenKind) (token, error) {
+		t.pos++
+		return token{Kind: kind, Text: t.src[start:t.pos], Offset: start}, nil
+	}
+
+	switch c := t.src[t.pos]; {
+	case c == '{':
+		return single(tokenBeginObject)
+	case c == '}':
+		return single(tokenEndObject)
+	case c == '[':
+		return single(tokenBeginArray)
+	case c == ']':
+		return single(tokenEndArray)
+	case c == ':':
+		return single(tokenColon)
+	case c == ',':
+		return single(tokenComma)
+	case c == '"':
+		if err := t.scanString(); err != nil {
+			return token{Offset: start}, err
+		}
+		return token{Kind: tokenString, Text: t.src[start:t.pos], Offset: start}, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		if err := t.scanNumber(); err != nil {
+			return token{Offset: start}, err
+		}
+		return token{Kind: tokenNumber, Text: t.src[start:t.pos], Offset: start}, nil
+	case c >= 'a' && c <= 'z':
+		for t.pos < len(t.src) && t.src[t.pos] >= 'a' && t.src[t.pos] <= 'z' {
+			t.pos++
+		}
+		word := t.src[start:t.pos]
+		if word != "true" && word != "false" && word != "null" {
+			return token{Offset: start}, &scanError{Offset: start, Msg: fmt.Sprintf("invalid literal %q", word)}
+		}
+		return token{Kind: tokenLiteral, Text: word, Offset: start}, nil
+	default:
+		return token{Offset: start}, &scanError{Offset: start, Msg: fmt.Sprintf("invalid character %q", c)}
+	}
+}
+
+// skipWhitespace advances past insignificant whitespace
+func (t *tokenizer) skipWhitespace() {
+	for t.pos < len(t.src) {
+		switch t.src[t.pos] {
+		case ' ', '\t', '\n', '\r':
+			t.pos++
+		default:
+			return
+		}
+	}
+}
+
+// scanString advances past a double-quoted string, checking escapes but
+// leaving them untouched
+func (t *tokenizer) scanString() error {
+	start := t.pos
+	t.pos++
+	for t.pos < len(t.src) {
+		switch c := t.src[t.pos]; {
+		case c == '"':
+			t.pos++
+			return nil
+		case c == '\\':
+			if err := t.scanEscape(); err != nil {
+				return err
+			}
+		case c < 0x20:
+			return &scanError{Offset: t.pos, Msg: "invalid control character in string"}
+		default:
+			t.pos++
+		}
+	}
+	return &scanError{Offset: start, Msg: "unterminated string"}
+}
+
+// scanEscape advances past one escape sequence, rejecting anything JSON
+// does not allow
+func (t *tokenizer) scanEscape() error {
+	start := t.pos
+	t.pos++
+	if t.pos >= len(t.src) {
+		return nil
+	}
+
+	switch t.src[t.pos] {
+	case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+		t.pos++
+		return nil
+	case 'u':
+		if t.pos+5 > len(t.src) || !isHex(t.src[t.pos+1:t.pos+5]) {
+			return &scanError{Offset: start, Msg: "invalid unicode escape"}
+		}
+		t.pos += 5
+		return nil
+	default:
+		return &scanError{Offset: start, Msg: "invalid escape character"}
+	}
+}
+
+// scanNumber advances past a number literal following the JSON grammar
+func (t *tokenizer) scanNumber() error {
+	start := t.pos
+	digits := func() int {
+		n := 0
+		for t.pos < len(t.src) && t.src[t.pos] >= '0' && t.src[t.pos] <= '9' {
+			t.pos++
+			n++
+		}
+		return n
+	}
+
+	if t.src[t.pos] == '-' {
+		t.pos++
+	}
+	if t.pos < len(t.src) && t.src[t.pos] == '0' {
+		t.pos++
+	} else if digits() == 0 {
+		return &scanError{Offset: start, Msg: "invalid number"}
+	}
+
+	if t.pos < len(t.src) && t.src[t.pos] == '.' {
+		t.pos++
+		if digits() == 0 {
+			return &scanError{Offset: start, Msg: "invalid number: missing digits after decimal point"}
+		}
+	}
+
+	if t.pos < len(t.src) && (t.src[t.pos] == 'e' || t.src[t.pos] == 'E') {
+		t.pos++
+		if t.pos < len(t.src) && (t.src[t.pos] == '+' || t.src[t.pos] == '-') {
+			t.pos++
+		}
+		if digits() == 0 {
+			return &scanError{Offset: start, Msg: "invalid number: missing exponent digits"}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,852 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Supported JSON Schema dialects
+const (
+	SchemaDraft07   = "draft-07"
+	SchemaDraft2020 = "2020-12"
+)
+
+// maxRefDepth bounds the $ref expansions applied to one instance value, so
+// recursive schemas cannot loop forever. Moving on to an array item or
+// object member starts the count again, since the document itself is
+// finite.
+const maxRefDepth = 64
+
+// SchemaViolation describes a single place where a document breaks its schema
+type SchemaViolation struct {
+	Path       string `json:"path"`
+	SchemaPath string `json:"schemaPath"`
+	Keyword    string `json:"keyword"`
+	Message    string `json:"message"`
+}
+
+// SchemaValidationResult is the outcome of validating a document against a schema
+type SchemaValidationResult struct {
+	IsValid    bool              `json:"isValid"`
+	Draft      string            `json:"draft"`
+	Violations []SchemaViolation `json:"violations"`
+}
+
+// schemaValidator walks a document and a schema side by side
+type schemaValidator struct {
+	root       interface{}
+	draft      string
+	anchors    map[string]interface{}
+	patterns   map[string]*regexp.Regexp
+	violations []SchemaViolation
+}
+
+// validateAgainstSchema checks a decoded document against a decoded schema.
+// The draft is taken from the schema's $schema keyword unless one is forced.
+func validateAgainstSchema(doc, schema interface{}, draft string) (SchemaValidationResult, error) {
+	if draft == "" {
+		draft = detectSchemaDraft(schema)
+	}
+	if draft != SchemaDraft07 && draft != SchemaDraft2020 {
+		return SchemaValidationResult{}, fmt.Errorf("unsupported schema draft %q (supported: %s, %s)", draft, SchemaDraft07, SchemaDraft2020)
+	}
+
+	v := &schemaValidator{
+		root:     schema,
+		draft:    draft,
+		anchors:  make(map[string]interface{}),
+		patterns: make(map[string]*regexp.Regexp),
+	}
+	v.indexAnchors(schema)
+
+	if err := v.validate(doc, schema, "", "#", 0); err != nil {
+		return SchemaValidationResult{}, err
+	}
+
+	return SchemaValidationResult{
+		IsValid:    len(v.violations) == 0,
+		Draft:      draft,
+		Violations: append([]SchemaViolation{}, v.violations...),
+	}, nil
+}
+
+// detectSchemaDraft picks a dialect from the $schema URI, defaulting to 2020-12
+func detectSchemaDraft(schema interface{}) string {
+	obj, ok := schema.(map[string]interface{})
+	if !ok {
+		return SchemaDraft2020
+	}
+
+	uri, _ := obj["$schema"].(string)
+	switch {
+	case strings.Contains(uri, "draft-07"), strings.Contains(uri, "draft-06"):
+		return SchemaDraft07
+	default:
+		return SchemaDraft2020
+	}
+}
+
+// indexAnchors records every $anchor (and draft-07 fragment-only $id) so
+// that "#name" references can be resolved
+func (v *schemaValidator) indexAnchors(schema interface{}) {
+	switch node := schema.(type) {
+	case map[string]interface{}:
+		if anchor, ok := node["$anchor"].(string); ok {
+			v.anchors[anchor] = node
+		}
+		if id, ok := node["$id"].(string); ok && strings.HasPrefix(id, "#") {
+			v.anchors[id[1:]] = node
+		}
+		for key, child := range node {
+			if key == "enum" || key == "const" {
+				continue
+			}
+			v.indexAnchors(child)
+		}
+	case []interface{}:
+		for _, child := range node {
+			v.indexAnchors(child)
+		}
+	}
+}
+
+// report records a violation
+func (v *schemaValidator) report(path, schemaPath, keyword, format string, args ...interface{}) {
+	v.violations = append(v.violations, SchemaViolation{
+		Path:       path,
+		SchemaPath: schemaPath + "/" + keyword,
+		Keyword:    keyword,
+		Message:    fmt.Sprintf(format, args...),
+	})
+}
+
+// matches reports whether instance is valid against schema without
+// recording any violations
+func (v *schemaValidator) matches(instance, schema interface{}, path, schemaPath string, depth int) (bool, error) {
+	saved := v.violations
+	v.violations = nil
+	err := v.validate(instance, schema, path, schemaPath, depth)
+	ok := len(v.violations) == 0
+	v.violations = saved
+	return ok, err
+}
+
+// validate applies every keyword of schema to instance. Returned errors are
+// problems with the schema itself; document problems are recorded as violations.
+// depth counts the $ref expansions applied to this instance value.
+func (v *schemaValidator) validate(instance, schema interface{}, path, schemaPath string, depth int) error {
+	if depth > maxRefDepth {
+		return fmt.Errorf("schema recursion too deep at %s", schemaPath)
+	}
+
+	switch s := schema.(type) {
+	case bool:
+		if !s {
+			v.violations = append(v.violations, SchemaViolation{
+				Path:       path,
+				SchemaPath: schemaPath,
+				Keyword:    "false",
+				Message:    "no value is allowed here",
+			})
+		}
+		return nil
+	case map[string]interface{}:
+		return v.validateObjectSchema(instance, s, path, schemaPath, depth)
+	default:
+		return fmt.Errorf("invalid schema at %s: expected object or boolean, got %s", schemaPath, jsonType(schema))
+	}
+}
+
+// validateObjectSchema applies the keywords of an object schema
+func (v *schemaValidator) validateObjectSchema(instance interface{}, s map[string]interface{}, path, schemaPath string, depth int) error {
+	if ref, ok := s["$ref"].(string); ok {
+		target, err := v.resolveRef(ref)
+		if err != nil {
+			return fmt.Errorf("invalid schema at %s/$ref: %w", schemaPath, err)
+		}
+		if err := v.validate(instance, target, path, ref, depth+1); err != nil {
+			return err
+		}
+		// Before 2019-09, $ref replaces all of its sibling keywords
+		if v.draft == SchemaDraft07 {
+			return nil
+		}
+	}
+
+	v.validateGeneric(instance, s, path, schemaPath)
+
+	if err := v.validateCombinators(instance, s, path, schemaPath, depth); err != nil {
+		return err
+	}
+
+	switch value := instance.(type) {
+	case json.Number:
+		v.validateNumber(value, s, path, schemaPath)
+	case string:
+		v.validateString(value, s, path, schemaPath)
+	case []interface{}:
+		return v.validateArray(value, s, path, schemaPath, depth)
+	case map[string]interface{}:
+		return v.validateObject(value, s, path, schemaPath, depth)
+	}
+	return nil
+}
+
+// resolveRef resolves a local $ref to the schema it points at
+func (v *schemaValidator) resolveRef(ref string) (interface{}, error) {
+	fragment := ref
+	if i := strings.IndexByte(ref, '#'); i >= 0 {
+		base := ref[:i]
+		fragment = ref[i:]
+		if base != "" {
+			rootID := ""
+			if obj, ok := v.root.(map[string]interface{}); ok {
+				rootID, _ = obj["$id"].(string)
+			}
+			if strings.TrimSuffix(rootID, "#") != base {
+				return nil, fmt.Errorf("remote reference %q is not supported", ref)
+			}
+		}
+	} else {
+		return nil, fmt.Errorf("remote reference %q is not supported", ref)
+	}
+
+	if fragment == "#" {
+		return v.root, nil
+	}
+	if strings.HasPrefix(fragment, "#/") {
+		pointer, err := url.PathUnescape(fragment[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid reference %q: %w", ref, err)
+		}
+		return resolvePointer(v.root, pointer)
+	}
+	if target, ok := v.anchors[fragment[1:]]; ok {
+		return target, nil
+	}
+	return nil, fmt.Errorf("unresolved reference %q", ref)
+}
+
+// validateGeneric applies type, enum and const
+func (v *schemaValidator) validateGeneric(instance interface{}, s map[string]interface{}, path, schemaPath string) {
+	if typ, ok := s["type"]; ok {
+		var allowed []string
+		switch t := typ.(type) {
+		case string:
+			allowed = []string{t}
+		case []interface{}:
+			for _, item := range t {
+				if name, ok := item.(string); ok {
+					allowed = append(allowed, name)
+				}
+			}
+		}
+
+		actual := jsonType(instance)
+		matched := false
+		for _, name := range allowed {
+			if name == actual || (name == "number" && actual == "integer") {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.report(path, schemaPath, "type", "expected %s, got %s", strings.Join(allowed, " or "), actual)
+		}
+	}
+
+	if enum, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, candidate := range enum {
+			if jsonEqual(instance, candidate) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.report(path, schemaPath, "enum", "value must be one of %s", compactJSON(enum))
+		}
+	}
+
+	if constant, ok := s["const"]; ok && !jsonEqual(instance, constant) {
+		v.report(path, schemaPath, "const", "value must be %s", compactJSON(constant))
+	}
+}
+
+// validateCombinators applies allOf, anyOf, oneOf, not and if/then/else
+func (v *schemaValidator) validateCombinators(instance interface{}, s map[string]interface{}, path, schemaPath string, depth int) error {
+	if allOf, ok := s["allOf"].([]interface{}); ok {
+		for i, sub := range allOf {
+			if err := v.validate(instance, sub, path, fmt.Sprintf("%s/allOf/%d", schemaPath, i), depth); err != nil {
+				return err
+			}
+		}
+	}
+
+	if anyOf, ok := s["anyOf"].([]interface{}); ok {
+		matched := false
+		for i, sub := range anyOf {
+			ok, err := v.matches(instance, sub, path, fmt.Sprintf("%s/anyOf/%d", schemaPath, i), depth)
+			if err != nil {
+				return err
+			}
+			if ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.report(path, schemaPath, "anyOf", "value does not match any of the %d allowed schemas", len(anyOf))
+		}
+	}
+
+	if oneOf, ok := s["oneOf"].([]interface{}); ok {
+		var matchedIndexes []string
+		for i, sub := range oneOf {
+			ok, err := v.matches(instance, sub, path, fmt.Sprintf("%s/oneOf/%d", schemaPath, i), depth)
+			if err != nil {
+				return err
+			}
+			if ok {
+				matchedIndexes = append(matchedIndexes, fmt.Sprint(i))
+			}
+		}
+		switch len(matchedIndexes) {
+		case 1:
+		case 0:
+			v.report(path, schemaPath, "oneOf", "value does not match any of the %d schemas", len(oneOf))
+		default:
+			v.report(path, schemaPath, "oneOf", "value matches more than one schema (indexes %s)", strings.Join(matchedIndexes, ", "))
+		}
+	}
+
+	if not, ok := s["not"]; ok {
+		matched, err := v.matches(instance, not, path, schemaPath+"/not", depth)
+		if err != nil {
+			return err
+		}
+		if matched {
+			v.report(path, schemaPath, "not", "value must not match the schema")
+		}
+	}
+
+	if cond, ok := s["if"]; ok {
+		matched, err := v.matches(instance, cond, path, schemaPath+"/if", depth)
+		if err != nil {
+			return err
+		}
+		if then, ok := s["then"]; ok && matched {
+			if err := v.validate(instance, then, path, schemaPath+"/then", depth); err != nil {
+				return err
+			}
+		}
+		if otherwise, ok := s["else"]; ok && !matched {
+			if err := v.validate(instance, otherwise, path, schemaPath+"/else", depth); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateNumber applies the numeric keywords using exact arithmetic
+func (v *schemaValidator) validateNumber(n json.Number, s map[string]interface{}, path, schemaPath string) {
+	value, ok := numberRat(n)
+	if !ok {
+		return
+	}
+
+	limit := func(keyword string) (*big.Rat, string, bool) {
+		raw, exists := s[keyword]
+		if !exists {
+			return nil, "", false
+		}
+		r, ok := numberRat(raw)
+		return r, fmt.Sprint(raw), ok
+	}
+
+	if m, text, ok := limit("multipleOf"); ok && m.Sign() > 0 {
+		if !new(big.Rat).Quo(value, m).IsInt() {
+			v.report(path, schemaPath, "multipleOf", "%s is not a multiple of %s", n, text)
+		}
+	}
+	if max, text, ok := limit("maximum"); ok && value.Cmp(max) > 0 {
+		v.report(path, schemaPath, "maximum", "%s is greater than the maximum of %s", n, text)
+	}
+	if max, text, ok := limit("exclusiveMaximum"); ok && value.Cmp(max) >= 0 {
+		v.report(path, schemaPath, "exclusiveMaximum", "%s must be less than %s", n, text)
+	}
+	if min, text, ok := limit("minimum"); ok && value.Cmp(min) < 0 {
+		v.report(path, schemaPath, "minimum", "%s is less than the minimum of %s", n, text)
+	}
+	if min, text, ok := limit("exclusiveMinimum"); ok && value.Cmp(min) <= 0 {
+		v.report(path, schemaPath, "exclusiveMinimum", "%s must be greater than %s", n, text)
+	}
+}
+
+// validateString applies the string keywords
+func (v *schemaValidator) validateString(str string, s map[string]interface{}, path, schemaPath string) {
+	length := utf8.RuneCountInString(str)
+
+	if max, ok := schemaInt(s, "maxLength"); ok && length > max {
+		v.report(path, schemaPath, "maxLength", "string is %d characters, longer than the maximum of %d", length, max)
+	}
+	if min, ok := schemaInt(s, "minLength"); ok && length < min {
+		v.report(path, schemaPath, "minLength", "string is %d characters, shorter than the minimum of %d", length, min)
+	}
+
+	if pattern, ok := s["pattern"].(string); ok {
+		re, err := v.compilePattern(pattern)
+		if err != nil {
+			v.report(path, schemaPath, "pattern", "schema pattern %q is not a supported regular expression: %v", pattern, err)
+		} else if !re.MatchString(str) {
+			v.report(path, schemaPath, "pattern", "string does not match pattern %q", pattern)
+		}
+	}
+
+	if format, ok := s["format"].(string); ok {
+		if msg := checkFormat(format, str); msg != "" {
+			v.report(path, schemaPath, "format", "%s", msg)
+		}
+	}
+}
+
+// compilePattern compiles and caches a schema regular expression
+func (v *schemaValidator) compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := v.patterns[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	v.patterns[pattern] = re
+	return re, nil
+}
+
+// validateArray applies the array keywords
+func (v *schemaValidator) validateArray(items []interface{}, s map[string]interface{}, path, schemaPath string, depth int) error {
+	if max, ok := schemaInt(s, "maxItems"); ok && len(items) > max {
+		v.report(path, schemaPath, "maxItems", "array has %d items, more than the maximum of %d", len(items), max)
+	}
+	if min, ok := schemaInt(s, "minItems"); ok && len(items) < min {
+		v.report(path, schemaPath, "minItems", "array has %d items, fewer than the minimum of %d", len(items), min)
+	}
+
+	if unique, _ := s["uniqueItems"].(bool); unique {
+	outer:
+		for i := range items {
+			for j := i + 1; j < len(items); j++ {
+				if jsonEqual(items[i], items[j]) {
+					v.report(path, schemaPath, "uniqueItems", "items %d and %d are equal", i, j)
+					break outer
+				}
+			}
+		}
+	}
+
+	// Tuple validation moved from "items" to "prefixItems" in 2020-12
+	prefixKeyword, restKeyword := "prefixItems", "items"
+	if v.draft == SchemaDraft07 {
+		prefixKeyword, restKeyword = "items", "additionalItems"
+	}
+
+	prefix, isTuple := s[prefixKeyword].([]interface{})
+	start := 0
+	if isTuple {
+		for i := 0; i < len(prefix) && i < len(items); i++ {
+			if err := v.validate(items[i], prefix[i], appendPointerIndex(path, i), fmt.Sprintf("%s/%s/%d", schemaPath, prefixKeyword, i), 0); err != nil {
+				return err
+			}
+		}
+		start = len(prefix)
+	}
+
+	if v.draft == SchemaDraft07 && !isTuple {
+		// A single draft-07 "items" schema applies to every element
+		restKeyword = "items"
+	}
+	rest, hasRest := s[restKeyword]
+	if allowed, ok := rest.(bool); ok && !allowed {
+		if len(items) > start {
+			v.report(path, schemaPath, restKeyword, "array has %d items, at most %d allowed", len(items), start)
+		}
+	} else if hasRest {
+		if _, isArray := rest.([]interface{}); !isArray {
+			for i := start; i < len(items); i++ {
+				if err := v.validate(items[i], rest, appendPointerIndex(path, i), schemaPath+"/"+restKeyword, 0); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if contains, ok := s["contains"]; ok {
+		count := 0
+		for i, item := range items {
+			matched, err := v.matches(item, contains, appendPointerIndex(path, i), schemaPath+"/contains", 0)
+			if err != nil {
+				return err
+			}
+			if matched {
+				count++
+			}
+		}
+
+		minContains, hasMin := schemaInt(s, "minContains")
+		if !hasMin || v.draft == SchemaDraft07 {
+			minContains = 1
+		}
+		if count < minContains {
+			v.report(path, schemaPath, "contains", "array must contain at least %d matching item(s), found %d", minContains, count)
+		}
+		if maxContains, ok := schemaInt(s, "maxContains"); ok && v.draft != SchemaDraft07 && count > maxContains {
+			v.report(path, schemaPath, "maxContains", "array must contain at most %d matching item(s), found %d", maxContains, count)
+		}
+	}
+	return nil
+}
+
+// validateObject applies the object keywords
+func (v *schemaValidator) validateObject(obj map[string]interface{}, s map[string]interface{}, path, schemaPath string, depth int) error {
+	if max, ok := schemaInt(s, "maxProperties"); ok && len(obj) > max {
+		v.report(path, schemaPath, "maxProperties", "object has %d properties, more than the maximum of %d", len(obj), max)
+	}
+	if min, ok := schemaInt(s, "minProperties"); ok && len(obj) < min {
+		v.report(path, schemaPath, "minProperties", "object has %d properties, fewer than the minimum of %d", len(obj), min)
+	}
+
+	if required, ok := s["required"].([]interface{}); ok {
+		for _, item := range required {
+			name, _ := item.(string)
+			if _, exists := obj[name]; !exists {
+				v.report(path, schemaPath, "required", "missing required property %q", name)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	properties, _ := s["properties"].(map[string]interface{})
+	patternProperties, _ := s["patternProperties"].(map[string]interface{})
+	additional, hasAdditional := s["additionalProperties"]
+
+	for _, key := range keys {
+		value := obj[key]
+		childPath := appendPointer(path, key)
+		evaluated := false
+
+		if sub, ok := properties[key]; ok {
+			evaluated = true
+			if err := v.validate(value, sub, childPath, schemaPath+"/properties/"+pointerEscaper.Replace(key), 0); err != nil {
+				return err
+			}
+		}
+
+		for pattern, sub := range patternProperties {
+			re, err := v.compilePattern(pattern)
+			if err != nil {
+				return fmt.Errorf("invalid schema at %s/patternProperties: %w", schemaPath, err)
+			}
+			if re.MatchString(key) {
+				evaluated = true
+				if err := v.validate(value, sub, childPath, schemaPath+"/patternProperties/"+pointerEscaper.Replace(pattern), 0); err != nil {
+					return err
+				}
+			}
+		}
+
+		if !evaluated && hasAdditional {
+			if allowed, ok := additional.(bool); ok && !allowed {
+				v.report(childPath, schemaPath, "additionalProperties", "property %q is not allowed", key)
+			} else if err := v.validate(value, additional, childPath, schemaPath+"/additionalProperties", 0); err != nil {
+				return err
+			}
+		}
+
+		if names, ok := s["propertyNames"]; ok {
+			matched, err := v.matches(key, names, childPath, schemaPath+"/propertyNames", 0)
+			if err != nil {
+				return err
+			}
+			if !matched {
+				v.report(childPath, schemaPath, "propertyNames", "property name %q is not allowed", key)
+			}
+		}
+	}
+
+	return v.validateDependencies(obj, s, path, schemaPath, depth)
+}
+
+// validateDependencies applies dependentRequired, dependentSchemas and the
+// draft-07 "dependencies" keyword that combined both
+func (v *schemaValidator) validateDependencies(obj map[string]interface{}, s map[string]interface{}, path, schemaPath string, depth int) error {
+	for _, keyword := range []string{"dependencies", "dependentRequired", "dependentSchemas"} {
+		deps, ok := s[keyword].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		triggers := make([]string, 0, len(deps))
+		for trigger := range deps {
+			triggers = append(triggers, trigger)
+		}
+		sort.Strings(triggers)
+
+		for _, trigger := range triggers {
+			if _, present := obj[trigger]; !present {
+				continue
+			}
+
+			if names, isList := deps[trigger].([]interface{}); isList && keyword != "dependentSchemas" {
+				for _, item := range names {
+					name, _ := item.(string)
+					if _, exists := obj[name]; !exists {
+						v.report(path, schemaPath, keyword, "property %q is required when %q is present", name, trigger)
+					}
+				}
+				continue
+			}
+
+			if keyword != "dependentRequired" {
+				subPath := schemaPath + "/" + keyword + "/" + pointerEscaper.Replace(trigger)
+				if err := v.validate(obj, deps[trigger], path, subPath, depth); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// schemaInt reads a non-negative integer keyword
+func schemaInt(s map[string]interface{}, keyword string) (int, bool) {
+	r, ok := numberRat(s[keyword])
+	if !ok || !r.IsInt() || !r.Num().IsInt64() {
+		return 0, false
+	}
+	return int(r.Num().Int64()), true
+}
+
+var (
+	uuidPattern     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hostnamePattern = regexp.MustCompile(`^(?i:[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)(\.(?i:[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?))*$`)
+)
+
+// checkFormat validates the common "format" values. Unknown formats are
+// accepted, as the specification requires.
+func checkFormat(format, value string) string {
+	switch format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
+			return fmt.Sprintf("%q is not a valid RFC 3339 date-time", value)
+		}
+	case "date":
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return fmt.Sprintf("%q is not a valid date", value)
+		}
+	case "time":
+		if _, err := time.Parse("15:04:05Z07:00", value); err != nil {
+			if _, err := time.Parse("15:04:05.999999999Z07:00", value); err != nil {
+				return fmt.Sprintf("%q is not a valid time", value)
+			}
+		}
+	case "email":
+		if addr, err := mail.ParseAddress(value); err != nil || addr.Address != value {
+			return fmt.Sprintf("%q is not a valid email address", value)
+		}
+	case "uri":
+		if u, err := url.Parse(value); err != nil || !u.IsAbs() {
+			return fmt.Sprintf("%q is not a valid absolute URI", value)
+		}
+	case "uri-reference":
+		if _, err := url.Parse(value); err != nil {
+			return fmt.Sprintf("%q is not a valid URI reference", value)
+		}
+	case "uuid":
+		if !uuidPattern.MatchString(value) {
+			return fmt.Sprintf("%q is not a valid UUID", value)
+		}
+	case "ipv4":
+		if ip := net.ParseIP(value); ip == nil || ip.To4() == nil || strings.Contains(value, ":") {
+			return fmt.Sprintf("%q is not a valid IPv4 address", value)
+		}
+	case "ipv6":
+		if ip := net.ParseIP(value); ip == nil || !strings.Contains(value, ":") {
+			return fmt.Sprintf("%q is not a valid IPv6 address", value)
+		}
+	case "hostname":
+		if len(value) > 253 || !hostnamePattern.MatchString(value) {
+			return fmt.Sprintf("%q is not a valid hostname", value)
+		}
+	case "regex":
+		if _, err := regexp.Compile(value); err != nil {
+			return fmt.Sprintf("%q is not a valid regular expression", value)
+		}
+	}
+	return ""
+}
+
+// compactJSON renders a value for use in messages
+func compactJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// schemaStorageKey is the plugin data key holding saved schemas by name
+const schemaStorageKey = "json_schemas"
+
+// schemasMu serializes read-modify-write cycles on the saved schemas
+var schemasMu sync.Mutex
+
+// SchemaValidationOptions are the options of the validateSchema operation.
+// Schema may be given inline, either as a JSON value or as JSON text, or
+// SchemaName may reference a schema saved with the saveSchema operation.
+type SchemaValidationOptions struct {
+	Schema     json.RawMessage `json:"schema"`
+	SchemaName string          `json:"schemaName"`
+	Draft      string          `json:"draft"`
+}
+
+// SaveSchemaOptions are the options of the saveSchema operation
+type SaveSchemaOptions struct {
+	Name string `json:"name"`
+}
+
+// runValidateSchema validates the input document against a schema
+func runValidateSchema(req OperationRequest) (interface{}, error) {
+	var opts SchemaValidationOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+
+	schemaText, err := opts.schemaText()
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := decodeJSON([]byte(schemaText))
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema JSON: %w", err)
+	}
+
+	doc, err := decodeJSON([]byte(req.Input))
+	if err != nil {
+		return nil, fmt.Errorf("invalid input JSON: %w", err)
+	}
+
+	return validateAgainstSchema(doc, schema, opts.Draft)
+}
+
+// schemaText returns the schema source named by the options
+func (o SchemaValidationOptions) schemaText() (string, error) {
+	raw := strings.TrimSpace(string(o.Schema))
+	switch {
+	case raw != "" && raw != "null":
+		var text string
+		if strings.HasPrefix(raw, `"`) {
+			if err := json.Unmarshal(o.Schema, &text); err != nil {
+				return "", fmt.Errorf("invalid schema option: %w", err)
+			}
+			return text, nil
+		}
+		return raw, nil
+	case o.SchemaName != "":
+		schemas, err := loadStoredSchemas()
+		if err != nil {
+			return "", err
+		}
+		text, ok := schemas[o.SchemaName]
+		if !ok {
+			return "", fmt.Errorf("no saved schema named %q", o.SchemaName)
+		}
+		return text, nil
+	default:
+		return "", fmt.Errorf("either schema or schemaName must be provided")
+	}
+}
+
+// runSaveSchema stores the input as a named schema in plugin storage
+func runSaveSchema(req OperationRequest) (interface{}, error) {
+	var opts SaveSchemaOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+	if opts.Name == "" {
+		return nil, fmt.Errorf("schema name is required")
+	}
+
+	schema, err := decodeJSON([]byte(req.Input))
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema JSON: %w", err)
+	}
+	if t := jsonType(schema); t != "object" && t != "boolean" {
+		return nil, fmt.Errorf("a schema must be an object or boolean, got %s", t)
+	}
+
+	if err := storeSchema(opts.Name, req.Input); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"name": opts.Name, "draft": detectSchemaDraft(schema)}, nil
+}
+
+// loadStoredSchemas reads the saved schema sources from plugin storage
+func loadStoredSchemas() (map[string]string, error) {
+	if plugin == nil {
+		return nil, fmt.Errorf("plugin storage not available")
+	}
+
+	stored, err := plugin.LoadData(schemaStorageKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load saved schemas: %w", err)
+	}
+
+	schemas := make(map[string]string)
+	if stored != nil && stored.Value != nil {
+		values, ok := stored.Value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid saved schema format")
+		}
+		for name, value := range values {
+			if text, ok := value.(string); ok {
+				schemas[name] = text
+			}
+		}
+	}
+	return schemas, nil
+}
+
+// storeSchema saves a schema source under name, replacing any schema
+// already saved with that name
+func storeSchema(name, text string) error {
+	schemasMu.Lock()
+	defer schemasMu.Unlock()
+
+	schemas, err := loadStoredSchemas()
+	if err != nil {
+		return err
+	}
+	schemas[name] = text
+
+	if err := plugin.StoreData(schemaStorageKey, schemas, "1.0.0"); err != nil {
+		return fmt.Errorf("failed to save schema: %w", err)
+	}
+	return nil
+}
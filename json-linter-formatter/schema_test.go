@@ -0,0 +1,206 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateAgainstSchema(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   string
+		doc      string
+		draft    string
+		keywords []string
+		paths    []string
+	}{
+		{
+			name:   "valid object",
+			schema: `{"type": "object", "required": ["id"], "properties": {"id": {"type": "integer"}}}`,
+			doc:    `{"id": 7}`,
+			draft:  SchemaDraft2020,
+		},
+		{
+			name:   "integer accepts integral values written as numbers",
+			schema: `{"type": "array", "items": {"type": "integer"}}`,
+			doc:    `[1, 1.0, 1e3]`,
+			draft:  SchemaDraft2020,
+		},
+		{
+			name:     "integer rejects fractions",
+			schema:   `{"type": "array", "items": {"type": "integer"}}`,
+			doc:      `[1, 1.5]`,
+			draft:    SchemaDraft2020,
+			keywords: []string{"type"},
+			paths:    []string{"/1"},
+		},
+		{
+			name:     "missing required property",
+			schema:   `{"type": "object", "required": ["id", "name"]}`,
+			doc:      `{"id": 1}`,
+			draft:    SchemaDraft2020,
+			keywords: []string{"required"},
+			paths:    []string{""},
+		},
+		{
+			name:     "nested type mismatch",
+			schema:   `{"properties": {"items": {"type": "array", "items": {"type": "string"}}}}`,
+			doc:      `{"items": ["a", 2]}`,
+			draft:    SchemaDraft2020,
+			keywords: []string{"type"},
+			paths:    []string{"/items/1"},
+		},
+		{
+			name:     "draft-07 detected from $schema",
+			schema:   `{"$schema": "http://json-schema.org/draft-07/schema#", "maximum": 3}`,
+			doc:      `4`,
+			draft:    SchemaDraft07,
+			keywords: []string{"maximum"},
+			paths:    []string{""},
+		},
+		{
+			name:   "draft-07 $ref ignores siblings",
+			schema: `{"$schema": "http://json-schema.org/draft-07/schema#", "definitions": {"s": {"type": "string"}}, "properties": {"a": {"$ref": "#/definitions/s", "minLength": 5}}}`,
+			doc:    `{"a": "hi"}`,
+			draft:  SchemaDraft07,
+		},
+		{
+			name:     "2020-12 $ref keeps siblings",
+			schema:   `{"$defs": {"s": {"type": "string"}}, "properties": {"a": {"$ref": "#/$defs/s", "minLength": 5}}}`,
+			doc:      `{"a": "hi"}`,
+			draft:    SchemaDraft2020,
+			keywords: []string{"minLength"},
+			paths:    []string{"/a"},
+		},
+		{
+			name:     "prefixItems",
+			schema:   `{"prefixItems": [{"type": "number"}, {"type": "string"}], "items": false}`,
+			doc:      `[1, "a", true]`,
+			draft:    SchemaDraft2020,
+			keywords: []string{"items"},
+			paths:    []string{""},
+		},
+		{
+			name:     "oneOf matching twice",
+			schema:   `{"oneOf": [{"type": "number"}, {"type": "integer"}]}`,
+			doc:      `3`,
+			draft:    SchemaDraft2020,
+			keywords: []string{"oneOf"},
+			paths:    []string{""},
+		},
+		{
+			name:     "format",
+			schema:   `{"format": "email"}`,
+			doc:      `"not an email"`,
+			draft:    SchemaDraft2020,
+			keywords: []string{"format"},
+			paths:    []string{""},
+		},
+		{
+			name:     "large integers compare exactly",
+			schema:   `{"const": 12345678901234567890}`,
+			doc:      `12345678901234567891`,
+			draft:    SchemaDraft2020,
+			keywords: []string{"const"},
+			paths:    []string{""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := decodeJSON([]byte(tt.schema))
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc, err := decodeJSON([]byte(tt.doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := validateAgainstSchema(doc, schema, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Draft != tt.draft {
+				t.Errorf("draft = %q, want %q", result.Draft, tt.draft)
+			}
+
+			var keywords, paths []string
+			for _, v := range result.Violations {
+				keywords = append(keywords, v.Keyword)
+				paths = append(paths, v.Path)
+			}
+			if !reflect.DeepEqual(keywords, tt.keywords) || !reflect.DeepEqual(paths, tt.paths) {
+				t.Errorf("violations = %+v, want keywords %v at %v", result.Violations, tt.keywords, tt.paths)
+			}
+			if result.IsValid != (len(tt.keywords) == 0) {
+				t.Errorf("isValid = %v", result.IsValid)
+			}
+		})
+	}
+}
+
+func TestValidateAgainstSchemaErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		draft  string
+		want   string
+	}{
+		{"unsupported draft", `{}`, "draft-04", "unsupported schema draft"},
+		{"remote reference", `{"$ref": "https://example.com/schema.json"}`, "", "remote reference"},
+		{"unresolved anchor", `{"$ref": "#missing"}`, "", "unresolved reference"},
+		{"recursive reference", `{"$ref": "#"}`, "", "recursion too deep"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := decodeJSON([]byte(tt.schema))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = validateAgainstSchema(map[string]interface{}{}, schema, tt.draft)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateAgainstSchemaDeepRecursiveDocument(t *testing.T) {
+	schema, err := decodeJSON([]byte(`{"type": "object", "properties": {"child": {"$ref": "#"}, "items": {"type": "array", "items": {"$ref": "#"}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		doc   string
+		paths []string
+	}{
+		{"nested objects", strings.Repeat(`{"child": `, 3*maxRefDepth) + `{}` + strings.Repeat(`}`, 3*maxRefDepth), nil},
+		{"nested arrays", strings.Repeat(`{"items": [`, maxRefDepth+5) + `{}` + strings.Repeat(`]}`, maxRefDepth+5), nil},
+		{"violation deep inside", strings.Repeat(`{"child": `, 2*maxRefDepth) + `1` + strings.Repeat(`}`, 2*maxRefDepth), []string{strings.Repeat("/child", 2*maxRefDepth)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := decodeJSON([]byte(tt.doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := validateAgainstSchema(doc, schema, SchemaDraft2020)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var paths []string
+			for _, v := range result.Violations {
+				paths = append(paths, v.Path)
+			}
+			if !reflect.DeepEqual(paths, tt.paths) {
+				t.Errorf("violations at %v, want %v", paths, tt.paths)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// settingsConfigKey is the plugin config key holding the linter settings
+const settingsConfigKey = "linter_settings"
+
+const (
+	defaultTabSize   = 2
+	defaultLineWidth = 80
+	maxTabSize       = 16
+	minLineWidth     = 20
+	maxLineWidth     = 1000
+)
+
+// FormatSettings are the stored formatting preferences. They live in the
+// linter_settings config alongside settings owned by the frontend, which
+// are preserved when these are saved.
+type FormatSettings struct {
+	TabSize       int  `json:"tabSize"`
+	UseTabs       bool `json:"useTabs"`
+	Minify        bool `json:"minify"`
+	CompactArrays bool `json:"compactArrays"`
+	LineWidth     int  `json:"lineWidth"`
+	EscapeHTML    bool `json:"escapeHTML"`
+}
+
+// defaultFormatSettings returns the settings used before any are stored
+func defaultFormatSettings() FormatSettings {
+	return FormatSettings{TabSize: defaultTabSize, LineWidth: defaultLineWidth}
+}
+
+// check rejects settings the formatter cannot honor
+func (s FormatSettings) check() error {
+	if s.TabSize < 1 || s.TabSize > maxTabSize {
+		return fmt.Errorf("tabSize must be between 1 and %d, got %d", maxTabSize, s.TabSize)
+	}
+	if s.LineWidth < minLineWidth || s.LineWidth > maxLineWidth {
+		return fmt.Errorf("lineWidth must be between %d and %d, got %d", minLineWidth, maxLineWidth, s.LineWidth)
+	}
+	return nil
+}
+
+// style converts the settings to formatter options
+func (s FormatSettings) style() formatStyle {
+	indent := strings.Repeat(" ", s.TabSize)
+	if s.UseTabs {
+		indent = "\t"
+	}
+	return formatStyle{
+		Indent:        indent,
+		Minify:        s.Minify,
+		CompactArrays: s.CompactArrays,
+		LineWidth:     s.LineWidth,
+		EscapeHTML:    s.EscapeHTML,
+	}
+}
+
+// settingsRetryDelay is how long the defaults are used after the stored
+// settings failed to load, before storage is tried again
+const settingsRetryDelay = 30 * time.Second
+
+var (
+	settingsMu     sync.Mutex
+	cachedSettings *FormatSettings
+	// settingsRetryAt is set while cachedSettings holds the defaults
+	// because storage failed
+	settingsRetryAt time.Time
+)
+
+// currentFormatSettings returns the stored settings, loading them from
+// plugin storage the first time they are needed. When storage fails the
+// defaults are used until settingsRetryDelay has passed, so a broken store
+// does not slow down every request.
+func currentFormatSettings() FormatSettings {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+
+	if cachedSettings != nil && (settingsRetryAt.IsZero() || time.Now().Before(settingsRetryAt)) {
+		return *cachedSettings
+	}
+
+	settings := defaultFormatSettings()
+	stored, err := loadStoredSettings()
+	if err != nil {
+		log.Printf("Using default format settings: %v", err)
+		cachedSettings = &settings
+		settingsRetryAt = time.Now().Add(settingsRetryDelay)
+		return settings
+	}
+	if err := decodeSettings(stored, &settings); err != nil || settings.check() != nil {
+		log.Printf("Ignoring invalid stored format settings: %v", stored)
+		settings = defaultFormatSettings()
+	}
+
+	cachedSettings = &settings
+	settingsRetryAt = time.Time{}
+	return settings
+}
+
+// loadStoredSettings reads the raw linter_settings config. Missing settings
+// yield an empty map.
+func loadStoredSettings() (map[string]interface{}, error) {
+	if plugin == nil {
+		return nil, fmt.Errorf("plugin storage not available")
+	}
+
+	stored, err := plugin.LoadConfig(settingsConfigKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load settings: %w", err)
+	}
+
+	values := make(map[string]interface{})
+	if stored != nil && stored.Value != nil {
+		existing, ok := stored.Value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid stored settings format")
+		}
+		for key, value := range existing {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// decodeSettings overlays the known keys of a settings map onto settings
+func decodeSettings(values map[string]interface{}, settings *FormatSettings) error {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, settings)
+}
+
+// runGetSettings returns the formatting settings currently in effect
+func runGetSettings(req OperationRequest) (interface{}, error) {
+	return currentFormatSettings(), nil
+}
+
+// runSaveSettings updates the formatting settings. Options hold only the
+// settings to change; everything else keeps its stored value.
+func runSaveSettings(req OperationRequest) (interface{}, error) {
+	var changes map[string]interface{}
+	if err := decodeOptions(req, &changes); err != nil {
+		return nil, err
+	}
+
+	settings := currentFormatSettings()
+	if err := decodeOptions(req, &settings); err != nil {
+		return nil, err
+	}
+	if err := settings.check(); err != nil {
+		return nil, err
+	}
+
+	var known map[string]interface{}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &known); err != nil {
+		return nil, err
+	}
+
+	stored, err := loadStoredSettings()
+	if err != nil {
+		return nil, err
+	}
+	for key := range changes {
+		value, ok := known[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown setting %q", key)
+		}
+		stored[key] = value
+	}
+
+	if err := plugin.StoreConfig(settingsConfigKey, stored, "1.0.0"); err != nil {
+		return nil, fmt.Errorf("failed to save settings: %w", err)
+	}
+
+	settingsMu.Lock()
+	cachedSettings = &settings
+	settingsRetryAt = time.Time{}
+	settingsMu.Unlock()
+
+	return settings, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatSettingsCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings FormatSettings
+		wantErr  bool
+	}{
+		{"defaults", defaultFormatSettings(), false},
+		{"tab size too small", FormatSettings{TabSize: 0, LineWidth: 80}, true},
+		{"tab size too large", FormatSettings{TabSize: maxTabSize + 1, LineWidth: 80}, true},
+		{"line width too small", FormatSettings{TabSize: 2, LineWidth: minLineWidth - 1}, true},
+		{"line width too large", FormatSettings{TabSize: 2, LineWidth: maxLineWidth + 1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.settings.check(); (err != nil) != tt.wantErr {
+				t.Errorf("check() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFormatSettingsStyle(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings FormatSettings
+		want     formatStyle
+	}{
+		{"spaces", FormatSettings{TabSize: 4, LineWidth: 100}, formatStyle{Indent: "    ", LineWidth: 100}},
+		{"tabs", FormatSettings{TabSize: 4, UseTabs: true, LineWidth: 80}, formatStyle{Indent: "\t", LineWidth: 80}},
+		{
+			name:     "flags",
+			settings: FormatSettings{TabSize: 2, Minify: true, CompactArrays: true, EscapeHTML: true, LineWidth: 80},
+			want:     formatStyle{Indent: "  ", Minify: true, CompactArrays: true, EscapeHTML: true, LineWidth: 80},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.settings.style(); got != tt.want {
+				t.Errorf("style() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeSettingsKeepsUnsetValues(t *testing.T) {
+	settings := defaultFormatSettings()
+	stored := map[string]interface{}{"useTabs": true, "theme": "dark"}
+	if err := decodeSettings(stored, &settings); err != nil {
+		t.Fatal(err)
+	}
+	want := defaultFormatSettings()
+	want.UseTabs = true
+	if settings != want {
+		t.Errorf("settings = %+v, want %+v", settings, want)
+	}
+}
+
+func TestCurrentFormatSettingsBacksOffWhenStorageFails(t *testing.T) {
+	settingsMu.Lock()
+	cachedSettings, settingsRetryAt = nil, time.Time{}
+	settingsMu.Unlock()
+
+	if got := currentFormatSettings(); got != defaultFormatSettings() {
+		t.Errorf("settings = %+v, want defaults", got)
+	}
+
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	if cachedSettings == nil {
+		t.Fatal("expected the defaults to be cached after a storage failure")
+	}
+	if wait := time.Until(settingsRetryAt); wait <= 0 || wait > settingsRetryDelay {
+		t.Errorf("retry in %v, want within %v", wait, settingsRetryDelay)
+	}
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// snippetsStorageKey is the plugin data key holding saved JSON snippets
+const snippetsStorageKey = "json_snippets"
+
+// maxSnippetNameLength bounds snippet names
+const maxSnippetNameLength = 200
+
+// snippetsMu serializes read-modify-write cycles on the snippet library
+var snippetsMu sync.Mutex
+
+// Snippet is a named JSON document saved in the library
+type Snippet struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	JSON        string    `json:"json"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// snippetLibrary is the stored form of the library
+type snippetLibrary struct {
+	Snippets []Snippet `json:"snippets"`
+	Version  string    `json:"version"`
+}
+
+// SnippetOptions are the options of the snippet operations. The content
+// of createSnippet and updateSnippet is the request input; updateSnippet
+// keeps the current content when the input is empty, and leaves
+// Description and Tags alone when they are omitted.
+type SnippetOptions struct {
+	Name        string    `json:"name"`
+	NewName     string    `json:"newName"`
+	Description *string   `json:"description"`
+	Tags        *[]string `json:"tags"`
+}
+
+// SnippetListOptions are the options of the listSnippets operation. Query
+// searches names, descriptions, tags and content; every word must match.
+type SnippetListOptions struct {
+	Query string `json:"query"`
+	Tag   string `json:"tag"`
+	Limit int    `json:"limit"`
+}
+
+// SnippetSummary describes a snippet without its content. Excerpt shows
+// the first content line matching the query.
+type SnippetSummary struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Size        int       `json:"size"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	Excerpt     string    `json:"excerpt,omitempty"`
+}
+
+// SnippetListResult is the result of the listSnippets operation
+type SnippetListResult struct {
+	Total    int              `json:"total"`
+	Snippets []SnippetSummary `json:"snippets"`
+}
+
+// loadSnippets reads the snippet library from plugin storage
+func loadSnippets() ([]Snippet, error) {
+	if plugin == nil {
+		return nil, fmt.Errorf("plugin storage not available")
+	}
+
+	stored, err := plugin.LoadData(snippetsStorageKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load snippets: %w", err)
+	}
+	if stored == nil || stored.Value == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(stored.Value)
+	if err != nil {
+		return nil, err
+	}
+	var library snippetLibrary
+	if err := json.Unmarshal(data, &library); err != nil {
+		return nil, fmt.Errorf("invalid saved snippet format: %w", err)
+	}
+	return library.Snippets, nil
+}
+
+// storeSnippets writes the snippet library to plugin storage
+func storeSnippets(snippets []Snippet) error {
+	library := snippetLibrary{Snippets: snippets, Version: "1.0.0"}
+	if err := plugin.StoreData(snippetsStorageKey, library, "1.0.0"); err != nil {
+		return fmt.Errorf("failed to save snippets: %w", err)
+	}
+	return nil
+}
+
+// findSnippet returns the index of the named snippet, or -1
+func findSnippet(snippets []Snippet, name string) int {
+	for i, s := range snippets {
+		if s.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// loadSnippetJSON returns the JSON text of the stored snippet with the
+// given name
+func loadSnippetJSON(name string) (string, error) {
+	snippets, err := loadSnippets()
+	if err != nil {
+		return "", err
+	}
+	i := findSnippet(snippets, name)
+	if i < 0 {
+		return "", fmt.Errorf("no snippet named %q", name)
+	}
+	return snippets[i].JSON, nil
+}
+
+// checkSnippetName validates a snippet name and returns it trimmed
+func checkSnippetName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("snippet name is required")
+	}
+	if len(name) > maxSnippetNameLength {
+		return "", fmt.Errorf("snippet name must be at most %d bytes", maxSnippetNameLength)
+	}
+	return name, nil
+}
+
+// checkSnippetJSON ensures snippet content is a valid JSON document
+func checkSnippetJSON(input string) error {
+	if _, err := parseTree(input); err != nil {
+		return fmt.Errorf("invalid snippet JSON: %w", err)
+	}
+	return nil
+}
+
+// normalizeTags trims, de-duplicates and sorts tags
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !seen[strings.ToLower(tag)] {
+			seen[strings.ToLower(tag)] = true
+			result = append(result, tag)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// runCreateSnippet saves the input as a new snippet
+func runCreateSnippet(req OperationRequest) (interface{}, error) {
+	var opts SnippetOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+	name, err := checkSnippetName(opts.Name)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSnippetJSON(req.Input); err != nil {
+		return nil, err
+	}
+
+	snippetsMu.Lock()
+	defer snippetsMu.Unlock()
+
+	snippets, err := loadSnippets()
+	if err != nil {
+		return nil, err
+	}
+	if findSnippet(snippets, name) >= 0 {
+		return nil, fmt.Errorf("a snippet named %q already exists", name)
+	}
+
+	now := time.Now().UTC()
+	snippet := Snippet{Name: name, JSON: req.Input, CreatedAt: now, UpdatedAt: now}
+	if opts.Description != nil {
+		snippet.Description = strings.TrimSpace(*opts.Description)
+	}
+	if opts.Tags != nil {
+		snippet.Tags = normalizeTags(*opts.Tags)
+	}
+
+	if err := storeSnippets(append(snippets, snippet)); err != nil {
+		return nil, err
+	}
+	return snippet, nil
+}
+
+// runGetSnippet returns a snippet with its content
+func runGetSnippet(req OperationRequest) (interface{}, error) {
+	var opts SnippetOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+
+	snippets, err := loadSnippets()
+	if err != nil {
+		return nil, err
+	}
+	i := findSnippet(snippets, strings.TrimSpace(opts.Name))
+	if i < 0 {
+		return nil, fmt.Errorf("no snippet named %q", opts.Name)
+	}
+	return snippets[i], nil
+}
+
+// runUpdateSnippet changes the content, name, description or tags of a
+// snippet
+func runUpdateSnippet(req OperationRequest) (interface{}, error) {
+	var opts SnippetOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+	if req.Input != "" {
+		if err := checkSnippetJSON(req.Input); err != nil {
+			return nil, err
+		}
+	}
+
+	snippetsMu.Lock()
+	defer snippetsMu.Unlock()
+
+	snippets, err := loadSnippets()
+	if err != nil {
+		return nil, err
+	}
+	i := findSnippet(snippets, strings.TrimSpace(opts.Name))
+	if i < 0 {
+		return nil, fmt.Errorf("no snippet named %q", opts.Name)
+	}
+
+	snippet := snippets[i]
+	if opts.NewName != "" {
+		newName, err := checkSnippetName(opts.NewName)
+		if err != nil {
+			return nil, err
+		}
+		if j := findSnippet(snippets, newName); j >= 0 && j != i {
+			return nil, fmt.Errorf("a snippet named %q already exists", newName)
+		}
+		snippet.Name = newName
+	}
+	if req.Input != "" {
+		snippet.JSON = req.Input
+	}
+	if opts.Description != nil {
+		snippet.Description = strings.TrimSpace(*opts.Description)
+	}
+	if opts.Tags != nil {
+		snippet.Tags = normalizeTags(*opts.Tags)
+	}
+	snippet.UpdatedAt = time.Now().UTC()
+	snippets[i] = snippet
+
+	if err := storeSnippets(snippets); err != nil {
+		return nil, err
+	}
+	return snippet, nil
+}
+
+// runDeleteSnippet removes a snippet from the library
+func runDeleteSnippet(req OperationRequest) (interface{}, error) {
+	var opts SnippetOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+
+	snippetsMu.Lock()
+	defer snippetsMu.Unlock()
+
+	snippets, err := loadSnippets()
+	if err != nil {
+		return nil, err
+	}
+	i := findSnippet(snippets, strings.TrimSpace(opts.Name))
+	if i < 0 {
+		return nil, fmt.Errorf("no snippet named %q", opts.Name)
+	}
+
+	deleted := snippets[i]
+	snippets = append(snippets[:i], snippets[i+1:]...)
+	if err := storeSnippets(snippets); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"name": deleted.Name, "deleted": true}, nil
+}
+
+// runListSnippets lists snippets, most recently updated first, optionally
+// filtered by tag and a full-text query. Search results put name matches
+// first.
+func runListSnippets(req OperationRequest) (interface{}, error) {
+	var opts SnippetListOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+	if opts.Limit < 0 {
+		return nil, fmt.Errorf("limit must not be negative")
+	}
+
+	snippets, err := loadSnippets()
+	if err != nil {
+		return nil, err
+	}
+
+	terms := strings.Fields(strings.ToLower(opts.Query))
+	type match struct {
+		summary SnippetSummary
+		score   int
+	}
+	var matches []match
+	for _, s := range snippets {
+		if opts.Tag != "" && !hasTag(s.Tags, opts.Tag) {
+			continue
+		}
+		score, excerpt, ok := matchSnippet(s, terms)
+		if !ok {
+			continue
+		}
+		matches = append(matches, match{
+			summary: SnippetSummary{
+				Name:        s.Name,
+				Description: s.Description,
+				Tags:        s.Tags,
+				Size:        len(s.JSON),
+				CreatedAt:   s.CreatedAt,
+				UpdatedAt:   s.UpdatedAt,
+				Excerpt:     excerpt,
+			},
+			score: score,
+		})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if !a.summary.UpdatedAt.Equal(b.summary.UpdatedAt) {
+			return a.summary.UpdatedAt.After(b.summary.UpdatedAt)
+		}
+		return a.summary.Name < b.summary.Name
+	})
+
+	result := SnippetListResult{Total: len(matches), Snippets: []SnippetSummary{}}
+	for i, m := range matches {
+		if opts.Limit > 0 && i == opts.Limit {
+			break
+		}
+		result.Snippets = append(result.Snippets, m.summary)
+	}
+	return result, nil
+}
+
+// hasTag reports whether tags contains tag, ignoring case
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSnippet checks a snippet against lower-cased search terms. Every
+// term must appear in the name, description, tags or content; name hits
+// score highest. The excerpt is the first content line holding a term.
+func matchSnippet(s Snippet, terms []string) (int, string, bool) {
+	if len(terms) == 0 {
+		return 0, "", true
+	}
+
+	name := strings.ToLower(s.Name)
+	meta := strings.ToLower(s.Description + " " + strings.Join(s.Tags, " "))
+	content := strings.ToLower(s.JSON)
+	score := 0
+	for _, term := range terms {
+		switch {
+		case strings.Contains(name, term):
+			score += 3
+		case strings.Contains(meta, term):
+			score += 2
+		case strings.Contains(content, term):
+			score++
+		default:
+			return 0, "", false
+		}
+	}
+
+	excerpt := ""
+	for _, line := range strings.Split(s.JSON, "\n") {
+		lower := strings.ToLower(line)
+		for _, term := range terms {
+			if strings.Contains(lower, term) {
+				excerpt = snippetExcerpt(line)
+				break
+			}
+		}
+		if excerpt != "" {
+			break
+		}
+	}
+	return score, excerpt, true
+}
+
+// snippetExcerpt trims a content line for display in search results
+func snippetExcerpt(line string) string {
+	const maxExcerpt = 120
+	line = strings.TrimSpace(line)
+	if runes := []rune(line); len(runes) > maxExcerpt {
+		return string(runes[:maxExcerpt]) + "…"
+	}
+	return line
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckSnippetName(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"trimmed", "  config  ", "config", false},
+		{"empty", "   ", "", true},
+		{"longest allowed", strings.Repeat("n", maxSnippetNameLength), strings.Repeat("n", maxSnippetNameLength), false},
+		{"too long", strings.Repeat("n", maxSnippetNameLength+1), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checkSnippetName(tt.input)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("checkSnippetName(%q) = %q, %v; want %q (error %v)", tt.input, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	got := normalizeTags([]string{" prod ", "api", "", "Prod", "config"})
+	want := []string{"api", "config", "prod"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeTags = %v, want %v", got, want)
+	}
+}
+
+func TestMatchSnippet(t *testing.T) {
+	snippet := Snippet{
+		Name:        "Payment webhook",
+		Description: "Sample event from the billing service",
+		Tags:        []string{"stripe"},
+		JSON:        "{\n  \"type\": \"invoice.paid\",\n  \"amount\": 1200\n}",
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		score   int
+		excerpt string
+		ok      bool
+	}{
+		{"no query matches everything", "", 0, "", true},
+		{"name", "webhook", 3, "", true},
+		{"description and tags", "billing stripe", 4, "", true},
+		{"content", "invoice", 1, `"type": "invoice.paid",`, true},
+		{"every term must match", "payment refund", 0, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, excerpt, ok := matchSnippet(snippet, strings.Fields(strings.ToLower(tt.query)))
+			if score != tt.score || excerpt != tt.excerpt || ok != tt.ok {
+				t.Errorf("matchSnippet(%q) = %d, %q, %v; want %d, %q, %v", tt.query, score, excerpt, ok, tt.score, tt.excerpt, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSnippetExcerpt(t *testing.T) {
+	long := "  " + strings.Repeat("é", 130)
+	if got := snippetExcerpt(long); got != strings.Repeat("é", 120)+"…" {
+		t.Errorf("snippetExcerpt = %q", got)
+	}
+	if got := snippetExcerpt("\t\"a\": 1,"); got != `"a": 1,` {
+		t.Errorf("snippetExcerpt = %q", got)
+	}
+}
+
+func TestFindSnippet(t *testing.T) {
+	snippets := []Snippet{{Name: "a"}, {Name: "b"}}
+	if i := findSnippet(snippets, "b"); i != 1 {
+		t.Errorf("findSnippet(b) = %d, want 1", i)
+	}
+	if i := findSnippet(snippets, "c"); i != -1 {
+		t.Errorf("findSnippet(c) = %d, want -1", i)
+	}
+}
+
+func TestSnippetOperationErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		run     func(OperationRequest) (interface{}, error)
+		input   string
+		options string
+		want    string
+	}{
+		{"create needs a name", runCreateSnippet, `{}`, `{}`, "name is required"},
+		{"create needs valid JSON", runCreateSnippet, `{"a": }`, `{"name": "x"}`, "invalid snippet JSON"},
+		{"update needs valid JSON", runUpdateSnippet, `[`, `{"name": "x"}`, "invalid snippet JSON"},
+		{"list rejects negative limits", runListSnippets, ``, `{"limit": -1}`, "must not be negative"},
+		{"storage unavailable", runGetSnippet, ``, `{"name": "x"}`, "storage not available"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.run(OperationRequest{Input: tt.input, Options: []byte(tt.options)})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,515 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// streamProgressInterval is the minimum time between progress events
+	streamProgressInterval = 250 * time.Millisecond
+	// streamCheckEvery is how many tokens are processed between checks for
+	// cancellation and progress
+	streamCheckEvery = 1024
+	// streamBufferSize is the read and write buffer size of a streaming job
+	streamBufferSize = 64 * 1024
+)
+
+// StreamOptions are the options of the stream operation. The document is
+// read from InputPath when set, otherwise from the request input. Formatted
+// output is only produced when OutputPath is set, so memory use does not
+// grow with the document.
+type StreamOptions struct {
+	InputPath  string `json:"inputPath"`
+	OutputPath string `json:"outputPath"`
+}
+
+// StreamJob identifies a started streaming job
+type StreamJob struct {
+	JobID string `json:"jobId"`
+}
+
+// StreamCancelOptions are the options of the cancelStream operation
+type StreamCancelOptions struct {
+	JobID string `json:"jobId"`
+}
+
+// StreamProgress is sent to the host as a MessageTypeProgress event while a
+// job runs. The final event has Done set and carries the result.
+type StreamProgress struct {
+	JobID      string        `json:"jobId"`
+	BytesRead  int64         `json:"bytesRead"`
+	TotalBytes int64         `json:"totalBytes,omitempty"`
+	Tokens     int64         `json:"tokens"`
+	Done       bool          `json:"done"`
+	Result     *StreamResult `json:"result,omitempty"`
+}
+
+// StreamResult is the outcome of a streaming job
+type StreamResult struct {
+	IsValid      bool   `json:"isValid"`
+	Cancelled    bool   `json:"cancelled,omitempty"`
+	BytesRead    int64  `json:"bytesRead"`
+	Tokens       int64  `json:"tokens"`
+	MaxDepth     int    `json:"maxDepth"`
+	OutputPath   string `json:"outputPath,omitempty"`
+	OutputBytes  int64  `json:"outputBytes,omitempty"`
+	ErrorMessage string `json:"errorMessage,omitempty"`
+	LineNumber   int    `json:"lineNumber,omitempty"`
+	Column       int    `json:"column,omitempty"`
+	Offset       int    `json:"offset,omitempty"`
+}
+
+// streamJob is a running streaming job
+type streamJob struct {
+	id        string
+	requestID string
+	cancel    context.CancelFunc
+}
+
+var (
+	streamJobsMu sync.Mutex
+	streamJobs   = make(map[string]*streamJob)
+	streamJobSeq int64
+)
+
+// runStream starts a streaming validation job in the background and
+// returns its ID. Progress and the final result arrive as events.
+func runStream(req OperationRequest) (interface{}, error) {
+	var opts StreamOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+
+	if err := opts.check(); err != nil {
+		return nil, err
+	}
+	open, total, err := opts.opener(req.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &streamJob{
+		id:        fmt.Sprintf("stream-%d", atomic.AddInt64(&streamJobSeq, 1)),
+		requestID: req.RequestID,
+		cancel:    cancel,
+	}
+
+	streamJobsMu.Lock()
+	streamJobs[job.id] = job
+	streamJobsMu.Unlock()
+
+	style := currentFormatSettings().style()
+	go func() {
+		defer func() {
+			cancel()
+			streamJobsMu.Lock()
+			delete(streamJobs, job.id)
+			streamJobsMu.Unlock()
+		}()
+
+		result := streamValidate(ctx, open, total, opts.OutputPath, style, job.report)
+		job.report(StreamProgress{
+			BytesRead:  result.BytesRead,
+			TotalBytes: total,
+			Tokens:     result.Tokens,
+			Done:       true,
+			Result:     &result,
+		})
+	}()
+
+	return StreamJob{JobID: job.id}, nil
+}
+
+// runCancelStream cancels a running streaming job
+func runCancelStream(req OperationRequest) (interface{}, error) {
+	var opts StreamCancelOptions
+	if err := decodeOptions(req, &opts); err != nil {
+		return nil, err
+	}
+
+	streamJobsMu.Lock()
+	job, ok := streamJobs[opts.JobID]
+	streamJobsMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no running stream job %q", opts.JobID)
+	}
+
+	job.cancel()
+	return StreamJob{JobID: job.id}, nil
+}
+
+// report sends a progress event for the job to the host
+func (j *streamJob) report(progress StreamProgress) {
+	progress.JobID = j.id
+	sendResponse(MessageTypeProgress, APIResponse{
+		RequestID: j.requestID,
+		Success:   true,
+		Data:      progress,
+	})
+}
+
+// check rejects an output path that would overwrite the input while it is
+// being read
+func (o StreamOptions) check() error {
+	if o.InputPath == "" || o.OutputPath == "" {
+		return nil
+	}
+	if filepath.Clean(o.InputPath) == filepath.Clean(o.OutputPath) {
+		return fmt.Errorf("output path must differ from the input path")
+	}
+	inputInfo, err := os.Stat(o.InputPath)
+	if err != nil {
+		return nil
+	}
+	if outputInfo, err := os.Stat(o.OutputPath); err == nil && os.SameFile(inputInfo, outputInfo) {
+		return fmt.Errorf("output path must differ from the input path")
+	}
+	return nil
+}
+
+// opener returns a function that opens the job input, along with its size.
+// A function is returned so the input can be re-read to locate errors.
+func (o StreamOptions) opener(input string) (func() (io.ReadCloser, error), int64, error) {
+	if o.InputPath == "" {
+		open := func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(input)), nil
+		}
+		return open, int64(len(input)), nil
+	}
+
+	info, err := os.Stat(o.InputPath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot read input file: %w", err)
+	}
+	if info.IsDir() {
+		return nil, 0, fmt.Errorf("input path %q is a directory", o.InputPath)
+	}
+	open := func() (io.ReadCloser, error) {
+		return os.Open(o.InputPath)
+	}
+	return open, info.Size(), nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	atomic.AddInt64(&c.n, int64(n))
+	return n, err
+}
+
+// sourceRecorder keeps the input the decoder has read ahead but not yet
+// consumed, so scalar tokens can be copied out with their source spelling
+type sourceRecorder struct {
+	r   io.Reader
+	buf []byte
+	// start is the input offset of buf[0]
+	start int64
+}
+
+func (s *sourceRecorder) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.buf = append(s.buf, p[:n]...)
+	return n, err
+}
+
+// token returns the source text of the scalar that ends at end and starts
+// after the separators that follow from
+func (s *sourceRecorder) token(from, end int64) string {
+	i := from - s.start
+	for i < end-s.start && strings.IndexByte(" \t\r\n,:", s.buf[i]) >= 0 {
+		i++
+	}
+	return string(s.buf[i : end-s.start])
+}
+
+// discard drops the recorded input before offset
+func (s *sourceRecorder) discard(offset int64) {
+	s.buf = s.buf[offset-s.start:]
+	s.start = offset
+}
+
+// streamFrame tracks one open container while streaming
+type streamFrame struct {
+	object bool
+	count  int
+}
+
+// streamWriter writes formatted JSON one token at a time
+type streamWriter struct {
+	w      *bufio.Writer
+	style  formatStyle
+	frames []streamFrame
+	n      int64
+	err    error
+}
+
+// write appends raw text to the output
+func (s *streamWriter) write(text string) {
+	if s.err != nil || s.w == nil {
+		return
+	}
+	n, err := s.w.WriteString(text)
+	s.n += int64(n)
+	s.err = err
+}
+
+// newline starts a new line at the current depth
+func (s *streamWriter) newline() {
+	if s.style.Minify {
+		return
+	}
+	s.write("\n")
+	s.write(strings.Repeat(s.style.Indent, len(s.frames)))
+}
+
+// beginValue writes the separator before a value. Keys count as values
+// of their object here; the value after a key follows the colon directly.
+func (s *streamWriter) beginValue(isKey bool) {
+	if len(s.frames) == 0 {
+		return
+	}
+	top := &s.frames[len(s.frames)-1]
+	if top.object && !isKey {
+		return
+	}
+	if top.count > 0 {
+		s.write(",")
+	}
+	top.count++
+	s.newline()
+}
+
+// scalar writes a string, number or literal token exactly as it was
+// spelled in the source
+func (s *streamWriter) scalar(text string, isString, isKey bool) {
+	s.beginValue(isKey)
+
+	if isString && s.style.EscapeHTML {
+		text = escapeHTML(text)
+	}
+	s.write(text)
+
+	if isKey {
+		if s.style.Minify {
+			s.write(":")
+		} else {
+			s.write(": ")
+		}
+	}
+}
+
+// open writes the opening bracket of a container
+func (s *streamWriter) open(delim json.Delim) {
+	s.beginValue(false)
+	s.write(delim.String())
+	s.frames = append(s.frames, streamFrame{object: delim == '{'})
+}
+
+// close writes the closing bracket of a container
+func (s *streamWriter) close(delim json.Delim) {
+	top := s.frames[len(s.frames)-1]
+	s.frames = s.frames[:len(s.frames)-1]
+	if top.count > 0 {
+		s.newline()
+	}
+	s.write(delim.String())
+}
+
+// streamValidate reads a document token by token, checking its syntax and
+// writing it reformatted to outputPath when one is given. Only the current
+// token and the stack of open containers are held in memory. Output goes to
+// a temporary file that replaces outputPath once the whole document has
+// been written, so a failed job leaves any existing file untouched.
+func streamValidate(ctx context.Context, open func() (io.ReadCloser, error), total int64, outputPath string, style formatStyle, report func(StreamProgress)) StreamResult {
+	var result StreamResult
+
+	input, err := open()
+	if err != nil {
+		result.ErrorMessage = "cannot read input: " + err.Error()
+		return result
+	}
+	defer input.Close()
+
+	counter := &countingReader{r: input}
+	source := &sourceRecorder{r: bufio.NewReaderSize(counter, streamBufferSize)}
+	dec := json.NewDecoder(source)
+	dec.UseNumber()
+
+	out := &streamWriter{style: style}
+	var outFile *os.File
+	if outputPath != "" {
+		outFile, err = os.CreateTemp(filepath.Dir(outputPath), "."+filepath.Base(outputPath)+".*.tmp")
+		if err != nil {
+			result.ErrorMessage = "cannot create output file: " + err.Error()
+			return result
+		}
+		// CreateTemp makes the file private; match what os.Create would give
+		outFile.Chmod(0o644)
+		out.w = bufio.NewWriterSize(outFile, streamBufferSize)
+	}
+
+	// fail records an error and removes any partial output
+	fail := func(msg string, offset int64) StreamResult {
+		result.ErrorMessage = msg
+		if offset >= 0 {
+			if pos, err := streamPositionAt(open, offset); err == nil {
+				result.LineNumber = pos.Line
+				result.Column = pos.Column
+				result.Offset = pos.Offset
+			}
+		}
+		if outFile != nil {
+			outFile.Close()
+			os.Remove(outFile.Name())
+		}
+		result.BytesRead = atomic.LoadInt64(&counter.n)
+		return result
+	}
+
+	lastReport := time.Now()
+	expectKey := false
+	for {
+		tokenStart := dec.InputOffset()
+		source.discard(tokenStart)
+		tok, err := dec.Token()
+		if err == io.EOF {
+			if result.Tokens == 0 {
+				return fail("Empty JSON input", -1)
+			}
+			return fail("unexpected end of input", dec.InputOffset())
+		}
+		if err != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				return fail(syntaxErr.Error(), syntaxErr.Offset-1)
+			}
+			return fail(err.Error(), dec.InputOffset())
+		}
+		result.Tokens++
+
+		wasKey := false
+		switch v := tok.(type) {
+		case json.Delim:
+			if v == '{' || v == '[' {
+				if len(out.frames) >= maxTreeDepth {
+					return fail("document nested too deeply", dec.InputOffset()-1)
+				}
+				out.open(v)
+				if len(out.frames) > result.MaxDepth {
+					result.MaxDepth = len(out.frames)
+				}
+			} else {
+				out.close(v)
+			}
+		default:
+			_, isString := v.(string)
+			out.scalar(source.token(tokenStart, dec.InputOffset()), isString, expectKey)
+			wasKey = expectKey
+		}
+
+		// The decoder has already checked the grammar, so inside an object
+		// a key is due after anything other than a key
+		inObject := len(out.frames) > 0 && out.frames[len(out.frames)-1].object
+		expectKey = inObject && !wasKey
+
+		if len(out.frames) == 0 {
+			break
+		}
+
+		if result.Tokens%streamCheckEvery == 0 {
+			if ctx.Err() != nil {
+				result.Cancelled = true
+				return fail("cancelled", -1)
+			}
+			if time.Since(lastReport) >= streamProgressInterval {
+				report(StreamProgress{BytesRead: atomic.LoadInt64(&counter.n), TotalBytes: total, Tokens: result.Tokens})
+				lastReport = time.Now()
+			}
+		}
+	}
+
+	// Only whitespace may follow the top-level value
+	end := dec.InputOffset()
+	if _, err := dec.Token(); err != io.EOF {
+		return fail("unexpected data after top-level value", end+leadingWhitespace(dec.Buffered()))
+	}
+
+	if out.w != nil {
+		out.write("\n")
+		if err := out.w.Flush(); err != nil && out.err == nil {
+			out.err = err
+		}
+		if err := outFile.Close(); err != nil && out.err == nil {
+			out.err = err
+		}
+		if out.err == nil {
+			out.err = os.Rename(outFile.Name(), outputPath)
+		}
+		if out.err != nil {
+			return fail("cannot write output file: "+out.err.Error(), -1)
+		}
+		result.OutputPath = outputPath
+		result.OutputBytes = out.n
+	}
+
+	result.IsValid = true
+	result.BytesRead = atomic.LoadInt64(&counter.n)
+	return result
+}
+
+// streamPositionAt finds the line and column of offset by re-reading the
+// input, so the document never has to be held in memory
+func streamPositionAt(open func() (io.ReadCloser, error), offset int64) (SourcePosition, error) {
+	input, err := open()
+	if err != nil {
+		return SourcePosition{}, err
+	}
+	defer input.Close()
+
+	pos := SourcePosition{Offset: int(offset), Line: 1, Column: 1}
+	r := bufio.NewReaderSize(input, streamBufferSize)
+	for read := int64(0); read < offset; {
+		ch, size, err := r.ReadRune()
+		if err != nil {
+			break
+		}
+		read += int64(size)
+		if ch == '\n' {
+			pos.Line++
+			pos.Column = 1
+		} else {
+			pos.Column++
+		}
+	}
+	return pos, nil
+}
+
+// leadingWhitespace counts the whitespace bytes at the start of r
+func leadingWhitespace(r io.Reader) int64 {
+	var n int64
+	br := bufio.NewReader(r)
+	for {
+		c, err := br.ReadByte()
+		if err != nil || strings.IndexByte(" \t\r\n", c) < 0 {
+			return n
+		}
+		n++
+	}
+}
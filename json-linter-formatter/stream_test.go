@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// openString returns an opener over a fixed input
+func openString(input string) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(input)), nil
+	}
+}
+
+func ignoreProgress(StreamProgress) {}
+
+func TestStreamValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		valid    bool
+		message  string
+		line     int
+		column   int
+		maxDepth int
+	}{
+		{name: "object", input: `{"a": [1, {"b": null}]}`, valid: true, maxDepth: 3},
+		{name: "scalar", input: ` 42 `, valid: true},
+		{name: "empty", input: "  ", message: "Empty JSON input"},
+		{name: "syntax error", input: "{\n  \"a\": tru\n}", message: "invalid character", line: 2, column: 11},
+		{name: "truncated", input: `[1, 2`, message: "unexpected end of input"},
+		{name: "trailing data", input: "{}\n  x", message: "unexpected data after top-level value", line: 2, column: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := streamValidate(context.Background(), openString(tt.input), int64(len(tt.input)), "", defaultFormatStyle, ignoreProgress)
+			if result.IsValid != tt.valid {
+				t.Fatalf("isValid = %v, want %v (%s)", result.IsValid, tt.valid, result.ErrorMessage)
+			}
+			if !strings.Contains(result.ErrorMessage, tt.message) {
+				t.Errorf("error = %q, want it to contain %q", result.ErrorMessage, tt.message)
+			}
+			if tt.line != 0 && (result.LineNumber != tt.line || result.Column != tt.column) {
+				t.Errorf("position = %d:%d, want %d:%d", result.LineNumber, result.Column, tt.line, tt.column)
+			}
+			if tt.valid && result.MaxDepth != tt.maxDepth {
+				t.Errorf("maxDepth = %d, want %d", result.MaxDepth, tt.maxDepth)
+			}
+		})
+	}
+}
+
+func TestStreamValidateWritesSourceSpelling(t *testing.T) {
+	dir := t.TempDir()
+	outputPath := filepath.Join(dir, "out.json")
+	input := `{"caf\u00e9": "a\/b", "n": [1.0, 1e3, 12345678901234567890], "h": "<&>", "ok": true, "none": null}`
+
+	result := streamValidate(context.Background(), openString(input), int64(len(input)), outputPath, defaultFormatStyle, ignoreProgress)
+	if !result.IsValid {
+		t.Fatal(result.ErrorMessage)
+	}
+
+	got, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := formatTokens(input, defaultFormatStyle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want+"\n" {
+		t.Errorf("output =\n%s\nwant\n%s", got, want)
+	}
+	if result.OutputBytes != int64(len(got)) {
+		t.Errorf("outputBytes = %d, want %d", result.OutputBytes, len(got))
+	}
+}
+
+func TestStreamValidateStyles(t *testing.T) {
+	input := `{"a": ["<b>", {}], "c": {"d": []}}`
+	for _, style := range []formatStyle{
+		{Minify: true},
+		{Indent: "\t", LineWidth: defaultLineWidth},
+		{Indent: "  ", EscapeHTML: true, LineWidth: defaultLineWidth},
+	} {
+		outputPath := filepath.Join(t.TempDir(), "out.json")
+		result := streamValidate(context.Background(), openString(input), int64(len(input)), outputPath, style, ignoreProgress)
+		if !result.IsValid {
+			t.Fatal(result.ErrorMessage)
+		}
+		got, _ := os.ReadFile(outputPath)
+		want, _ := formatTokens(input, style)
+		if string(got) != want+"\n" {
+			t.Errorf("style %+v: output =\n%s\nwant\n%s", style, got, want)
+		}
+	}
+}
+
+func TestStreamValidateCancel(t *testing.T) {
+	input := "[" + strings.Repeat("1,", streamCheckEvery*4) + "1]"
+	outputPath := filepath.Join(t.TempDir(), "out.json")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result := streamValidate(ctx, openString(input), int64(len(input)), outputPath, defaultFormatStyle, ignoreProgress)
+	if !result.Cancelled || result.IsValid {
+		t.Fatalf("result = %+v, want a cancelled job", result)
+	}
+	if result.Tokens != streamCheckEvery {
+		t.Errorf("tokens = %d, want the job to stop at the first check", result.Tokens)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(outputPath)); len(entries) != 0 {
+		t.Errorf("expected no output files, found %d", len(entries))
+	}
+}
+
+func TestStreamValidateFailureKeepsExistingOutput(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "out.json")
+	if err := os.WriteFile(outputPath, []byte("previous"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result := streamValidate(context.Background(), openString(`[1, 2`), 5, outputPath, defaultFormatStyle, ignoreProgress)
+	if result.IsValid {
+		t.Fatal("expected the job to fail")
+	}
+	got, err := os.ReadFile(outputPath)
+	if err != nil || string(got) != "previous" {
+		t.Errorf("output file = %q, %v; want it untouched", got, err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(outputPath)); len(entries) != 1 {
+		t.Errorf("expected the temporary file to be removed, found %d files", len(entries))
+	}
+}
+
+func TestStreamOptionsCheck(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "in.json")
+	if err := os.WriteFile(inputPath, []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link.json")
+	if err := os.Symlink(inputPath, link); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+
+	tests := []struct {
+		name    string
+		opts    StreamOptions
+		wantErr bool
+	}{
+		{"no output", StreamOptions{InputPath: inputPath}, false},
+		{"request input", StreamOptions{OutputPath: inputPath}, false},
+		{"different output", StreamOptions{InputPath: inputPath, OutputPath: filepath.Join(dir, "out.json")}, false},
+		{"same path", StreamOptions{InputPath: inputPath, OutputPath: inputPath}, true},
+		{"same path after cleaning", StreamOptions{InputPath: inputPath, OutputPath: dir + "/./in.json"}, true},
+		{"same file through a link", StreamOptions{InputPath: inputPath, OutputPath: link}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.check(); (err != nil) != tt.wantErr {
+				t.Errorf("check() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunCancelStreamUnknownJob(t *testing.T) {
+	req := OperationRequest{Operation: "cancelStream", Options: []byte(`{"jobId": "stream-missing"}`)}
+	if _, err := runCancelStream(req); err == nil {
+		t.Error("expected cancelling an unknown job to fail")
+	}
+}
@@ -0,0 +1,610 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	tomlBareKey  = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	tomlDate     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+	tomlTime     = regexp.MustCompile(`^\d{2}:\d{2}`)
+	tomlInt      = regexp.MustCompile(`^[-+]?(0|[1-9](_?[0-9])*)$`)
+	tomlRadixInt = regexp.MustCompile(`^0(x[0-9A-Fa-f](_?[0-9A-Fa-f])*|o[0-7](_?[0-7])*|b[01](_?[01])*)$`)
+	tomlFloat    = regexp.MustCompile(`^[-+]?(0|[1-9](_?[0-9])*)(\.[0-9](_?[0-9])*)?([eE][-+]?[0-9](_?[0-9])*)?$`)
+	tomlSpecial  = regexp.MustCompile(`^[-+]?(inf|nan)$`)
+)
+
+// writeTOML renders an object document as TOML. Nulls have no TOML
+// spelling and are reported and left out.
+func (c *conversion) writeTOML(doc *node) (string, error) {
+	if doc.Kind != nodeObject {
+		return "", fmt.Errorf("a TOML document must be a table, but the input is a %s", doc.Kind)
+	}
+	var b strings.Builder
+	c.tomlTable(&b, doc, "", "")
+	return strings.TrimLeft(b.String(), "\n"), nil
+}
+
+// isTOMLTable reports whether v is written as a [table] or [[array]]
+// section rather than as an inline value
+func isTOMLTable(v *node) bool {
+	if v.Kind == nodeObject {
+		return true
+	}
+	if v.Kind != nodeArray || len(v.Items) == 0 {
+		return false
+	}
+	for _, item := range v.Items {
+		if item.Kind != nodeObject {
+			return false
+		}
+	}
+	return true
+}
+
+// tomlTable writes the members of a table: plain keys first, then
+// sub-tables and arrays of tables under their headers
+func (c *conversion) tomlTable(b *strings.Builder, n *node, path, pointer string) {
+	keys, values := lastMembers(n)
+	if len(keys) < len(n.Members) {
+		c.warn(pointer, 0, "duplicate keys are not allowed in TOML; the last value of each is used")
+	}
+
+	for _, key := range keys {
+		v := values[key]
+		if isTOMLTable(v) {
+			continue
+		}
+		child := appendPointer(pointer, key)
+		if v.Kind == nodeNull {
+			c.warn(child, 0, "null cannot be represented in TOML; the key was left out")
+			continue
+		}
+		fmt.Fprintf(b, "%s = %s\n", tomlKey(key), c.tomlInline(v, child))
+	}
+
+	for _, key := range keys {
+		v := values[key]
+		if !isTOMLTable(v) {
+			continue
+		}
+		header := tomlKey(key)
+		if path != "" {
+			header = path + "." + header
+		}
+		child := appendPointer(pointer, key)
+		if v.Kind == nodeObject {
+			fmt.Fprintf(b, "\n[%s]\n", header)
+			c.tomlTable(b, v, header, child)
+			continue
+		}
+		for i, item := range v.Items {
+			fmt.Fprintf(b, "\n[[%s]]\n", header)
+			c.tomlTable(b, item, header, appendPointerIndex(child, i))
+		}
+	}
+}
+
+// tomlInline spells a value on one line
+func (c *conversion) tomlInline(v *node, pointer string) string {
+	switch v.Kind {
+	case nodeString:
+		return jsonQuote(scalarText(v))
+	case nodeNumber:
+		if strings.ContainsAny(v.Raw, ".eE") {
+			return v.Raw
+		}
+		if value, _ := new(big.Int).SetString(v.Raw, 10); !value.IsInt64() {
+			c.warn(pointer, 0, "integer %s is outside TOML's 64-bit range; written as a string", v.Raw)
+			return jsonQuote(v.Raw)
+		}
+		return v.Raw
+	case nodeArray:
+		var items []string
+		for i, item := range v.Items {
+			if item.Kind == nodeNull {
+				c.warn(appendPointerIndex(pointer, i), 0, "null cannot be represented in TOML; the item was left out")
+				continue
+			}
+			items = append(items, c.tomlInline(item, appendPointerIndex(pointer, i)))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case nodeObject:
+		keys, values := lastMembers(v)
+		var fields []string
+		for _, key := range keys {
+			child := appendPointer(pointer, key)
+			if values[key].Kind == nodeNull {
+				c.warn(child, 0, "null cannot be represented in TOML; the key was left out")
+				continue
+			}
+			fields = append(fields, tomlKey(key)+" = "+c.tomlInline(values[key], child))
+		}
+		if len(fields) == 0 {
+			return "{}"
+		}
+		return "{ " + strings.Join(fields, ", ") + " }"
+	default:
+		return v.Raw
+	}
+}
+
+// tomlKey spells a key bare when possible and quoted otherwise
+func tomlKey(key string) string {
+	if tomlBareKey.MatchString(key) {
+		return key
+	}
+	return jsonQuote(key)
+}
+
+// tomlParser reads a TOML document
+type tomlParser struct {
+	c       *conversion
+	src     string
+	pos     int
+	root    *node
+	current *node
+	// tables records explicitly defined tables to catch redefinitions
+	tables map[*node]bool
+}
+
+// readTOML parses TOML into the JSON document model. Dates and times
+// become strings.
+func (c *conversion) readTOML(input string) (*node, error) {
+	root := &node{Kind: nodeObject}
+	p := &tomlParser{c: c, src: input, root: root, current: root, tables: make(map[*node]bool)}
+	for {
+		p.skipBlank()
+		if p.pos == len(p.src) {
+			return root, nil
+		}
+		var err error
+		if p.src[p.pos] == '[' {
+			err = p.parseHeader()
+		} else {
+			err = p.parseKeyValue(p.current)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := p.endOfLine(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// line returns the 1-based line of the current position
+func (p *tomlParser) line() int {
+	return strings.Count(p.src[:p.pos], "\n") + 1
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line(), fmt.Sprintf(format, args...))
+}
+
+// skipSpace skips spaces and tabs
+func (p *tomlParser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// skipComment skips a comment up to the end of the line
+func (p *tomlParser) skipComment() {
+	if p.pos < len(p.src) && p.src[p.pos] == '#' {
+		for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+			p.pos++
+		}
+	}
+}
+
+// skipBlank skips whitespace, newlines and comments
+func (p *tomlParser) skipBlank() {
+	for {
+		p.skipSpace()
+		p.skipComment()
+		if p.pos < len(p.src) && (p.src[p.pos] == '\n' || p.src[p.pos] == '\r') {
+			p.pos++
+			continue
+		}
+		return
+	}
+}
+
+// endOfLine requires nothing but a comment before the next line
+func (p *tomlParser) endOfLine() error {
+	p.skipSpace()
+	p.skipComment()
+	if strings.HasPrefix(p.src[p.pos:], "\r\n") {
+		p.pos++
+	}
+	if p.pos < len(p.src) && p.src[p.pos] != '\n' {
+		return p.errorf("unexpected %q at end of line", p.src[p.pos])
+	}
+	return nil
+}
+
+// parseHeader reads a [table] or [[array of tables]] header and makes it
+// the current table
+func (p *tomlParser) parseHeader() error {
+	array := strings.HasPrefix(p.src[p.pos:], "[[")
+	if array {
+		p.pos += 2
+	} else {
+		p.pos++
+	}
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	closer := "]"
+	if array {
+		closer = "]]"
+	}
+	p.skipSpace()
+	if !strings.HasPrefix(p.src[p.pos:], closer) {
+		return p.errorf("expected %q to close the table header", closer)
+	}
+	p.pos += len(closer)
+
+	parent, err := p.descend(p.root, keys[:len(keys)-1], true)
+	if err != nil {
+		return err
+	}
+	last := keys[len(keys)-1]
+	index := memberIndex(parent, last)
+
+	if array {
+		list := &node{Kind: nodeArray}
+		if index >= 0 {
+			list = parent.Members[index].Value
+			if list.Kind != nodeArray {
+				return p.errorf("key %q is already defined and is not an array of tables", last)
+			}
+		} else {
+			setMember(parent, last, list)
+		}
+		table := &node{Kind: nodeObject}
+		list.Items = append(list.Items, table)
+		p.current = table
+		return nil
+	}
+
+	if index >= 0 {
+		table := parent.Members[index].Value
+		if table.Kind != nodeObject || p.tables[table] {
+			return p.errorf("table %q is defined more than once", strings.Join(keys, "."))
+		}
+		p.tables[table] = true
+		p.current = table
+		return nil
+	}
+	table := &node{Kind: nodeObject}
+	setMember(parent, last, table)
+	p.tables[table] = true
+	p.current = table
+	return nil
+}
+
+// descend walks dotted keys from table, creating missing tables. In
+// headers an array of tables continues at its last element.
+func (p *tomlParser) descend(table *node, keys []string, header bool) (*node, error) {
+	for _, key := range keys {
+		index := memberIndex(table, key)
+		if index < 0 {
+			next := &node{Kind: nodeObject}
+			setMember(table, key, next)
+			table = next
+			continue
+		}
+		next := table.Members[index].Value
+		if header && next.Kind == nodeArray && len(next.Items) > 0 && next.Items[len(next.Items)-1].Kind == nodeObject {
+			next = next.Items[len(next.Items)-1]
+		}
+		if next.Kind != nodeObject {
+			return nil, p.errorf("key %q is already defined as a %s", key, next.Kind)
+		}
+		table = next
+	}
+	return table, nil
+}
+
+// parseKeyValue reads key = value into table
+func (p *tomlParser) parseKeyValue(table *node) error {
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	p.skipSpace()
+	if p.pos == len(p.src) || p.src[p.pos] != '=' {
+		return p.errorf("expected '=' after key %q", strings.Join(keys, "."))
+	}
+	p.pos++
+	p.skipSpace()
+
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+	parent, err := p.descend(table, keys[:len(keys)-1], false)
+	if err != nil {
+		return err
+	}
+	last := keys[len(keys)-1]
+	if memberIndex(parent, last) >= 0 {
+		return p.errorf("key %q is defined more than once", strings.Join(keys, "."))
+	}
+	setMember(parent, last, value)
+	return nil
+}
+
+// parseKey reads a possibly dotted key
+func (p *tomlParser) parseKey() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpace()
+		if p.pos == len(p.src) {
+			return nil, p.errorf("expected a key")
+		}
+		switch p.src[p.pos] {
+		case '"', '\'':
+			s, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, s)
+		default:
+			start := p.pos
+			for p.pos < len(p.src) && tomlBareKey.MatchString(p.src[p.pos:p.pos+1]) {
+				p.pos++
+			}
+			if start == p.pos {
+				return nil, p.errorf("expected a key, found %q", p.src[p.pos])
+			}
+			keys = append(keys, p.src[start:p.pos])
+		}
+		p.skipSpace()
+		if p.pos < len(p.src) && p.src[p.pos] == '.' {
+			p.pos++
+			continue
+		}
+		return keys, nil
+	}
+}
+
+// parseValue reads any TOML value
+func (p *tomlParser) parseValue() (*node, error) {
+	if p.pos == len(p.src) {
+		return nil, p.errorf("expected a value")
+	}
+	switch p.src[p.pos] {
+	case '"', '\'':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return stringNode(s), nil
+	case '[':
+		return p.parseArray()
+	case '{':
+		return p.parseInlineTable()
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && strings.IndexByte("0123456789abcdefABCDEFilnorstuxyzTZ_:.+-", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+	text := p.src[start:p.pos]
+	// A local date may be followed by a space and a time
+	if tomlDate.MatchString(text) && len(text) == 10 && p.pos+1 < len(p.src) && p.src[p.pos] == ' ' && tomlTime.MatchString(p.src[p.pos+1:]) {
+		p.pos++
+		for p.pos < len(p.src) && strings.IndexByte("0123456789:.+-Zz", p.src[p.pos]) >= 0 {
+			p.pos++
+		}
+		text = p.src[start:p.pos]
+	}
+
+	switch {
+	case text == "true" || text == "false":
+		return &node{Kind: nodeBool, Raw: text}, nil
+	case tomlDate.MatchString(text) || tomlTime.MatchString(text):
+		return stringNode(text), nil
+	case tomlSpecial.MatchString(text):
+		p.c.warn("", p.line(), "%s cannot be represented in JSON; kept as a string", text)
+		return stringNode(text), nil
+	case tomlRadixInt.MatchString(text):
+		base := map[byte]int{'x': 16, 'o': 8, 'b': 2}[text[1]]
+		value, _ := new(big.Int).SetString(strings.ReplaceAll(text[2:], "_", ""), base)
+		return &node{Kind: nodeNumber, Raw: value.String()}, nil
+	case tomlInt.MatchString(text) || tomlFloat.MatchString(text):
+		strict, err := strictNumber(strings.ReplaceAll(text, "_", ""))
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		return &node{Kind: nodeNumber, Raw: strict}, nil
+	case text == "":
+		return nil, p.errorf("expected a value, found %q", p.src[p.pos])
+	default:
+		return nil, p.errorf("invalid value %q", text)
+	}
+}
+
+// parseArray reads [a, b, ...], which may span lines
+func (p *tomlParser) parseArray() (*node, error) {
+	arr := &node{Kind: nodeArray}
+	p.pos++
+	for {
+		p.skipBlank()
+		if p.pos == len(p.src) {
+			return nil, p.errorf("unterminated array")
+		}
+		if p.src[p.pos] == ']' {
+			p.pos++
+			return arr, nil
+		}
+		item, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		arr.Items = append(arr.Items, item)
+		p.skipBlank()
+		if p.pos < len(p.src) && p.src[p.pos] == ',' {
+			p.pos++
+		} else if p.pos < len(p.src) && p.src[p.pos] != ']' {
+			return nil, p.errorf("expected ',' or ']' in array")
+		}
+	}
+}
+
+// parseInlineTable reads { a = 1, b = 2 } on one line
+func (p *tomlParser) parseInlineTable() (*node, error) {
+	table := &node{Kind: nodeObject}
+	p.pos++
+	p.skipSpace()
+	if p.pos < len(p.src) && p.src[p.pos] == '}' {
+		p.pos++
+		return table, nil
+	}
+	for {
+		if err := p.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.pos == len(p.src) {
+			return nil, p.errorf("unterminated inline table")
+		}
+		switch p.src[p.pos] {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return table, nil
+		default:
+			return nil, p.errorf("expected ',' or '}' in inline table")
+		}
+	}
+}
+
+// parseString reads a basic, literal or multi-line string
+func (p *tomlParser) parseString() (string, error) {
+	quote := p.src[p.pos]
+	multi := strings.Repeat(string(quote), 3)
+	if strings.HasPrefix(p.src[p.pos:], multi) {
+		p.pos += 3
+		if strings.HasPrefix(p.src[p.pos:], "\r\n") {
+			p.pos += 2
+		} else if strings.HasPrefix(p.src[p.pos:], "\n") {
+			p.pos++
+		}
+		start := p.pos
+		for {
+			end := strings.Index(p.src[p.pos:], multi)
+			if end < 0 {
+				return "", p.errorf("unterminated multi-line string")
+			}
+			p.pos += end
+			if quote == '"' && escapedAt(p.src, p.pos) {
+				p.pos++
+				continue
+			}
+			// Up to two quotes may sit right before the closing delimiter
+			for extra := 0; extra < 2 && strings.HasPrefix(p.src[p.pos+1:], multi); extra++ {
+				p.pos++
+			}
+			body := p.src[start:p.pos]
+			p.pos += 3
+			if quote == '\'' {
+				return body, nil
+			}
+			return p.unescape(body, true)
+		}
+	}
+
+	p.pos++
+	start := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] != quote && p.src[p.pos] != '\n' {
+		if quote == '"' && p.src[p.pos] == '\\' {
+			p.pos++
+		}
+		p.pos++
+	}
+	if p.pos >= len(p.src) || p.src[p.pos] != quote {
+		return "", p.errorf("unterminated string")
+	}
+	body := p.src[start:p.pos]
+	p.pos++
+	if quote == '\'' {
+		return body, nil
+	}
+	return p.unescape(body, false)
+}
+
+// escapedAt reports whether the byte at i is preceded by an odd number of
+// backslashes
+func escapedAt(s string, i int) bool {
+	n := 0
+	for i > 0 && s[i-1] == '\\' {
+		n++
+		i--
+	}
+	return n%2 == 1
+}
+
+// unescape decodes the escapes of a basic string. In multi-line strings a
+// backslash at the end of a line trims the following whitespace.
+func (p *tomlParser) unescape(body string, multiline bool) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(body); i++ {
+		if body[i] != '\\' {
+			b.WriteByte(body[i])
+			continue
+		}
+		i++
+		if i == len(body) {
+			return "", p.errorf("invalid escape at end of string")
+		}
+		switch ch := body[i]; ch {
+		case 'b':
+			b.WriteByte('\b')
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case 'e':
+			b.WriteByte('\x1b')
+		case '"', '\\':
+			b.WriteByte(ch)
+		case 'u', 'U':
+			digits := 4
+			if ch == 'U' {
+				digits = 8
+			}
+			if i+digits >= len(body) {
+				return "", p.errorf("invalid escape \\%c", ch)
+			}
+			code, err := strconv.ParseUint(body[i+1:i+1+digits], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(code)) {
+				return "", p.errorf("invalid escape \\%s", body[i:i+1+digits])
+			}
+			b.WriteRune(rune(code))
+			i += digits
+		default:
+			rest := strings.TrimLeft(body[i:], " \t")
+			if !multiline || (!strings.HasPrefix(rest, "\n") && !strings.HasPrefix(rest, "\r\n")) {
+				return "", p.errorf("invalid escape \\%c", ch)
+			}
+			trimmed := strings.TrimLeft(rest, " \t\r\n")
+			i = len(body) - len(trimmed) - 1
+		}
+	}
+	return b.String(), nil
+}
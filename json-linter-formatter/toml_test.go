@@ -0,0 +1,144 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadTOML(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		want     string
+		warnings int
+	}{
+		{
+			name:  "keys and values",
+			input: "title = \"demo\"\ncount = 1_000\nhex = 0xff\nratio = 1.5\nok = true\n'quoted key' = 'raw\\n'\n",
+			want:  `{"title":"demo","count":1000,"hex":255,"ratio":1.5,"ok":true,"quoted key":"raw\\n"}`,
+		},
+		{
+			name:  "tables and dotted keys",
+			input: "a.b = 1\n[server]\nhost = \"x\"\n[server.tls]\nport = 443\n",
+			want:  `{"a":{"b":1},"server":{"host":"x","tls":{"port":443}}}`,
+		},
+		{
+			name:  "inline tables",
+			input: "point = { x = 1, y = 2 }\nnested = { a = { b = [1, 2] } }\nempty = {}\n",
+			want:  `{"point":{"x":1,"y":2},"nested":{"a":{"b":[1,2]}},"empty":{}}`,
+		},
+		{
+			name:  "arrays of tables",
+			input: "[[products]]\nname = \"a\"\n\n[[products]]\nname = \"b\"\n[products.size]\nw = 2\n",
+			want:  `{"products":[{"name":"a"},{"name":"b","size":{"w":2}}]}`,
+		},
+		{
+			name:  "multi-line strings and arrays",
+			input: "text = \"\"\"\nline one\nline two\"\"\"\nlist = [\n  1, # one\n  2,\n]\n",
+			want:  `{"text":"line one\nline two","list":[1,2]}`,
+		},
+		{
+			name:  "dates are kept as strings",
+			input: "when = 1979-05-27T07:32:00Z\n",
+			want:  `{"when":"1979-05-27T07:32:00Z"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runForTest[ConvertResult](t, runConvert, tt.input, `{"from": "toml"}`)
+			if got := compactForTest(t, result.Output); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			if result.WarningCount != tt.warnings {
+				t.Errorf("warnings = %+v, want %d", result.Warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestReadTOMLErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"duplicate key", "a = 1\na = 2\n"},
+		{"redefined table", "[a]\nx = 1\n[a]\ny = 2\n"},
+		{"missing value", "a =\n"},
+		{"unclosed string", "a = \"x\n"},
+		{"trailing text", "a = 1 2\n"},
+		{"leading zero", "a = 01\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runConvert(OperationRequest{Operation: "convert", Input: tt.input, Options: []byte(`{"from": "toml"}`)})
+			if err == nil || !strings.Contains(err.Error(), "line ") {
+				t.Errorf("error = %v, want one with a line number", err)
+			}
+		})
+	}
+}
+
+func TestWriteTOML(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		want     string
+		warnings []string
+	}{
+		{
+			name:  "tables and arrays of tables",
+			input: `{"title":"x","owner":{"name":"a"},"items":[{"id":1},{"id":2}]}`,
+			want:  "title = \"x\"\n\n[owner]\nname = \"a\"\n\n[[items]]\nid = 1\n\n[[items]]\nid = 2\n",
+		},
+		{
+			name:     "nulls are left out",
+			input:    `{"a":null,"b":[1,null]}`,
+			want:     "b = [1]\n",
+			warnings: []string{"/a", "/b/1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runForTest[ConvertResult](t, runConvert, tt.input, `{"to": "toml"}`)
+			if result.Output != tt.want {
+				t.Errorf("output =\n%s\nwant\n%s", result.Output, tt.want)
+			}
+			want := tt.warnings
+			if want == nil {
+				want = []string{}
+			}
+			if got := strings.Join(warningPointers(result.Warnings), ","); got != strings.Join(want, ",") {
+				t.Errorf("warnings = %+v, want %v", result.Warnings, want)
+			}
+		})
+	}
+
+	if _, err := runConvert(OperationRequest{Operation: "convert", Input: `[1]`, Options: []byte(`{"to": "toml"}`)}); err == nil {
+		t.Error("expected a non-table document to be rejected")
+	}
+}
+
+func TestTOMLRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{"scalars", `{"s":"a \"q\"\n","i":-7,"f":2.5,"b":true}`},
+		{"inline tables in arrays", `{"points":[{"x":1},2,"three"]}`},
+		{"nested tables", `{"a":{"d":[1,2],"b":{"c":1}},"e":{}}`},
+		{"arrays of tables", `{"products":[{"name":"a","tags":["x"]},{"name":"b","dims":{"w":1}}]}`},
+		{"keys that need quoting", `{"a b":1,"":2,"dotted.key":{"x.y":3}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			toml, got := roundTripForTest(t, tt.doc, formatTOML)
+			if got != tt.doc {
+				t.Errorf("round trip = %s, want %s\nTOML:\n%s", got, tt.doc, toml)
+			}
+		})
+	}
+}
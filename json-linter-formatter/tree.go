@@ -0,0 +1,318 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxTreeDepth guards the recursive parser against pathological nesting
+const maxTreeDepth = 10000
+
+// nodeKind identifies the JSON type of a parsed node
+type nodeKind int
+
+const (
+	nodeObject nodeKind = iota
+	nodeArray
+	nodeString
+	nodeNumber
+	nodeBool
+	nodeNull
+)
+
+// String returns the JSON type name of the kind
+func (k nodeKind) String() string {
+	switch k {
+	case nodeObject:
+		return "object"
+	case nodeArray:
+		return "array"
+	case nodeString:
+		return "string"
+	case nodeNumber:
+		return "number"
+	case nodeBool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+// node is a parsed JSON value that remembers where it came from. Object
+// members keep their source order, including duplicate keys, and scalars
+// keep their exact source text.
+type node struct {
+	Kind    nodeKind
+	Raw     string
+	Offset  int
+	Members []member
+	Items   []*node
+}
+
+// member is a single key/value pair of an object node
+type member struct {
+	Key       string
+	KeyRaw    string
+	KeyOffset int
+	Value     *node
+}
+
+// treeParser builds a node tree from a token stream
+type treeParser struct {
+	tok tokenSource
+}
+
+// parseTree parses src into a node tree, rejecting anything that is not a
+// single well-formed JSON document
+func parseTree(src string) (*node, error) {
+	return parseTokenTree(newTokenizer(src))
+}
+
+// parseTokenTree builds a node tree from any token source
+func parseTokenTree(ts tokenSource) (*node, error) {
+	p := &treeParser{tok: ts}
+
+	root, err := p.parseValue(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if extra, err := p.tok.next(); err != io.EOF {
+		if err != nil {
+			return nil, err
+		}
+		return nil, &scanError{Offset: extra.Offset, Msg: "unexpected data after top-level value"}
+	}
+	return root, nil
+}
+
+// expect consumes the next token and checks its kind
+func (p *treeParser) expect(kind tokenKind, what string) (token, error) {
+	t, err := p.tok.next()
+	if err == io.EOF {
+		return t, &scanError{Offset: t.Offset, Msg: "unexpected end of input, expected " + what}
+	}
+	if err != nil {
+		return t, err
+	}
+	if t.Kind != kind {
+		return t, &scanError{Offset: t.Offset, Msg: fmt.Sprintf("unexpected %s, expected %s", describeToken(t), what)}
+	}
+	return t, nil
+}
+
+// parseValue parses one value starting at the next token
+func (p *treeParser) parseValue(depth int) (*node, error) {
+	t, err := p.tok.next()
+	if err == io.EOF {
+		return nil, &scanError{Offset: t.Offset, Msg: "unexpected end of input, expected value"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if depth > maxTreeDepth {
+		return nil, &scanError{Offset: t.Offset, Msg: "document nested too deeply"}
+	}
+
+	switch t.Kind {
+	case tokenBeginObject:
+		return p.parseObject(t, depth)
+	case tokenBeginArray:
+		return p.parseArray(t, depth)
+	case tokenString:
+		return &node{Kind: nodeString, Raw: t.Text, Offset: t.Offset}, nil
+	case tokenNumber:
+		return &node{Kind: nodeNumber, Raw: t.Text, Offset: t.Offset}, nil
+	case tokenLiteral:
+		if t.Text == "null" {
+			return &node{Kind: nodeNull, Raw: t.Text, Offset: t.Offset}, nil
+		}
+		return &node{Kind: nodeBool, Raw: t.Text, Offset: t.Offset}, nil
+	default:
+		return nil, &scanError{Offset: t.Offset, Msg: fmt.Sprintf("unexpected %s, expected value", describeToken(t))}
+	}
+}
+
+// parseObject parses the members of an object after its opening brace
+func (p *treeParser) parseObject(open token, depth int) (*node, error) {
+	obj := &node{Kind: nodeObject, Offset: open.Offset}
+
+	if next, err := p.tok.peek(); err == nil && next.Kind == tokenEndObject {
+		p.tok.next()
+		return obj, nil
+	}
+
+	for {
+		keyTok, err := p.expect(tokenString, "string key")
+		if err != nil {
+			return nil, err
+		}
+		key, err := unquoteString(keyTok)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenColon, "':' after object key"); err != nil {
+			return nil, err
+		}
+
+		value, err := p.parseValue(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		obj.Members = append(obj.Members, member{Key: key, KeyRaw: keyTok.Text, KeyOffset: keyTok.Offset, Value: value})
+
+		sep, err := p.tok.next()
+		if err == io.EOF {
+			return nil, &scanError{Offset: sep.Offset, Msg: "unexpected end of input, expected ',' or '}'"}
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch sep.Kind {
+		case tokenComma:
+		case tokenEndObject:
+			return obj, nil
+		default:
+			return nil, &scanError{Offset: sep.Offset, Msg: fmt.Sprintf("unexpected %s, expected ',' or '}'", describeToken(sep))}
+		}
+	}
+}
+
+// parseArray parses the items of an array after its opening bracket
+func (p *treeParser) parseArray(open token, depth int) (*node, error) {
+	arr := &node{Kind: nodeArray, Offset: open.Offset}
+
+	if next, err := p.tok.peek(); err == nil && next.Kind == tokenEndArray {
+		p.tok.next()
+		return arr, nil
+	}
+
+	for {
+		item, err := p.parseValue(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		arr.Items = append(arr.Items, item)
+
+		sep, err := p.tok.next()
+		if err == io.EOF {
+			return nil, &scanError{Offset: sep.Offset, Msg: "unexpected end of input, expected ',' or ']'"}
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch sep.Kind {
+		case tokenComma:
+		case tokenEndArray:
+			return arr, nil
+		default:
+			return nil, &scanError{Offset: sep.Offset, Msg: fmt.Sprintf("unexpected %s, expected ',' or ']'", describeToken(sep))}
+		}
+	}
+}
+
+// describeToken names a token for use in error messages
+func describeToken(t token) string {
+	switch t.Kind {
+	case tokenString:
+		return "string " + t.Text
+	case tokenNumber:
+		return "number " + t.Text
+	case tokenLiteral:
+		return t.Text
+	default:
+		return "'" + t.Text + "'"
+	}
+}
+
+// unquoteString decodes the text of a string token
+func unquoteString(t token) (string, error) {
+	var s string
+	if err := json.Unmarshal([]byte(t.Text), &s); err != nil {
+		return "", &scanError{Offset: t.Offset, Msg: "invalid string escape"}
+	}
+	return s, nil
+}
+
+// walkTree calls visit for n and every node below it, passing each node's
+// JSON Pointer. Returning false from visit skips the node's children.
+func walkTree(n *node, path string, visit func(n *node, path string) bool) {
+	if !visit(n, path) {
+		return
+	}
+	switch n.Kind {
+	case nodeObject:
+		for _, m := range n.Members {
+			walkTree(m.Value, appendPointer(path, m.Key), visit)
+		}
+	case nodeArray:
+		for i, item := range n.Items {
+			walkTree(item, appendPointerIndex(path, i), visit)
+		}
+	}
+}
+
+// compact renders n as minified JSON. Scalars and keys keep their source
+// spelling.
+func (n *node) compact() string {
+	var b strings.Builder
+	n.writeCompact(&b)
+	return b.String()
+}
+
+func (n *node) writeCompact(b *strings.Builder) {
+	switch n.Kind {
+	case nodeObject:
+		b.WriteByte('{')
+		for i, m := range n.Members {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(m.KeyRaw)
+			b.WriteByte(':')
+			m.Value.writeCompact(b)
+		}
+		b.WriteByte('}')
+	case nodeArray:
+		b.WriteByte('[')
+		for i, item := range n.Items {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			item.writeCompact(b)
+		}
+		b.WriteByte(']')
+	default:
+		b.WriteString(n.Raw)
+	}
+}
+
+// value converts n to the generic form produced by decodeJSON. When an
+// object repeats a key, the last value wins.
+func (n *node) value() interface{} {
+	switch n.Kind {
+	case nodeObject:
+		obj := make(map[string]interface{}, len(n.Members))
+		for _, m := range n.Members {
+			obj[m.Key] = m.Value.value()
+		}
+		return obj
+	case nodeArray:
+		arr := make([]interface{}, len(n.Items))
+		for i, item := range n.Items {
+			arr[i] = item.value()
+		}
+		return arr
+	case nodeString:
+		s, _ := unquoteString(token{Text: n.Raw})
+		return s
+	case nodeNumber:
+		return json.Number(n.Raw)
+	case nodeBool:
+		return n.Raw == "true"
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseTreeKeepsNumbersAsWritten(t *testing.T) {
+	tests := []string{
+		`1.0`,
+		`1e3`,
+		`-0`,
+		`1E+02`,
+		`12345678901234567890`,
+		`0.10000000000000000000000001`,
+		`[9007199254740993, 1.50, 2e-308]`,
+		`{"a": 100000000000000000000000000000}`,
+	}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			root, err := parseTree(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := compactForTest(t, input)
+			if got := root.compact(); got != want {
+				t.Errorf("compact() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestNodeValueUsesJSONNumber(t *testing.T) {
+	root, err := parseTree(`{"big": 12345678901234567890, "float": 1.0}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := root.value().(map[string]interface{})
+	if got := obj["big"]; got != json.Number("12345678901234567890") {
+		t.Errorf("big = %#v", got)
+	}
+	if got := obj["float"]; got != json.Number("1.0") {
+		t.Errorf("float = %#v", got)
+	}
+}
+
+func TestParseTreeErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		offset int
+	}{
+		{`{"a": }`, 6},
+		{`[1, 2`, 5},
+		{`{"a" 1}`, 5},
+		{`[1] [2]`, 4},
+		{`{1: 2}`, 1},
+		{`[tru]`, 1},
+		{`[1e]`, 1},
+		{`["\q"]`, 2},
+		{`{"a": "\u00g0"}`, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := parseTree(tt.input)
+			scanErr, ok := err.(*scanError)
+			if !ok {
+				t.Fatalf("error = %v, want a scan error", err)
+			}
+			if scanErr.Offset != tt.offset {
+				t.Errorf("offset = %d, want %d (%v)", scanErr.Offset, tt.offset, err)
+			}
+		})
+	}
+}
+
+func compactForTest(t *testing.T, src string) string {
+	t.Helper()
+	out, err := formatTokens(src, formatStyle{Minify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
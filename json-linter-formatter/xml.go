@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// XML mapping conventions: members prefixed with "@" are attributes and
+// "#text" holds an element's text next to attributes or children
+const (
+	xmlAttributePrefix = "@"
+	xmlTextKey         = "#text"
+	xmlItemElement     = "item"
+)
+
+// validXMLName reports whether name can be used as an element or
+// attribute name
+func validXMLName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case unicode.IsLetter(r) || r == '_':
+		case i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// validXMLText reports whether s holds only characters XML can carry
+func validXMLText(s string) bool {
+	for _, r := range s {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0xFFFE || r == 0xFFFF {
+			return false
+		}
+	}
+	return true
+}
+
+// xmlEscape escapes text for element content and attribute values
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// writeXML renders the document as XML. An object with a single
+// non-array member becomes the root element; anything else is wrapped in
+// RootElement.
+func (c *conversion) writeXML(doc *node) (string, error) {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+
+	if doc.Kind == nodeObject && len(doc.Members) == 1 {
+		m := doc.Members[0]
+		if m.Value.Kind != nodeArray && validXMLName(m.Key) {
+			c.xmlElement(&b, m.Key, m.Value, "", appendPointer("", m.Key))
+			return b.String(), nil
+		}
+	}
+	c.xmlElement(&b, c.opts.RootElement, doc, "", "")
+	return b.String(), nil
+}
+
+// xmlElement writes n as the element name at indent
+func (c *conversion) xmlElement(b *strings.Builder, name string, n *node, indent, pointer string) {
+	b.WriteString(indent)
+	b.WriteString("<" + name)
+
+	var text *node
+	var children []member
+	switch n.Kind {
+	case nodeObject:
+		for _, m := range n.Members {
+			child := appendPointer(pointer, m.Key)
+			switch {
+			case m.Key == xmlTextKey:
+				text = m.Value
+			case strings.HasPrefix(m.Key, xmlAttributePrefix):
+				attr := strings.TrimPrefix(m.Key, xmlAttributePrefix)
+				switch {
+				case !validXMLName(attr):
+					c.warn(child, 0, "%q is not a valid XML attribute name; it was left out", attr)
+				case m.Value.Kind == nodeObject || m.Value.Kind == nodeArray:
+					c.warn(child, 0, "attribute %q cannot hold a %s; it was left out", attr, m.Value.Kind)
+				default:
+					fmt.Fprintf(b, " %s=\"%s\"", attr, xmlEscape(c.xmlText(m.Value, child)))
+				}
+			default:
+				children = append(children, m)
+			}
+		}
+	case nodeArray:
+		for _, item := range n.Items {
+			children = append(children, member{Key: xmlItemElement, Value: item})
+		}
+	default:
+		text = n
+	}
+
+	if text != nil && (text.Kind == nodeObject || text.Kind == nodeArray) {
+		c.warn(appendPointer(pointer, xmlTextKey), 0, "element text cannot be a %s; it was left out", text.Kind)
+		text = nil
+	}
+	if text != nil && text.Kind == nodeNull {
+		if n.Kind == nodeNull {
+			c.warn(pointer, 0, "null has no XML spelling; it was written as an empty element")
+		}
+		text = nil
+	}
+	if n.Kind == nodeString && scalarText(n) == "" {
+		c.warn(pointer, 0, "an empty string is written as an empty element and reads back as null")
+	}
+	if text == nil && len(children) == 0 {
+		b.WriteString("/>\n")
+		return
+	}
+	b.WriteByte('>')
+	if text != nil {
+		textPointer := pointer
+		if n.Kind == nodeObject {
+			textPointer = appendPointer(pointer, xmlTextKey)
+		}
+		b.WriteString(xmlEscape(c.xmlText(text, textPointer)))
+	}
+
+	if len(children) > 0 {
+		b.WriteByte('\n')
+		for i, m := range children {
+			child := appendPointer(pointer, m.Key)
+			if n.Kind == nodeArray {
+				child = appendPointerIndex(pointer, i)
+			}
+			if !validXMLName(m.Key) {
+				c.warn(child, 0, "%q is not a valid XML element name; it was left out", m.Key)
+				continue
+			}
+			if m.Value.Kind != nodeArray || n.Kind == nodeArray {
+				c.xmlElement(b, m.Key, m.Value, indent+"  ", child)
+				continue
+			}
+			switch len(m.Value.Items) {
+			case 0:
+				c.warn(child, 0, "an empty array has no XML elements; it was left out")
+			case 1:
+				c.warn(child, 0, "a one-item array is written as a single element and reads back as a single value")
+			}
+			for j, item := range m.Value.Items {
+				if item.Kind == nodeArray {
+					c.warn(appendPointerIndex(child, j), 0, "nested arrays cannot be represented in XML; the item was written as an <item> list")
+				}
+				c.xmlElement(b, m.Key, item, indent+"  ", appendPointerIndex(child, j))
+			}
+		}
+		b.WriteString(indent)
+	}
+	b.WriteString("</" + name + ">\n")
+}
+
+// xmlText returns the text of a scalar, reporting characters XML cannot
+// carry
+func (c *conversion) xmlText(n *node, pointer string) string {
+	s := scalarText(n)
+	if !validXMLText(s) {
+		c.warn(pointer, 0, "control characters cannot be represented in XML; they were replaced")
+	}
+	return s
+}
+
+// xmlFrame is an element being read
+type xmlFrame struct {
+	name     string
+	line     int
+	obj      *node
+	text     strings.Builder
+	children bool
+	// repeated records child names already collected into arrays
+	repeated map[string]bool
+}
+
+// readXML parses XML into the JSON document model. Attributes become
+// "@name" members, repeated child elements become arrays and text next to
+// attributes or children is kept under "#text".
+func (c *conversion) readXML(input string) (*node, error) {
+	dec := xml.NewDecoder(strings.NewReader(input))
+	var stack []*xmlFrame
+	var root *node
+
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := dec.InputPos()
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 && root != nil {
+				return nil, fmt.Errorf("line %d: a second root element <%s> is not allowed", line, t.Name.Local)
+			}
+			frame := &xmlFrame{name: t.Name.Local, line: line, obj: &node{Kind: nodeObject}, repeated: make(map[string]bool)}
+			for _, attr := range t.Attr {
+				name := attr.Name.Local
+				if attr.Name.Space == "xmlns" {
+					name = "xmlns:" + name
+				}
+				setMember(frame.obj, xmlAttributePrefix+name, c.xmlScalar(attr.Value))
+			}
+			if len(stack) > 0 {
+				stack[len(stack)-1].children = true
+			}
+			stack = append(stack, frame)
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			}
+		case xml.EndElement:
+			frame := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			value := c.xmlValue(frame)
+			if len(stack) == 0 {
+				root = &node{Kind: nodeObject}
+				setMember(root, frame.name, value)
+				continue
+			}
+			addXMLChild(stack[len(stack)-1], frame.name, value)
+		}
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("no root element")
+	}
+	return root, nil
+}
+
+// xmlValue builds the value of a finished element
+func (c *conversion) xmlValue(frame *xmlFrame) *node {
+	text := strings.TrimSpace(frame.text.String())
+	if len(frame.obj.Members) == 0 {
+		if text == "" && !c.opts.KeepStrings {
+			return nullNode()
+		}
+		return c.xmlScalar(text)
+	}
+	if text != "" {
+		if frame.children {
+			c.warn("", frame.line, "<%s> mixes text with child elements; the text was joined under %q", frame.name, xmlTextKey)
+		}
+		setMember(frame.obj, xmlTextKey, c.xmlScalar(text))
+	}
+	return frame.obj
+}
+
+// xmlScalar reads element text or an attribute value
+func (c *conversion) xmlScalar(text string) *node {
+	if c.opts.KeepStrings {
+		return stringNode(text)
+	}
+	return inferScalar(text)
+}
+
+// addXMLChild adds a child element's value to its parent, collecting
+// repeated names into an array
+func addXMLChild(parent *xmlFrame, name string, value *node) {
+	index := memberIndex(parent.obj, name)
+	switch {
+	case index < 0:
+		setMember(parent.obj, name, value)
+	case parent.repeated[name]:
+		list := parent.obj.Members[index].Value
+		list.Items = append(list.Items, value)
+	default:
+		parent.repeated[name] = true
+		first := parent.obj.Members[index].Value
+		parent.obj.Members[index].Value = &node{Kind: nodeArray, Items: []*node{first, value}}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteXML(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		want     string
+		warnings []string
+	}{
+		{
+			name:  "single member becomes the root",
+			input: `{"note": {"@id": 7, "to": "a", "#text": "hi"}}`,
+			want:  "<note id=\"7\">hi\n  <to>a</to>\n</note>\n",
+		},
+		{
+			name:  "other documents are wrapped",
+			input: `{"a": 1, "b": "x<y"}`,
+			want:  "<root>\n  <a>1</a>\n  <b>x&lt;y</b>\n</root>\n",
+		},
+		{
+			name:  "arrays repeat the element",
+			input: `{"list": {"v": [1, 2]}}`,
+			want:  "<list>\n  <v>1</v>\n  <v>2</v>\n</list>\n",
+		},
+		{
+			name:     "empty arrays are reported",
+			input:    `{"list": {"v": [], "w": 1}}`,
+			want:     "<list>\n  <w>1</w>\n</list>\n",
+			warnings: []string{"/list/v"},
+		},
+		{
+			name:     "one-item arrays are reported",
+			input:    `{"list": {"v": [1]}}`,
+			want:     "<list>\n  <v>1</v>\n</list>\n",
+			warnings: []string{"/list/v"},
+		},
+		{
+			name:     "nulls and empty strings are reported",
+			input:    `{"list": {"v": null, "w": ""}}`,
+			want:     "<list>\n  <v/>\n  <w></w>\n</list>\n",
+			warnings: []string{"/list/v", "/list/w"},
+		},
+		{
+			name:     "invalid names are left out",
+			input:    `{"list": {"1x": 1, "@a b": 2, "@c": [1]}}`,
+			want:     "<list>\n</list>\n",
+			warnings: []string{"/list/@a b", "/list/@c", "/list/1x"},
+		},
+		{
+			name:     "nested arrays",
+			input:    `{"list": {"v": [[1], 2]}}`,
+			want:     "<list>\n  <v>\n    <item>1</item>\n  </v>\n  <v>2</v>\n</list>\n",
+			warnings: []string{"/list/v/0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runForTest[ConvertResult](t, runConvert, tt.input, `{"to": "xml"}`)
+			if got := strings.TrimPrefix(result.Output, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"); got != tt.want {
+				t.Errorf("output =\n%s\nwant\n%s", got, tt.want)
+			}
+			want := tt.warnings
+			if want == nil {
+				want = []string{}
+			}
+			if got := warningPointers(result.Warnings); !reflect.DeepEqual(got, want) {
+				t.Errorf("warnings = %v (%+v), want %v", got, result.Warnings, want)
+			}
+		})
+	}
+}
+
+func TestReadXML(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		options string
+		want    string
+	}{
+		{
+			name:  "attributes, text and repeated children",
+			input: `<a id="1"><b>x</b><b>2</b><c/></a>`,
+			want:  `{"a":{"@id":1,"b":["x",2],"c":null}}`,
+		},
+		{
+			name:    "keep strings",
+			input:   `<a><b>1</b><c/></a>`,
+			options: `"keepStrings": true`,
+			want:    `{"a":{"b":"1","c":""}}`,
+		},
+		{
+			name:  "text next to attributes",
+			input: `<a lang="en">hi</a>`,
+			want:  `{"a":{"@lang":"en","#text":"hi"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := `{"from": "xml"}`
+			if tt.options != "" {
+				options = `{"from": "xml", ` + tt.options + `}`
+			}
+			result := runForTest[ConvertResult](t, runConvert, tt.input, options)
+			if got := compactForTest(t, result.Output); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadXMLErrors(t *testing.T) {
+	for _, input := range []string{``, `<a>`, `<a/><b/>`} {
+		if _, err := runConvert(OperationRequest{Operation: "convert", Input: input, Options: []byte(`{"from": "xml"}`)}); err == nil {
+			t.Errorf("expected %q to be rejected", input)
+		}
+	}
+}
+
+func TestXMLRoundTrip(t *testing.T) {
+	doc := `{"order":{"@id":7,"customer":"ann","lines":[{"sku":"a","qty":2},{"sku":"b","qty":1}],"note":"x & y"}}`
+	_, got := roundTripForTest(t, doc, formatXML)
+	if got != doc {
+		t.Errorf("round trip = %s, want %s", got, doc)
+	}
+}
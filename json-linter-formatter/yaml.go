@@ -0,0 +1,895 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// YAML 1.2 core schema scalars
+var (
+	yamlInt      = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlOctal    = regexp.MustCompile(`^0o[0-7]+$`)
+	yamlHex      = regexp.MustCompile(`^0x[0-9a-fA-F]+$`)
+	yamlFloat    = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+	yamlSpecial  = regexp.MustCompile(`^([-+]?\.(inf|Inf|INF)|\.(nan|NaN|NAN))$`)
+	yamlNumberly = regexp.MustCompile(`^[-+.]?[0-9][0-9a-zA-Z_.:+-]*$`)
+)
+
+// yamlAmbiguous are plain scalars that YAML 1.1 parsers, still common in
+// Kubernetes tooling, read as booleans or nulls
+var yamlAmbiguous = map[string]bool{
+	"y": true, "Y": true, "yes": true, "Yes": true, "YES": true,
+	"n": true, "N": true, "no": true, "No": true, "NO": true,
+	"on": true, "On": true, "ON": true, "off": true, "Off": true, "OFF": true,
+	"true": true, "True": true, "TRUE": true, "false": true, "False": true, "FALSE": true,
+	"null": true, "Null": true, "NULL": true, "~": true,
+}
+
+// yamlEscapes are the single-character escapes of double-quoted scalars
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n",
+	'v': "\v", 'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"",
+	'/': "/", '\\': "\\", 'N': "\u0085", '_': "\u00a0", 'L': "\u2028", 'P': "\u2029",
+}
+
+// writeYAML renders the document as block-style YAML
+func (c *conversion) writeYAML(doc *node) string {
+	var b strings.Builder
+	c.yamlNode(&b, doc, "", "", false)
+	return b.String()
+}
+
+// yamlNode writes n at indent. When inline is set the first line follows
+// a "- " already written on the current line.
+func (c *conversion) yamlNode(b *strings.Builder, n *node, indent, pointer string, inline bool) {
+	switch {
+	case n.Kind == nodeObject && len(n.Members) > 0:
+		seen := make(map[string]bool, len(n.Members))
+		for i, m := range n.Members {
+			child := appendPointer(pointer, m.Key)
+			if seen[m.Key] {
+				c.warn(child, 0, "duplicate key %q is not allowed in YAML", m.Key)
+			}
+			seen[m.Key] = true
+			if i > 0 || !inline {
+				b.WriteString(indent)
+			}
+			b.WriteString(yamlString(m.Key, "", false))
+			b.WriteByte(':')
+			if isNonEmptyContainer(m.Value) {
+				b.WriteByte('\n')
+				c.yamlNode(b, m.Value, indent+"  ", child, false)
+			} else {
+				b.WriteByte(' ')
+				writeYAMLScalar(b, m.Value, indent+"  ")
+			}
+		}
+	case n.Kind == nodeArray && len(n.Items) > 0:
+		for i, item := range n.Items {
+			if i > 0 || !inline {
+				b.WriteString(indent)
+			}
+			b.WriteString("- ")
+			if isNonEmptyContainer(item) {
+				c.yamlNode(b, item, indent+"  ", appendPointerIndex(pointer, i), true)
+			} else {
+				writeYAMLScalar(b, item, indent+"  ")
+			}
+		}
+	default:
+		writeYAMLScalar(b, n, indent)
+	}
+}
+
+// isNonEmptyContainer reports whether n is an object or array with content
+func isNonEmptyContainer(n *node) bool {
+	return (n.Kind == nodeObject && len(n.Members) > 0) || (n.Kind == nodeArray && len(n.Items) > 0)
+}
+
+// writeYAMLScalar writes a scalar or empty container and ends the line.
+// Block scalars continue on following lines at indent.
+func writeYAMLScalar(b *strings.Builder, n *node, indent string) {
+	switch n.Kind {
+	case nodeObject:
+		b.WriteString("{}")
+	case nodeArray:
+		b.WriteString("[]")
+	case nodeString:
+		b.WriteString(yamlString(scalarText(n), indent, true))
+	default:
+		b.WriteString(n.Raw)
+	}
+	b.WriteByte('\n')
+}
+
+// yamlString spells s as a plain, literal block or double-quoted scalar
+func yamlString(s, indent string, allowBlock bool) string {
+	if yamlPlainSafe(s) {
+		return s
+	}
+	if allowBlock {
+		if block, ok := yamlLiteral(s, indent); ok {
+			return block
+		}
+	}
+	return jsonQuote(s)
+}
+
+// yamlPlainSafe reports whether s reads back as the same string when
+// written unquoted
+func yamlPlainSafe(s string) bool {
+	if s == "" || s != strings.TrimSpace(s) || yamlAmbiguous[s] || yamlNumberly.MatchString(s) || yamlSpecial.MatchString(s) {
+		return false
+	}
+	if strings.ContainsAny(s[:1], ",[]{}#&*!|>'\"%@`") {
+		return false
+	}
+	if strings.ContainsAny(s[:1], "-?:") && (len(s) == 1 || s[1] == ' ') {
+		return false
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// yamlLiteral writes a multi-line string as a literal block scalar
+func yamlLiteral(s, indent string) (string, bool) {
+	body := strings.TrimRight(s, "\n")
+	if !strings.Contains(body, "\n") || strings.HasPrefix(body, " ") || strings.Contains(s, "\r") {
+		return "", false
+	}
+	for _, r := range body {
+		if r != '\n' && r != '\t' && !unicode.IsPrint(r) {
+			return "", false
+		}
+	}
+
+	header := "|"
+	switch trailing := len(s) - len(body); {
+	case trailing == 0:
+		header = "|-"
+	case trailing > 1:
+		header = "|+"
+		body = s[:len(s)-1]
+	}
+
+	var b strings.Builder
+	b.WriteString(header)
+	for _, line := range strings.Split(body, "\n") {
+		b.WriteByte('\n')
+		if line != "" {
+			b.WriteString(indent)
+			b.WriteString(line)
+		}
+	}
+	return b.String(), true
+}
+
+// yamlLine is one physical line of YAML input
+type yamlLine struct {
+	num    int
+	indent int
+	raw    string
+	// text is the line without its indentation
+	text string
+}
+
+// blank reports whether the line holds nothing but a comment
+func (l yamlLine) blank() bool {
+	t := strings.TrimSpace(l.text)
+	return t == "" || strings.HasPrefix(t, "#")
+}
+
+// checkIndent rejects tabs used for indentation
+func (l yamlLine) checkIndent() error {
+	if strings.HasPrefix(l.text, "\t") {
+		return fmt.Errorf("line %d: tabs are not allowed in indentation", l.num)
+	}
+	return nil
+}
+
+// yamlParser reads block-structured YAML line by line
+type yamlParser struct {
+	c       *conversion
+	lines   []yamlLine
+	pos     int
+	anchors map[string]*node
+}
+
+// readYAML parses YAML into the JSON document model. Several documents
+// become one array.
+func (c *conversion) readYAML(input string) (*node, error) {
+	var docs [][]yamlLine
+	var current []yamlLine
+	started := false
+	for i, raw := range strings.Split(input, "\n") {
+		raw = strings.TrimSuffix(raw, "\r")
+		text := strings.TrimLeft(raw, " ")
+		line := yamlLine{num: i + 1, indent: len(raw) - len(text), raw: raw, text: text}
+
+		switch {
+		case line.indent == 0 && (text == "---" || strings.HasPrefix(text, "--- ")):
+			if started || len(current) > 0 {
+				docs = append(docs, current)
+			}
+			current, started = nil, true
+			if rest := strings.TrimSpace(text[3:]); rest != "" {
+				current = append(current, yamlLine{num: line.num, raw: rest, text: rest})
+			}
+			continue
+		case line.indent == 0 && text == "...":
+			docs = append(docs, current)
+			current, started = nil, false
+			continue
+		case line.indent == 0 && strings.HasPrefix(text, "%") && !started && len(current) == 0:
+			continue
+		}
+		current = append(current, line)
+	}
+	if started || len(docs) == 0 || len(current) > 0 {
+		docs = append(docs, current)
+	}
+
+	var values []*node
+	for _, lines := range docs {
+		p := &yamlParser{c: c, lines: lines, anchors: make(map[string]*node)}
+		if p.peek() == nil && len(docs) > 1 {
+			continue
+		}
+		value, err := p.parseBlock(-1)
+		if err != nil {
+			return nil, err
+		}
+		if line := p.peek(); line != nil {
+			return nil, fmt.Errorf("line %d: unexpected %q (check the indentation)", line.num, strings.TrimSpace(line.text))
+		}
+		values = append(values, value)
+	}
+
+	if len(values) == 1 {
+		return values[0], nil
+	}
+	c.warn("", 0, "%d YAML documents were combined into one array", len(values))
+	return &node{Kind: nodeArray, Items: values}, nil
+}
+
+// peek skips blank lines and returns the next line, or nil at the end
+func (p *yamlParser) peek() *yamlLine {
+	for p.pos < len(p.lines) && p.lines[p.pos].blank() {
+		p.pos++
+	}
+	if p.pos == len(p.lines) {
+		return nil
+	}
+	return &p.lines[p.pos]
+}
+
+// parseBlock parses the node starting on the next line, which belongs to
+// the parent when it is not indented past parentIndent
+func (p *yamlParser) parseBlock(parentIndent int) (*node, error) {
+	line := p.peek()
+	if line == nil || line.indent <= parentIndent {
+		return nullNode(), nil
+	}
+	if err := line.checkIndent(); err != nil {
+		return nil, err
+	}
+	if isYAMLSequenceEntry(line.text) {
+		return p.parseSequence(line.indent)
+	}
+	if strings.HasPrefix(line.text, "? ") {
+		return nil, fmt.Errorf("line %d: complex mapping keys are not supported", line.num)
+	}
+	if _, _, ok := splitYAMLKey(line.text); ok {
+		return p.parseMapping(line.indent)
+	}
+	p.pos++
+	return p.parseValue(line.text, *line, parentIndent)
+}
+
+// isYAMLSequenceEntry reports whether text starts a block sequence entry
+func isYAMLSequenceEntry(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ") || strings.HasPrefix(text, "-\t")
+}
+
+// parseSequence parses the block sequence whose entries sit at indent
+func (p *yamlParser) parseSequence(indent int) (*node, error) {
+	seq := &node{Kind: nodeArray}
+	for {
+		line := p.peek()
+		if line == nil || line.indent != indent || !isYAMLSequenceEntry(line.text) {
+			return seq, nil
+		}
+		if err := line.checkIndent(); err != nil {
+			return nil, err
+		}
+
+		rest := strings.TrimLeft(line.text[1:], " \t")
+		var item *node
+		var err error
+		if stripYAMLComment(rest) == "" {
+			p.pos++
+			item, err = p.parseBlock(indent)
+		} else {
+			// Treat the entry's content as a line of its own so that
+			// "- key: value" continues as a mapping on the lines below.
+			line.indent += len(line.text) - len(rest)
+			line.text = rest
+			item, err = p.parseBlock(indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		seq.Items = append(seq.Items, item)
+	}
+}
+
+// parseMapping parses the block mapping whose keys sit at indent
+func (p *yamlParser) parseMapping(indent int) (*node, error) {
+	obj := &node{Kind: nodeObject}
+	explicit := make(map[string]bool)
+	for {
+		line := p.peek()
+		if line == nil || line.indent != indent || isYAMLSequenceEntry(line.text) {
+			return obj, nil
+		}
+		if err := line.checkIndent(); err != nil {
+			return nil, err
+		}
+		key, rest, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected a mapping key, found %q", line.num, strings.TrimSpace(line.text))
+		}
+		num := line.num
+		p.pos++
+
+		var value *node
+		var err error
+		if next := p.peek(); stripYAMLComment(rest) == "" && next != nil && next.indent == indent && isYAMLSequenceEntry(next.text) {
+			value, err = p.parseSequence(indent)
+		} else {
+			value, err = p.parseValue(rest, yamlLine{num: num, indent: indent}, indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if key == "<<" {
+			p.merge(obj, value, num)
+			continue
+		}
+		if explicit[key] {
+			p.c.warn("", num, "duplicate key %q; the last value is used", key)
+		}
+		explicit[key] = true
+		setMember(obj, key, value)
+	}
+}
+
+// merge applies a "<<" merge key, adding members the mapping lacks
+func (p *yamlParser) merge(obj, value *node, line int) {
+	sources := []*node{value}
+	if value.Kind == nodeArray {
+		sources = value.Items
+	}
+	for _, source := range sources {
+		if source.Kind != nodeObject {
+			p.c.warn("", line, "merge key value is a %s, not a mapping; ignored", source.Kind)
+			continue
+		}
+		for _, m := range source.Members {
+			if memberIndex(obj, m.Key) < 0 {
+				setMember(obj, m.Key, cloneNode(m.Value))
+			}
+		}
+	}
+}
+
+// splitYAMLKey splits a "key: value" line. It reports false when the line
+// is not a mapping entry.
+func splitYAMLKey(text string) (string, string, bool) {
+	if text == "" || strings.ContainsAny(text[:1], "[{&*!|>#%@`") {
+		return "", "", false
+	}
+
+	if text[0] == '"' || text[0] == '\'' {
+		end := yamlQuoteEnd(text)
+		if end < 0 {
+			return "", "", false
+		}
+		after := strings.TrimLeft(text[end:], " ")
+		if !strings.HasPrefix(after, ":") || (len(after) > 1 && after[1] != ' ' && after[1] != '\t') {
+			return "", "", false
+		}
+		key, err := unquoteYAML(text[:end])
+		if err != nil {
+			return "", "", false
+		}
+		return key, after[1:], true
+	}
+
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '#' && i > 0 && (text[i-1] == ' ' || text[i-1] == '\t'):
+			return "", "", false
+		case text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ' || text[i+1] == '\t'):
+			return strings.TrimSpace(text[:i]), text[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// yamlQuoteEnd returns the index just past the quoted scalar opening text,
+// or -1 when it is not closed on this line
+func yamlQuoteEnd(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// stripYAMLComment removes a trailing comment and surrounding spaces.
+// Quotes only count when they open a scalar.
+func stripYAMLComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		switch {
+		case quote == '"' && ch == '\\':
+			i++
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			prev := strings.TrimRight(text[:i], " \t")
+			if prev == "" || strings.ContainsAny(prev[len(prev)-1:], ":-[{,?") {
+				quote = ch
+			}
+		case ch == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t'):
+			return strings.TrimSpace(text[:i])
+		}
+	}
+	return strings.TrimSpace(text)
+}
+
+// parseValue parses the value written after a key or sequence indicator.
+// An empty value continues on the lines indented past parentIndent.
+func (p *yamlParser) parseValue(rest string, line yamlLine, parentIndent int) (*node, error) {
+	text := stripYAMLComment(rest)
+
+	anchor, tag := "", ""
+	for text != "" && (text[0] == '&' || text[0] == '!') {
+		end := strings.IndexAny(text, " \t")
+		if end < 0 {
+			end = len(text)
+		}
+		if text[0] == '&' {
+			anchor = text[1:end]
+		} else {
+			tag = text[:end]
+		}
+		text = strings.TrimSpace(text[end:])
+	}
+	switch tag {
+	case "", "!!str", "!!int", "!!float", "!!bool", "!!null", "!!map", "!!seq":
+	default:
+		p.c.warn("", line.num, "tag %s is not supported and was ignored", tag)
+	}
+
+	var value *node
+	var err error
+	switch {
+	case text == "":
+		value, err = p.parseBlock(parentIndent)
+	case text[0] == '*':
+		target, ok := p.anchors[text[1:]]
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown alias %q", line.num, text)
+		}
+		value = cloneNode(target)
+	case text[0] == '|' || text[0] == '>':
+		value, err = p.parseBlockScalar(text, line, parentIndent)
+	case text[0] == '[' || text[0] == '{':
+		value, err = p.parseFlowLines(text, line, parentIndent)
+	case text[0] == '"' || text[0] == '\'':
+		value, err = p.parseQuotedLines(text, line, parentIndent)
+	default:
+		text, err = p.continuePlain(text, parentIndent)
+		value = p.resolvePlain(text, line.num, tag)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if anchor != "" {
+		p.anchors[anchor] = value
+	}
+	return value, nil
+}
+
+// continuePlain appends the continuation lines of a multi-line plain
+// scalar, folding line breaks into spaces
+func (p *yamlParser) continuePlain(text string, parentIndent int) (string, error) {
+	for {
+		line := p.peek()
+		if line == nil || line.indent <= parentIndent {
+			return text, nil
+		}
+		if _, _, ok := splitYAMLKey(line.text); ok {
+			return "", fmt.Errorf("line %d: mapping key %q is not allowed inside a plain scalar (check the indentation)", line.num, strings.TrimSpace(line.text))
+		}
+		text += " " + stripYAMLComment(line.text)
+		p.pos++
+	}
+}
+
+// continueUntil joins following lines to text until complete reports true
+func (p *yamlParser) continueUntil(text string, line yamlLine, parentIndent int, complete func(string) bool, what string) (string, error) {
+	for !complete(text) {
+		if p.pos == len(p.lines) || (!p.lines[p.pos].blank() && p.lines[p.pos].indent <= parentIndent) {
+			return "", fmt.Errorf("line %d: unterminated %s", line.num, what)
+		}
+		text += " " + strings.TrimSpace(p.lines[p.pos].text)
+		p.pos++
+	}
+	return text, nil
+}
+
+// parseQuotedLines parses a quoted scalar that may span several lines
+func (p *yamlParser) parseQuotedLines(text string, line yamlLine, parentIndent int) (*node, error) {
+	text, err := p.continueUntil(text, line, parentIndent, func(s string) bool { return yamlQuoteEnd(s) > 0 }, "quoted string")
+	if err != nil {
+		return nil, err
+	}
+	end := yamlQuoteEnd(text)
+	if trailing := stripYAMLComment(text[end:]); trailing != "" {
+		return nil, fmt.Errorf("line %d: unexpected %q after quoted string", line.num, trailing)
+	}
+	s, err := unquoteYAML(text[:end])
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", line.num, err)
+	}
+	return stringNode(s), nil
+}
+
+// parseFlowLines parses a flow collection that may span several lines
+func (p *yamlParser) parseFlowLines(text string, line yamlLine, parentIndent int) (*node, error) {
+	text, err := p.continueUntil(text, line, parentIndent, flowClosed, "flow collection")
+	if err != nil {
+		return nil, err
+	}
+	f := &yamlFlow{p: p, src: text, line: line.num}
+	value, err := f.value()
+	if err != nil {
+		return nil, err
+	}
+	f.skipSpace()
+	if f.pos < len(f.src) {
+		return nil, fmt.Errorf("line %d: unexpected %q after flow collection", line.num, f.src[f.pos:])
+	}
+	return value, nil
+}
+
+// flowClosed reports whether every bracket opened in text is closed
+func flowClosed(text string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		switch {
+		case quote == '"' && ch == '\\':
+			i++
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '[' || ch == '{':
+			depth++
+		case ch == ']' || ch == '}':
+			depth--
+		}
+	}
+	return depth <= 0 && quote == 0
+}
+
+// parseBlockScalar reads a literal (|) or folded (>) block scalar
+func (p *yamlParser) parseBlockScalar(header string, line yamlLine, parentIndent int) (*node, error) {
+	folded := header[0] == '>'
+	chomp := byte(0)
+	indent := -1
+	for _, ch := range header[1:] {
+		switch {
+		case ch == '-' || ch == '+':
+			chomp = byte(ch)
+		case ch >= '1' && ch <= '9':
+			indent = max(parentIndent, 0) + int(ch-'0')
+		default:
+			return nil, fmt.Errorf("line %d: invalid block scalar header %q", line.num, header)
+		}
+	}
+
+	var lines []string
+	trailing := 0
+	for ; p.pos < len(p.lines); p.pos++ {
+		raw := p.lines[p.pos].raw
+		if strings.TrimSpace(raw) == "" {
+			lines = append(lines, "")
+			trailing++
+			continue
+		}
+		lineIndent := p.lines[p.pos].indent
+		if indent < 0 {
+			if lineIndent <= parentIndent {
+				break
+			}
+			indent = lineIndent
+		}
+		if lineIndent < indent {
+			break
+		}
+		lines = append(lines, raw[indent:])
+		trailing = 0
+	}
+	lines = lines[:len(lines)-trailing]
+
+	var b strings.Builder
+	if folded {
+		breaks := 0
+		for i, l := range lines {
+			if l == "" {
+				breaks++
+				continue
+			}
+			b.WriteString(strings.Repeat("\n", breaks))
+			if i > 0 && breaks == 0 {
+				if strings.HasPrefix(l, " ") || strings.HasPrefix(lines[i-1], " ") {
+					b.WriteByte('\n')
+				} else {
+					b.WriteByte(' ')
+				}
+			}
+			b.WriteString(l)
+			breaks = 0
+		}
+	} else {
+		b.WriteString(strings.Join(lines, "\n"))
+	}
+
+	s := b.String()
+	switch {
+	case chomp == '-' || s == "":
+	case chomp == '+':
+		s += strings.Repeat("\n", trailing+1)
+	default:
+		s += "\n"
+	}
+	return stringNode(s), nil
+}
+
+// resolvePlain reads a plain scalar with the YAML 1.2 core schema
+func (p *yamlParser) resolvePlain(text string, line int, tag string) *node {
+	if tag == "!!str" {
+		return stringNode(text)
+	}
+	switch text {
+	case "", "~", "null", "Null", "NULL":
+		return nullNode()
+	case "true", "True", "TRUE":
+		return boolNode(true)
+	case "false", "False", "FALSE":
+		return boolNode(false)
+	}
+
+	base := 0
+	switch {
+	case yamlInt.MatchString(text):
+		base = 10
+	case yamlOctal.MatchString(text):
+		base, text = 8, text[2:]
+	case yamlHex.MatchString(text):
+		base, text = 16, text[2:]
+	}
+	if base != 0 {
+		value, _ := new(big.Int).SetString(text, base)
+		return &node{Kind: nodeNumber, Raw: value.String()}
+	}
+
+	if yamlFloat.MatchString(text) {
+		if strict, err := strictNumber(text); err == nil {
+			return &node{Kind: nodeNumber, Raw: strict}
+		}
+	}
+	if yamlSpecial.MatchString(text) {
+		p.c.warn("", line, "%s cannot be represented in JSON; kept as a string", text)
+	}
+	return stringNode(text)
+}
+
+// unquoteYAML decodes a single- or double-quoted YAML scalar
+func unquoteYAML(text string) (string, error) {
+	if text[0] == '\'' {
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	}
+
+	body := text[1 : len(text)-1]
+	var b strings.Builder
+	for i := 0; i < len(body); i++ {
+		if body[i] != '\\' {
+			b.WriteByte(body[i])
+			continue
+		}
+		i++
+		if i == len(body) {
+			return "", fmt.Errorf("invalid escape at end of string")
+		}
+		if s, ok := yamlEscapes[body[i]]; ok {
+			b.WriteString(s)
+			continue
+		}
+		digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[body[i]]
+		if digits == 0 || i+digits >= len(body) {
+			return "", fmt.Errorf("invalid escape \\%c", body[i])
+		}
+		code, err := strconv.ParseUint(body[i+1:i+1+digits], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return "", fmt.Errorf("invalid escape \\%s", body[i:i+1+digits])
+		}
+		b.WriteRune(rune(code))
+		i += digits
+	}
+	return b.String(), nil
+}
+
+// yamlFlow parses a flow collection held on one logical line
+type yamlFlow struct {
+	p    *yamlParser
+	src  string
+	pos  int
+	line int
+}
+
+func (f *yamlFlow) skipSpace() {
+	for f.pos < len(f.src) && (f.src[f.pos] == ' ' || f.src[f.pos] == '\t') {
+		f.pos++
+	}
+}
+
+func (f *yamlFlow) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", f.line, fmt.Sprintf(format, args...))
+}
+
+// value parses one flow node
+func (f *yamlFlow) value() (*node, error) {
+	f.skipSpace()
+	if f.pos == len(f.src) {
+		return nil, f.errorf("unexpected end of flow collection")
+	}
+	switch f.src[f.pos] {
+	case '[':
+		return f.sequence()
+	case '{':
+		return f.mapping()
+	case '"', '\'':
+		end := yamlQuoteEnd(f.src[f.pos:])
+		if end < 0 {
+			return nil, f.errorf("unterminated quoted string")
+		}
+		s, err := unquoteYAML(f.src[f.pos : f.pos+end])
+		if err != nil {
+			return nil, f.errorf("%v", err)
+		}
+		f.pos += end
+		return stringNode(s), nil
+	case '*':
+		start := f.pos + 1
+		for f.pos < len(f.src) && !strings.ContainsRune(" ,]}", rune(f.src[f.pos])) {
+			f.pos++
+		}
+		target, ok := f.p.anchors[f.src[start:f.pos]]
+		if !ok {
+			return nil, f.errorf("unknown alias %q", f.src[start-1:f.pos])
+		}
+		return cloneNode(target), nil
+	}
+
+	start := f.pos
+	for f.pos < len(f.src) {
+		ch := f.src[f.pos]
+		if ch == ',' || ch == ']' || ch == '}' {
+			break
+		}
+		if ch == ':' && (f.pos+1 == len(f.src) || strings.ContainsRune(" ,]}", rune(f.src[f.pos+1]))) {
+			break
+		}
+		f.pos++
+	}
+	return f.p.resolvePlain(strings.TrimSpace(f.src[start:f.pos]), f.line, ""), nil
+}
+
+// sequence parses [a, b, ...]
+func (f *yamlFlow) sequence() (*node, error) {
+	seq := &node{Kind: nodeArray}
+	f.pos++
+	for {
+		f.skipSpace()
+		if f.pos < len(f.src) && f.src[f.pos] == ']' {
+			f.pos++
+			return seq, nil
+		}
+		item, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		seq.Items = append(seq.Items, item)
+		if err := f.separator(']'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// mapping parses {a: 1, b: 2, ...}
+func (f *yamlFlow) mapping() (*node, error) {
+	obj := &node{Kind: nodeObject}
+	f.pos++
+	for {
+		f.skipSpace()
+		if f.pos < len(f.src) && f.src[f.pos] == '}' {
+			f.pos++
+			return obj, nil
+		}
+		key, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		if key.Kind == nodeObject || key.Kind == nodeArray {
+			return nil, f.errorf("complex mapping keys are not supported")
+		}
+		value := nullNode()
+		f.skipSpace()
+		if f.pos < len(f.src) && f.src[f.pos] == ':' {
+			f.pos++
+			if value, err = f.value(); err != nil {
+				return nil, err
+			}
+		}
+		setMember(obj, scalarText(key), value)
+		if err := f.separator('}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// separator consumes the comma after an entry, leaving a closing bracket
+func (f *yamlFlow) separator(closer byte) error {
+	f.skipSpace()
+	switch {
+	case f.pos == len(f.src):
+		return f.errorf("missing %q", closer)
+	case f.src[f.pos] == ',':
+		f.pos++
+	case f.src[f.pos] != closer:
+		return f.errorf("expected ',' or %q, found %q", closer, f.src[f.pos])
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadYAML(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		want     string
+		warnings int
+	}{
+		{
+			name:  "mappings and sequences",
+			input: "name: demo\ntags:\n  - a\n  - b\nnested:\n  - id: 1\n    ok: true\n",
+			want:  `{"name":"demo","tags":["a","b"],"nested":[{"id":1,"ok":true}]}`,
+		},
+		{
+			name:  "core schema scalars",
+			input: "a: ~\nb: 0x1F\nc: 0o17\nd: 1.50\ne: yes\nf: '1'\ng: \"tab\\tend\"\n",
+			want:  `{"a":null,"b":31,"c":15,"d":1.50,"e":"yes","f":"1","g":"tab\tend"}`,
+		},
+		{
+			name:  "anchors and aliases",
+			input: "base: &base\n  x: 1\n  y: 2\ncopy: *base\nlist:\n  - &v hello\n  - *v\n",
+			want:  `{"base":{"x":1,"y":2},"copy":{"x":1,"y":2},"list":["hello","hello"]}`,
+		},
+		{
+			name:  "merge keys keep local members",
+			input: "defaults: &d\n  retries: 3\n  timeout: 10\nservice:\n  <<: *d\n  timeout: 30\n",
+			want:  `{"defaults":{"retries":3,"timeout":10},"service":{"retries":3,"timeout":30}}`,
+		},
+		{
+			name:  "literal block scalars",
+			input: "keep: |\n  line one\n  line two\nstrip: |-\n  text\nplus: |+\n  text\n\nnext: 1\n",
+			want:  `{"keep":"line one\nline two\n","strip":"text","plus":"text\n\n","next":1}`,
+		},
+		{
+			name:  "folded block scalars",
+			input: "folded: >\n  one\n  two\n\n  three\n",
+			want:  `{"folded":"one two\nthree\n"}`,
+		},
+		{
+			name:  "flow collections and comments",
+			input: "# header\nlist: [1, \"two\", {a: b}]  # trailing\nempty: {}\n",
+			want:  `{"list":[1,"two",{"a":"b"}],"empty":{}}`,
+		},
+		{
+			name:     "multiple documents",
+			input:    "---\na: 1\n---\nb: 2\n...\n",
+			want:     `[{"a":1},{"b":2}]`,
+			warnings: 1,
+		},
+		{
+			name:     "values JSON cannot hold",
+			input:    "a: .inf\n",
+			want:     `{"a":".inf"}`,
+			warnings: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runForTest[ConvertResult](t, runConvert, tt.input, `{"from": "yaml"}`)
+			if got := compactForTest(t, result.Output); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			if result.WarningCount != tt.warnings {
+				t.Errorf("warnings = %+v, want %d", result.Warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestReadYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"tab indentation", "a:\n\tb: 1\n", "line 2"},
+		{"bad indentation", "a: 1\n  b: 2\n", "line 2"},
+		{"unknown alias", "a: *missing\n", "line 1"},
+		{"unclosed flow", "a: [1, 2\n", "line 1"},
+		{"complex keys", "? a\n: 1\n", "complex mapping keys"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runConvert(OperationRequest{Operation: "convert", Input: tt.input, Options: []byte(`{"from": "yaml"}`)})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{"scalars", `{"s":"plain","n":-1.5e3,"b":false,"z":null,"big":12345678901234567890}`},
+		{"strings that need quoting", `{"yes":"yes","num":"1.0","empty":"","colon":"a: b","hash":"a #b","dash":"- x","lead":" x"}`},
+		{"block scalars", `{"keep":"one\ntwo\n","strip":"one\ntwo","plus":"one\n\n","indented":"  code\nmore"}`},
+		{"nesting", `{"list":[[1,2],[],{}],"obj":{"inner":[{"a":1,"b":[true]}]}}`},
+		{"top-level array", `[1,{"a":"x"},["y"]]`},
+		{"unusual keys", `{"":1,"a b":2,"1":3,"k:":4}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			yaml, got := roundTripForTest(t, tt.doc, formatYAML)
+			if got != tt.doc {
+				t.Errorf("round trip = %s, want %s\nYAML:\n%s", got, tt.doc, yaml)
+			}
+		})
+	}
+}